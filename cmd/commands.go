@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/nodes"
+	"github.com/jrmanes/torch/pkg/topology"
+)
+
+const timeoutDuration = 60 * time.Second // timeoutDuration max time to run a subcommand against the DB.
+
+// RunExport dumps all the node records stored in the DB into a file or the stdout.
+func RunExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("output", "", "Path to the file to write, stdout if empty")
+	format := fs.String("format", "", "Output format: json or yaml, default inferred from the file extension or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f := formatFromFile(*format, *output)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+	defer cancel()
+
+	red := redis.InitRedisConfig()
+	defer red.Close()
+
+	nodeIDs, err := redis.ExportNodes(red, ctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := redis.EncodeNodes(nodeIDs, f)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+
+	log.Info("Exporting [", len(nodeIDs), "] nodes to: ", *output)
+	return os.WriteFile(*output, data, 0644)
+}
+
+// RunImport loads the node records from a file into the DB.
+func RunImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("file", "", "Path to the file to import")
+	format := fs.String("format", "", "Input format: json or yaml, default inferred from the file extension or json")
+	dryRun := fs.Bool("dry-run", false, "Show the changes without applying them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return fmt.Errorf("the flag --file is required")
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+
+	nodeIDs, err := redis.DecodeNodes(data, formatFromFile(*format, *file))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+	defer cancel()
+
+	red := redis.InitRedisConfig()
+	defer red.Close()
+
+	result, err := redis.ImportNodes(red, ctx, nodeIDs, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+
+	return nil
+}
+
+// RunTopology renders the nodes and their connections from the config file, without connecting to the DB.
+func RunTopology(args []string) error {
+	fs := flag.NewFlagSet("topology", flag.ExitOnError)
+	configFile := fs.String("config-file", "", "Path to the configuration file")
+	output := fs.String("output", "", "Path to the file to write, stdout if empty")
+	format := fs.String("format", topology.FormatDOT, "Output format: dot or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *configFile == "" {
+		return fmt.Errorf("the flag --config-file is required")
+	}
+
+	cfg, err := ReadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	export := topology.Build(cfg).Export(nil)
+
+	var data []byte
+	switch strings.ToLower(*format) {
+	case topology.FormatDOT:
+		data = topology.EncodeDOT(export)
+	case topology.FormatJSON:
+		data, err = json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported format: [%s]", *format)
+	}
+
+	if *output == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+
+	log.Info("Writing the topology of [", len(export.Nodes), "] nodes to: ", *output)
+	return os.WriteFile(*output, data, 0644)
+}
+
+// RunPlan prints the actions that Torch would run to configure the nodes of the config, without running them. It only
+// reads the node records stored in the DB.
+func RunPlan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	configFile := fs.String("config-file", "", "Path to the configuration file")
+	node := fs.String("node", "", "Name of the node to plan, all the nodes of the config if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *configFile == "" {
+		return fmt.Errorf("the flag --config-file is required")
+	}
+
+	cfg, err := ReadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	var peers []config.Peer
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			if *node == "" || peer.NodeName == *node {
+				peers = append(peers, peer)
+			}
+		}
+	}
+	if len(peers) == 0 {
+		return fmt.Errorf("the node [%s] doesn't exist in the config", *node)
+	}
+
+	red := redis.InitRedisConfig()
+	defer red.Close()
+
+	plans := make([]nodes.Plan, 0, len(peers))
+	for _, peer := range peers {
+		plan, err := nodes.PlanNode(peer, cfg, red)
+		if err != nil {
+			return fmt.Errorf("error planning the node [%s]: %w", peer.NodeName, err)
+		}
+		plans = append(plans, plan)
+	}
+
+	out, err := json.MarshalIndent(plans, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+
+	return nil
+}
+
+// formatFromFile returns the format specified, otherwise, it uses the extension of the file.
+func formatFromFile(format, file string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return redis.FormatYAML
+	default:
+		return redis.FormatJSON
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
@@ -11,31 +14,50 @@ import (
 	"github.com/jrmanes/torch/config"
 	handlers "github.com/jrmanes/torch/pkg/http"
 	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/logging"
+	"github.com/jrmanes/torch/pkg/topology"
 )
 
 // ParseFlags parses the command-line flags and reads the configuration file.
 func ParseFlags() config.MutualPeersConfig {
 	// Define the flag for the configuration file path
 	configFile := flag.String("config-file", "", "Path to the configuration file")
+	// Define the flags of the logs, they overwrite LOG_FORMAT and LOG_LEVEL
+	logFormat := flag.String("log-format", logging.DefaultFormat(), "Format of the logs: text or json")
+	logLevel := flag.String("log-level", logging.DefaultLevel(), "Level of the logs: debug, info, warn or error")
 
 	// Parse the flags
 	flag.Parse()
 
-	// Read the configuration file
-	file, err := os.ReadFile(*configFile)
+	if err := logging.Configure(*logFormat, *logLevel); err != nil {
+		log.Fatal("Error configuring the logs: ", err)
+	}
+
+	cfg, err := ReadConfig(*configFile)
 	if err != nil {
-		log.Error("Config file doesn't exist...", err)
+		log.Error("Cannot read the config file...", err)
 	}
 
+	return cfg
+}
+
+// ReadConfig reads the configuration file, and expands its rules into peers.
+func ReadConfig(configFile string) (config.MutualPeersConfig, error) {
 	cfg := config.MutualPeersConfig{}
 
+	// Read the configuration file
+	file, err := os.ReadFile(configFile)
+	if err != nil {
+		return cfg, err
+	}
+
 	// Unmarshal the YAML into a struct
 	err = yaml.Unmarshal(file, &cfg)
 	if err != nil {
-		log.Error("Cannot unmarshal the config file...", err)
+		return cfg, err
 	}
 
-	return cfg
+	return topology.Expand(cfg)
 }
 
 func PrintName() {
@@ -51,11 +73,59 @@ func PrintName() {
 	fmt.Println(torch)
 }
 
+// runSubcommand executes the subcommand received, it returns false if there is no subcommand to run.
+func runSubcommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	var err error
+	switch args[0] {
+	case "export":
+		err = RunExport(args[1:])
+	case "import":
+		err = RunImport(args[1:])
+	case "topology":
+		err = RunTopology(args[1:])
+	case "plan":
+		err = RunPlan(args[1:])
+	default:
+		return false
+	}
+
+	if err != nil {
+		log.Fatal("Error running the subcommand [", args[0], "]: ", err)
+	}
+
+	return true
+}
+
 func main() {
+	// configure the logs using the env vars, the flags are parsed later
+	if err := logging.Configure(logging.DefaultFormat(), logging.DefaultLevel()); err != nil {
+		log.Fatal("Error configuring the logs: ", err)
+	}
+
+	// check if we have to run a subcommand instead of the server
+	if runSubcommand(os.Args[1:]) {
+		return
+	}
+
 	PrintName()
 	// Parse the command-line flags and read the configuration file
 	log.Info("Running on namespace: ", k8s.GetCurrentNamespace())
 	cfg := ParseFlags()
 
-	handlers.Run(cfg)
+	// the root context is cancelled on SIGINT or SIGTERM, and all the components are stopped with it
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// restore the default behaviour, so a second signal kills the process if the shutdown gets stuck
+		stop()
+	}()
+
+	if err := handlers.Run(ctx, cfg); err != nil {
+		log.Fatal("Error running Torch: ", err)
+	}
 }
@@ -2,7 +2,40 @@ package config
 
 // MutualPeersConfig represents the configuration structure.
 type MutualPeersConfig struct {
-	MutualPeers []*MutualPeer `yaml:"mutualPeers"` // MutualPeers list of mutual peers.
+	MutualPeers []*MutualPeer `yaml:"mutualPeers"`     // MutualPeers list of mutual peers.
+	Rules       []Rule        `yaml:"rules,omitempty"` // Rules to generate peers when the config is loaded.
+}
+
+// Rule represents a group of nodes generated when the config is loaded, and how they connect to the other nodes.
+type Rule struct {
+	Name               string          `yaml:"name"`                         // Name of the rule.
+	NodeType           string          `yaml:"nodeType"`                     // NodeType of the nodes generated.
+	NamePattern        string          `yaml:"namePattern"`                  // NamePattern name of the nodes with %d as the index, e.g. da-full-%d-0
+	Count              int             `yaml:"count"`                        // Count number of nodes generated.
+	StartIndex         int             `yaml:"startIndex,omitempty"`         // StartIndex index of the first node, default 1.
+	Namespace          string          `yaml:"namespace,omitempty"`          // Namespace of the nodes
+	ContainerName      string          `yaml:"containerName,omitempty"`      // ContainerName name of the main container
+	ContainerSetupName string          `yaml:"containerSetupName,omitempty"` // ContainerSetupName initContainer name
+	ConnectsAsEnvVar   bool            `yaml:"connectsAsEnvVar,omitempty"`   // ConnectsAsEnvVar use the value as env var
+	ConnectsTo         *RuleConnection `yaml:"connectsTo,omitempty"`         // ConnectsTo nodes that the generated nodes connect to
+}
+
+// RuleConnection represents the nodes that the nodes generated by a rule connect to.
+type RuleConnection struct {
+	NodeType string `yaml:"nodeType"`           // NodeType of the nodes to connect to.
+	Count    int    `yaml:"count,omitempty"`    // Count number of nodes to connect to, default 1.
+	Strategy string `yaml:"strategy,omitempty"` // Strategy spread (default) or sameIndex.
+}
+
+// Unexpanded returns the config without the peers generated by the rules.
+func (c MutualPeersConfig) Unexpanded() MutualPeersConfig {
+	unexpanded := MutualPeersConfig{Rules: c.Rules}
+	for _, mutualPeer := range c.MutualPeers {
+		if mutualPeer.Rule == "" {
+			unexpanded.MutualPeers = append(unexpanded.MutualPeers, mutualPeer)
+		}
+	}
+	return unexpanded
 }
 
 // MutualPeer represents a mutual peer structure.
@@ -10,6 +43,7 @@ type MutualPeer struct {
 	ConsensusNode    string `yaml:"consensusNode,omitempty"`    // ConsensusNode name
 	Peers            []Peer `yaml:"peers"`                      // Peer list of peers.
 	TrustedPeersPath string `yaml:"trustedPeersPath,omitempty"` // TrustedPeersPath specify the path to keep the files
+	Rule             string `yaml:"rule,omitempty"`             // Rule name of the rule which generated the peers
 }
 
 // Peer represents a peer structure.
@@ -22,6 +56,9 @@ type Peer struct {
 	ContainerSetupName string   `yaml:"containerSetupName,omitempty"` // ContainerSetupName initContainer name
 	ConnectsAsEnvVar   bool     `yaml:"connectsAsEnvVar,omitempty"`   // ConnectsAsEnvVar use the value as env var
 	ConnectsTo         []string `yaml:"connectsTo,omitempty"`         // ConnectsTo list of nodes that it will connect to
+	Seeds              []string `yaml:"seeds,omitempty"`              // Seeds list of consensus nodes used as seeds
 	DnsConnections     []string `yaml:"dnsConnections,omitempty"`     // DnsConnections list of DNS records
+	NodeStore          string   `yaml:"nodeStore,omitempty"`          // NodeStore path of the store of the DA node
+	AuthTokenSecret    string   `yaml:"authTokenSecret,omitempty"`    // AuthTokenSecret Secret with the token of the DA node
 	RetryCount         int      `yaml:"retryCount,omitempty"`         // RetryCount number of retries
 }
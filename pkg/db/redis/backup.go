@@ -0,0 +1,145 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/jrmanes/torch/pkg/logging"
+)
+
+const (
+	FormatJSON = "json" // FormatJSON encodes the node records as JSON.
+	FormatYAML = "yaml" // FormatYAML encodes the node records as YAML.
+)
+
+var (
+	// internalKeyPrefixes keys stored in Redis that don't belong to the nodes, like the ones used by rmq or the locks.
+	internalKeyPrefixes = []string{"rmq::", "torch::"}
+
+	// ErrInvalidNodeName returned when the name is empty or it's one of the internal keys.
+	ErrInvalidNodeName = errors.New("invalid node name")
+)
+
+// ImportResult represents the outcome of an import, grouping the node names by the action applied to them.
+type ImportResult struct {
+	DryRun      bool     `json:"dryRun" yaml:"dryRun"`           // DryRun true if the changes were not applied.
+	Created     []string `json:"created" yaml:"created"`         // Created nodes that didn't exist in the DB.
+	Overwritten []string `json:"overwritten" yaml:"overwritten"` // Overwritten nodes that existed with a different value.
+	Unchanged   []string `json:"unchanged" yaml:"unchanged"`     // Unchanged nodes that already had the same value.
+}
+
+// IsNodeKey returns false for the keys that Torch doesn't use to store node records.
+func IsNodeKey(key string) bool {
+	for _, prefix := range internalKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// ExportNodes returns all the node records stored in the DB.
+func ExportNodes(r *RedisClient, ctx context.Context) (map[string]string, error) {
+	nodes, err := r.GetAllKeys(ctx)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Error getting the keys and values")
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// ImportNodes stores the node records received, overwriting the ones which have a different value.
+// If dryRun is true, the DB is not modified, and the result shows what would be done.
+func ImportNodes(r *RedisClient, ctx context.Context, nodes map[string]string, dryRun bool) (ImportResult, error) {
+	result := ImportResult{
+		DryRun:      dryRun,
+		Created:     []string{},
+		Overwritten: []string{},
+		Unchanged:   []string{},
+	}
+
+	// sort the node names to get the same result every time
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := nodes[name]
+		if name == "" || !IsNodeKey(name) {
+			return result, fmt.Errorf("%w: [%s]", ErrInvalidNodeName, name)
+		}
+
+		current, err := CheckIfNodeExistsInDB(r, ctx, name)
+		if err != nil {
+			return result, err
+		}
+
+		action := NodeUpdated
+		switch {
+		case current == "":
+			action = NodeCreated
+			result.Created = append(result.Created, name)
+		case current != value:
+			result.Overwritten = append(result.Overwritten, name)
+		default:
+			result.Unchanged = append(result.Unchanged, name)
+			continue
+		}
+
+		if dryRun {
+			continue
+		}
+
+		logger := logging.ForNodeName(ctx, name).WithField("value", value)
+		logger.Info("Importing node")
+		err = r.SetKey(ctx, name, value, nodeIdExpiration)
+		if err != nil {
+			logger.WithError(err).Error("Error importing the node")
+			return result, err
+		}
+		PublishNodeEvent(r, ctx, action, name, value)
+	}
+
+	return result, nil
+}
+
+// EncodeNodes encodes the node records using the format specified.
+func EncodeNodes(nodes map[string]string, format string) ([]byte, error) {
+	switch format {
+	case FormatJSON, "":
+		return json.MarshalIndent(nodes, "", "  ")
+	case FormatYAML:
+		return yaml.Marshal(nodes)
+	default:
+		return nil, fmt.Errorf("unsupported format: [%s]", format)
+	}
+}
+
+// DecodeNodes decodes the node records using the format specified.
+func DecodeNodes(data []byte, format string) (map[string]string, error) {
+	nodes := make(map[string]string)
+
+	var err error
+	switch format {
+	case FormatJSON, "":
+		err = json.Unmarshal(data, &nodes)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &nodes)
+	default:
+		return nil, fmt.Errorf("unsupported format: [%s]", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,151 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// TestImportNodes checks the keys created, overwritten and left alone by the import.
+func TestImportNodes(t *testing.T) {
+	s := miniredis.RunT(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		dryRun    bool
+		want      ImportResult
+		wantStore map[string]string
+	}{
+		{
+			name:   "Case 1: Dry run doesn't modify the DB",
+			dryRun: true,
+			want: ImportResult{
+				DryRun:      true,
+				Created:     []string{"da-bridge-2-0"},
+				Overwritten: []string{"da-bridge-1-0"},
+				Unchanged:   []string{"da-full-1-0"},
+			},
+			wantStore: map[string]string{
+				"da-bridge-1-0": "/dns/da-bridge-1/tcp/2121/p2p/old",
+				"da-full-1-0":   "/dns/da-full-1/tcp/2121/p2p/full",
+			},
+		},
+		{
+			name:   "Case 2: Import stores the nodes",
+			dryRun: false,
+			want: ImportResult{
+				DryRun:      false,
+				Created:     []string{"da-bridge-2-0"},
+				Overwritten: []string{"da-bridge-1-0"},
+				Unchanged:   []string{"da-full-1-0"},
+			},
+			wantStore: map[string]string{
+				"da-bridge-1-0": "/dns/da-bridge-1/tcp/2121/p2p/new",
+				"da-bridge-2-0": "/dns/da-bridge-2/tcp/2121/p2p/new",
+				"da-full-1-0":   "/dns/da-full-1/tcp/2121/p2p/full",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.FlushAll()
+			s.Set("da-bridge-1-0", "/dns/da-bridge-1/tcp/2121/p2p/old")
+			s.Set("da-full-1-0", "/dns/da-full-1/tcp/2121/p2p/full")
+
+			red := NewRedisClient(s.Addr(), "", 0)
+			got, err := ImportNodes(red, ctx, map[string]string{
+				"da-bridge-1-0": "/dns/da-bridge-1/tcp/2121/p2p/new",
+				"da-bridge-2-0": "/dns/da-bridge-2/tcp/2121/p2p/new",
+				"da-full-1-0":   "/dns/da-full-1/tcp/2121/p2p/full",
+			}, tt.dryRun)
+			if err != nil {
+				t.Fatalf("ImportNodes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ImportNodes() = %v, want %v", got, tt.want)
+			}
+
+			store, err := ExportNodes(red, ctx)
+			if err != nil {
+				t.Fatalf("ExportNodes() error = %v", err)
+			}
+			if !reflect.DeepEqual(store, tt.wantStore) {
+				t.Errorf("ExportNodes() = %v, want %v", store, tt.wantStore)
+			}
+		})
+	}
+}
+
+// TestEncodeDecodeNodes checks that the node records are the same after encoding and decoding them.
+func TestEncodeDecodeNodes(t *testing.T) {
+	nodes := map[string]string{
+		"da-bridge-1-0": "/dns/da-bridge-1/tcp/2121/p2p/12D3KooWNFpkX9fuo3GQ38FaVKdAZcTQsLr1BNE5DTHGjv2fjEHG",
+		"da-full-1-0":   "/ip4/100.64.5.15/tcp/2121/p2p/12D3KooWL8cqu7dFyodQNLWgJLuCzsQiv617SN9WDVX2GiZnjmeE",
+	}
+
+	for _, format := range []string{FormatJSON, FormatYAML} {
+		t.Run(format, func(t *testing.T) {
+			data, err := EncodeNodes(nodes, format)
+			if err != nil {
+				t.Fatalf("EncodeNodes() error = %v", err)
+			}
+			got, err := DecodeNodes(data, format)
+			if err != nil {
+				t.Fatalf("DecodeNodes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, nodes) {
+				t.Errorf("DecodeNodes() = %v, want %v", got, nodes)
+			}
+		})
+	}
+
+	if _, err := EncodeNodes(nodes, "xml"); err == nil {
+		t.Errorf("EncodeNodes() expected an error with an unsupported format")
+	}
+}
+
+// TestGetAllKeysSkipsInternalKeys checks that the locks and the queues stored in the same DB are not listed.
+func TestGetAllKeysSkipsInternalKeys(t *testing.T) {
+	s := miniredis.RunT(t)
+	s.Set("da-bridge-1-0", "/dns/da-bridge-1/tcp/2121/p2p/id")
+	s.Set("torch::lock::da-bridge-1-0", "token")
+	s.Lpush("rmq::queue::[k8s]::ready", "da-full-1-0")
+
+	red := NewRedisClient(s.Addr(), "", 0)
+	got, err := red.GetAllKeys(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllKeys() error = %v", err)
+	}
+
+	want := map[string]string{"da-bridge-1-0": "/dns/da-bridge-1/tcp/2121/p2p/id"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAllKeys() = %v, want %v", got, want)
+	}
+}
+
+// TestDeleteNodeIdSkipsInternalKeys checks that the keys of the queues and the locks can't be removed as nodes.
+func TestDeleteNodeIdSkipsInternalKeys(t *testing.T) {
+	s := miniredis.RunT(t)
+	red := NewRedisClient(s.Addr(), "", 0)
+	ctx := context.Background()
+
+	s.Lpush("rmq::queues", "k8s")
+	if err := s.Set("torch::lock::da-full-1-0", "token"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	for _, key := range []string{"rmq::queues", "torch::lock::da-full-1-0", ""} {
+		deleted, err := DeleteNodeId(key, red, ctx)
+		if !errors.Is(err, ErrInvalidNodeName) || deleted {
+			t.Errorf("DeleteNodeId(%q) = %v, error = %v, want %v", key, deleted, err, ErrInvalidNodeName)
+		}
+	}
+	if !s.Exists("rmq::queues") || !s.Exists("torch::lock::da-full-1-0") {
+		t.Errorf("the internal keys were removed")
+	}
+}
@@ -0,0 +1,142 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/pkg/logging"
+)
+
+const (
+	NodeEventsChannel = "torch::nodes" // NodeEventsChannel channel where Torch publishes the changes of the node records.
+
+	NodeCreated = "created" // NodeCreated the node record didn't exist.
+	NodeUpdated = "updated" // NodeUpdated the node record has been overwritten.
+	NodeDeleted = "deleted" // NodeDeleted the node record has been removed.
+)
+
+var (
+	replicaID = getReplicaID() // replicaID identifies the Torch replica which publishes the events, using the pod name.
+
+	subscribeRetryDelay    = time.Second      // subscribeRetryDelay time to wait before subscribing again, it's doubled on every failure.
+	maxSubscribeRetryDelay = 30 * time.Second // maxSubscribeRetryDelay max time to wait before subscribing again.
+)
+
+// NodeEvent represents a change of a node record.
+type NodeEvent struct {
+	Action    string    `json:"action"`          // Action applied to the node record: created, updated or deleted.
+	NodeName  string    `json:"nodeName"`        // NodeName name of the node.
+	Value     string    `json:"value,omitempty"` // Value of the node record, empty when it's deleted.
+	Source    string    `json:"source"`          // Source replica of Torch which made the change.
+	Timestamp time.Time `json:"timestamp"`       // Timestamp when the change was made.
+}
+
+// IsLocal returns true if the event was published by this replica.
+func (e NodeEvent) IsLocal() bool {
+	return e.Source == replicaID
+}
+
+// PublishNodeEvent publishes the change of the node record in the NodeEventsChannel.
+// The errors are only logged, as the change has already been applied.
+func PublishNodeEvent(r *RedisClient, ctx context.Context, action, nodeName, value string) {
+	event := NodeEvent{
+		Action:    action,
+		NodeName:  nodeName,
+		Value:     value,
+		Source:    replicaID,
+		Timestamp: time.Now().UTC(),
+	}
+
+	logger := logging.ForNodeName(ctx, nodeName).WithField("action", action)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		logger.WithError(err).Error("Error encoding the node event")
+		return
+	}
+
+	if err := r.client.Publish(ctx, NodeEventsChannel, payload).Err(); err != nil {
+		logger.WithError(err).Error("Error publishing the node event")
+	}
+}
+
+// SubscribeNodeEvents subscribes to the NodeEventsChannel and calls the handler with every event received,
+// it blocks until the context is canceled.
+func SubscribeNodeEvents(ctx context.Context, r *RedisClient, handler func(NodeEvent)) error {
+	pubsub := r.client.Subscribe(ctx, NodeEventsChannel)
+	defer pubsub.Close()
+
+	logger := log.WithField("channel", NodeEventsChannel)
+	// wait for the confirmation, so we know that the subscription is ready
+	if _, err := pubsub.Receive(ctx); err != nil {
+		logger.WithError(err).Error("Error subscribing to the channel")
+		return err
+	}
+	logger.Info("Subscribed to the channel")
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			var event NodeEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				logger.WithError(err).Error("Error decoding the node event")
+				continue
+			}
+			handler(event)
+		}
+	}
+}
+
+// getReplicaID returns the name of the pod, or the hostname if the env var is not defined.
+func getReplicaID() string {
+	if podName := os.Getenv("POD_NAME"); podName != "" {
+		return podName
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.WithError(err).Warn("Error getting the hostname")
+		return "torch"
+	}
+	return hostname
+}
+
+// WatchNodeEvents calls SubscribeNodeEvents and subscribes again with backoff when the subscription fails,
+// so a Redis outage doesn't stop Torch. It blocks until the context is canceled.
+func WatchNodeEvents(ctx context.Context, r *RedisClient, handler func(NodeEvent)) {
+	delay := subscribeRetryDelay
+	for {
+		start := time.Now()
+		err := SubscribeNodeEvents(ctx, r, handler)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// the subscription was working, so it starts again with the min delay
+		if time.Since(start) > maxSubscribeRetryDelay {
+			delay = subscribeRetryDelay
+		}
+		log.WithField("channel", NodeEventsChannel).WithField("delay", delay).WithError(err).
+			Warn("The subscription to the channel ended, subscribing again")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxSubscribeRetryDelay {
+			delay = maxSubscribeRetryDelay
+		}
+	}
+}
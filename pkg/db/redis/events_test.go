@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// TestNodeEvents checks that the changes of the node records are published in the channel.
+func TestNodeEvents(t *testing.T) {
+	s := miniredis.RunT(t)
+	red := NewRedisClient(s.Addr(), "", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := make(chan NodeEvent, 10)
+	go func() {
+		_ = SubscribeNodeEvents(ctx, red, func(e NodeEvent) {
+			events <- e
+		})
+	}()
+
+	// wait until the subscription is ready
+	for s.PubSubNumSub(NodeEventsChannel)[NodeEventsChannel] == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := SetNodeId("da-bridge-1-0", red, ctx, "id-1"); err != nil {
+		t.Fatalf("SetNodeId() error = %v", err)
+	}
+	if err := UpdateNodeId("da-bridge-1-0", red, ctx, "id-2"); err != nil {
+		t.Fatalf("UpdateNodeId() error = %v", err)
+	}
+	if _, err := DeleteNodeId("da-bridge-1-0", red, ctx); err != nil {
+		t.Fatalf("DeleteNodeId() error = %v", err)
+	}
+	// the node doesn't exist anymore, so updating it creates it again
+	if err := UpdateNodeId("da-bridge-1-0", red, ctx, "id-3"); err != nil {
+		t.Fatalf("UpdateNodeId() error = %v", err)
+	}
+
+	want := []NodeEvent{
+		{Action: NodeCreated, NodeName: "da-bridge-1-0", Value: "id-1"},
+		{Action: NodeUpdated, NodeName: "da-bridge-1-0", Value: "id-2"},
+		{Action: NodeDeleted, NodeName: "da-bridge-1-0", Value: ""},
+		{Action: NodeCreated, NodeName: "da-bridge-1-0", Value: "id-3"},
+	}
+	for _, w := range want {
+		select {
+		case got := <-events:
+			if got.Action != w.Action || got.NodeName != w.NodeName || got.Value != w.Value {
+				t.Errorf("NodeEvent = %+v, want %+v", got, w)
+			}
+			if !got.IsLocal() {
+				t.Errorf("NodeEvent.IsLocal() = false, want true")
+			}
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for the event: %+v", w)
+		}
+	}
+}
+
+// TestWatchNodeEventsSubscribesAgain checks that the subscription is retried until Redis is available.
+func TestWatchNodeEventsSubscribesAgain(t *testing.T) {
+	s := miniredis.RunT(t)
+	red := NewRedisClient(s.Addr(), "", 0)
+	s.Close()
+
+	subscribeRetryDelay = 10 * time.Millisecond
+	defer func() { subscribeRetryDelay = time.Second }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := make(chan NodeEvent, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WatchNodeEvents(ctx, red, func(e NodeEvent) {
+			events <- e
+		})
+	}()
+
+	// let the first subscriptions fail
+	time.Sleep(50 * time.Millisecond)
+	if err := s.Restart(); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	for s.PubSubNumSub(NodeEventsChannel)[NodeEventsChannel] == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for the subscription")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	PublishNodeEvent(red, ctx, NodeCreated, "da-bridge-1-0", "id-1")
+	select {
+	case got := <-events:
+		if got.Action != NodeCreated || got.NodeName != "da-bridge-1-0" {
+			t.Errorf("NodeEvent = %+v, want created da-bridge-1-0", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for the event")
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+// metricsHook records the latency of the commands sent to Redis, and traces them when they are part of a trace.
+type metricsHook struct{}
+
+// DialHook doesn't record the new connections, they are in the pool metrics.
+func (metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ProcessHook records the latency of the command.
+func (metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := traceOperation(ctx, cmd.Name(), func(ctx context.Context) error {
+			return next(ctx, cmd)
+		})
+		metrics.ObserveRedisOperation(cmd.Name(), time.Since(start), operationError(err))
+		return err
+	}
+}
+
+// ProcessPipelineHook records the latency of the pipeline as a single operation.
+func (metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := traceOperation(ctx, "pipeline", func(ctx context.Context) error {
+			return next(ctx, cmds)
+		})
+		metrics.ObserveRedisOperation("pipeline", time.Since(start), operationError(err))
+		return err
+	}
+}
+
+// traceOperation runs the operation in a span if the context is part of a trace, the background operations, like the
+// heartbeats of the queue, are not traced.
+func traceOperation(ctx context.Context, operation string, run func(ctx context.Context) error) error {
+	if !metrics.HasSpan(ctx) {
+		return run(ctx)
+	}
+
+	ctx, span := metrics.StartSpan(ctx, "redis "+operation,
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", operation),
+	)
+	err := run(ctx)
+	metrics.EndSpan(span, operationError(err))
+	return err
+}
+
+// operationError returns the error of the command, the keys which don't exist are not errors.
+func operationError(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
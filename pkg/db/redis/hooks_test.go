@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestMetricsHookSpans checks that the commands are traced only as part of a trace, and the missing keys are neither
+// errors in the span nor for the caller.
+func TestMetricsHookSpans(t *testing.T) {
+	s := miniredis.RunT(t)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+
+	red := NewRedisClient(s.Addr(), "", 0)
+
+	tests := []struct {
+		name      string
+		traced    bool
+		wantSpans []string
+	}{
+		{
+			name:      "Case 1: The background commands are not traced",
+			traced:    false,
+			wantSpans: nil,
+		},
+		{
+			name:      "Case 2: The commands of a trace are traced",
+			traced:    true,
+			wantSpans: []string{"redis set", "redis get"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.traced {
+				var parent trace.Span
+				ctx, parent = provider.Tracer("test").Start(ctx, "ConfigureNode")
+				defer parent.End()
+			}
+			before := len(recorder.Ended())
+
+			if err := red.SetKey(ctx, "da-bridge-1-0", "/dns/da-bridge-1/tcp/2121/p2p/a", 0); err != nil {
+				t.Fatalf("SetKey() error = %v", err)
+			}
+			value, err := red.GetKey(ctx, "da-full-1-0")
+			if err != nil || value != "" {
+				t.Fatalf("GetKey() = %s, %v, want an empty value", value, err)
+			}
+
+			ended := recorder.Ended()[before:]
+			if len(ended) != len(tt.wantSpans) {
+				t.Fatalf("spans = %d, want %d", len(ended), len(tt.wantSpans))
+			}
+			for i, span := range ended {
+				if span.Name() != tt.wantSpans[i] {
+					t.Errorf("span[%d] = %s, want %s", i, span.Name(), tt.wantSpans[i])
+				}
+				if span.Status().Code == codes.Error {
+					t.Errorf("span[%d] status = %v, want no error", i, span.Status())
+				}
+			}
+		})
+	}
+}
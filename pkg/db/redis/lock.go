@@ -0,0 +1,164 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/jrmanes/torch/pkg/logging"
+)
+
+const (
+	lockPrefix        = "torch::lock::"        // lockPrefix prefix of the keys used to lock the nodes.
+	lockRetryInterval = 250 * time.Millisecond // lockRetryInterval time to wait before trying to acquire a lock again.
+)
+
+var (
+	// unlockScript removes the lock only if it's still owned by the token received.
+	unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+	// renewScript extends the lease of the lock only if it's still owned by the token received.
+	renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// Locker acquires exclusive locks by key.
+// Lock blocks until the lock is acquired or the context is done, it returns the function to release the lock,
+// and true if the lock was held by someone else when it was requested.
+type Locker interface {
+	Lock(ctx context.Context, key string) (unlock func(), contended bool, err error)
+}
+
+// RedisLocker is a Locker shared by all the replicas of Torch, the locks expire after the lease if they are not
+// renewed, so a replica which dies doesn't keep them forever.
+type RedisLocker struct {
+	r     *RedisClient
+	lease time.Duration
+}
+
+// NewRedisLocker returns a RedisLocker using the lease specified.
+func NewRedisLocker(r *RedisClient, lease time.Duration) *RedisLocker {
+	return &RedisLocker{r: r, lease: lease}
+}
+
+// Lock acquires the lock of the key, the lease is renewed in the background until the lock is released.
+func (l *RedisLocker) Lock(ctx context.Context, key string) (func(), bool, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	lockKey := lockPrefix + key
+	logger := logging.ForNodeName(ctx, key)
+	contended := false
+	for {
+		ok, err := l.r.client.SetNX(ctx, lockKey, token, l.lease).Result()
+		if err != nil {
+			return nil, contended, err
+		}
+		if ok {
+			break
+		}
+
+		contended = true
+		select {
+		case <-ctx.Done():
+			return nil, contended, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	// renew the lease while the lock is held
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(l.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := renewScript.Run(context.Background(), l.r.client, []string{lockKey}, token, l.lease.Milliseconds()).Err()
+				if err != nil {
+					logger.WithError(err).Error("Error renewing the lock")
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	unlock := func() {
+		once.Do(func() {
+			close(stop)
+			err := unlockScript.Run(context.Background(), l.r.client, []string{lockKey}, token).Err()
+			if err != nil {
+				logger.WithError(err).Error("Error releasing the lock")
+			}
+		})
+	}
+
+	return unlock, contended, nil
+}
+
+// MemoryLocker is a Locker which only works within the same process, it's used when Redis is not available.
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+// NewMemoryLocker returns an empty MemoryLocker.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: make(map[string]chan struct{})}
+}
+
+// Lock acquires the lock of the key.
+func (l *MemoryLocker) Lock(ctx context.Context, key string) (func(), bool, error) {
+	contended := false
+	for {
+		l.mu.Lock()
+		held, ok := l.locks[key]
+		if !ok {
+			released := make(chan struct{})
+			l.locks[key] = released
+			l.mu.Unlock()
+
+			var once sync.Once
+			unlock := func() {
+				once.Do(func() {
+					l.mu.Lock()
+					delete(l.locks, key)
+					l.mu.Unlock()
+					close(released)
+				})
+			}
+			return unlock, contended, nil
+		}
+		l.mu.Unlock()
+
+		contended = true
+		select {
+		case <-ctx.Done():
+			return nil, contended, ctx.Err()
+		case <-held:
+		}
+	}
+}
+
+// newLockToken returns a random token to identify the owner of a lock.
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
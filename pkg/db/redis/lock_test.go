@@ -0,0 +1,64 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// TestLockers checks that the lock of a key can't be acquired twice until it's released.
+func TestLockers(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	tests := []struct {
+		name   string
+		locker Locker
+	}{
+		{
+			name:   "Case 1: Redis locker",
+			locker: NewRedisLocker(NewRedisClient(s.Addr(), "", 0), time.Minute),
+		},
+		{
+			name:   "Case 2: Memory locker",
+			locker: NewMemoryLocker(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			unlock, contended, err := tt.locker.Lock(ctx, "da-bridge-1-0")
+			if err != nil || contended {
+				t.Fatalf("Lock() contended = %v, error = %v", contended, err)
+			}
+
+			// another node can be locked at the same time
+			unlockOther, _, err := tt.locker.Lock(ctx, "da-bridge-2-0")
+			if err != nil {
+				t.Fatalf("Lock() error = %v", err)
+			}
+			unlockOther()
+
+			// the same node can't be locked until it's released
+			timeoutCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+			defer cancel()
+			if _, contended, err := tt.locker.Lock(timeoutCtx, "da-bridge-1-0"); err == nil || !contended {
+				t.Fatalf("Lock() contended = %v, error = %v, want the lock to be held", contended, err)
+			}
+
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				unlock()
+			}()
+
+			unlock, contended, err = tt.locker.Lock(ctx, "da-bridge-1-0")
+			if err != nil || !contended {
+				t.Fatalf("Lock() contended = %v, error = %v, want to acquire it after waiting", contended, err)
+			}
+			unlock()
+		})
+	}
+}
@@ -2,11 +2,15 @@ package redis
 
 import (
 	"context"
+	"fmt"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/jrmanes/torch/pkg/logging"
 )
 
+// nodeIdExpiration time that the node records are kept in the DB.
+const nodeIdExpiration = 1000 * time.Hour
+
 // SetNodeId stores the values in redis.
 func SetNodeId(
 	podName string,
@@ -14,6 +18,7 @@ func SetNodeId(
 	ctx context.Context,
 	output string,
 ) error {
+	logger := logging.ForNodeName(ctx, podName)
 	// try to get the value from redis
 	// if the value is empty, then we add it
 	nodeName, err := CheckIfNodeExistsInDB(r, ctx, podName)
@@ -23,30 +28,83 @@ func SetNodeId(
 
 	// if the node is not in the db, then we add it
 	if nodeName == "" {
-		log.Info("Node ", "["+podName+"]"+" not found in Redis, let's add it")
-		err := r.SetKey(ctx, podName, output, 1000*time.Hour)
+		logger.Info("Node not found in Redis, let's add it")
+		err := r.SetKey(ctx, podName, output, nodeIdExpiration)
 		if err != nil {
-			log.Error("Error adding the node to redis: ", err)
+			logger.WithError(err).Error("Error adding the node to redis")
 			return err
 		}
+		PublishNodeEvent(r, ctx, NodeCreated, podName, output)
 	} else {
-		log.Info("Node ", "["+podName+"]"+" found in Redis")
+		logger.Info("Node found in Redis")
+	}
+
+	return nil
+}
+
+// UpdateNodeId stores the value in redis, overwriting it if the node already exists. It publishes a created event
+// if the node was not in the DB, and an updated one otherwise.
+func UpdateNodeId(
+	podName string,
+	r *RedisClient,
+	ctx context.Context,
+	output string,
+) error {
+	logger := logging.ForNodeName(ctx, podName)
+	logger.Info("Updating node in Redis")
+	_, existed, err := r.SwapKey(ctx, podName, output, nodeIdExpiration)
+	if err != nil {
+		logger.WithError(err).Error("Error updating the node in redis")
+		return err
+	}
+
+	action := NodeUpdated
+	if !existed {
+		action = NodeCreated
 	}
+	PublishNodeEvent(r, ctx, action, podName, output)
 
 	return nil
 }
 
+// DeleteNodeId removes the node from redis, it returns true if the node existed. The internal keys can't be removed.
+func DeleteNodeId(
+	podName string,
+	r *RedisClient,
+	ctx context.Context,
+) (bool, error) {
+	if podName == "" || !IsNodeKey(podName) {
+		return false, fmt.Errorf("%w: [%s]", ErrInvalidNodeName, podName)
+	}
+
+	logger := logging.ForNodeName(ctx, podName)
+	deleted, err := r.DelKey(ctx, podName)
+	if err != nil {
+		logger.WithError(err).Error("Error deleting the node from redis")
+		return false, err
+	}
+
+	if deleted {
+		logger.Info("Node deleted from Redis")
+		PublishNodeEvent(r, ctx, NodeDeleted, podName, "")
+	} else {
+		logger.Info("Node not found in Redis")
+	}
+
+	return deleted, nil
+}
+
 // CheckIfNodeExistsInDB checks if node is in the DB and return it.
 func CheckIfNodeExistsInDB(
 	r *RedisClient,
 	ctx context.Context,
 	nodeName string,
 ) (string, error) {
-	nodeName, err := r.GetKey(ctx, nodeName)
+	value, err := r.GetKey(ctx, nodeName)
 	if err != nil {
-		log.Error("Error: ", err)
+		logging.ForNodeName(ctx, nodeName).WithError(err).Error("Error getting the node from redis")
 		return "", err
 	}
 
-	return nodeName, err
+	return value, err
 }
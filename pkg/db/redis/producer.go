@@ -3,36 +3,55 @@ package redis
 import (
 	"github.com/adjust/rmq/v5"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/pkg/logging"
+	"github.com/jrmanes/torch/pkg/metrics"
 )
 
-// Producer add data into the queue.
-func Producer(data, queueName string) error {
-	log.Info("Adding STS [", data, "] node to the queue: [", queueName, "]")
-	data += "-0" // we add the suffix as the pods have it in their name when we use a StatefulSet.
-	log.Info("Getting the pod from the STS [", data, "]")
-
-	connection, err := rmq.OpenConnection(
-		"producer",
-		"tcp",
-		GetRedisFullURL(),
-		2,
-		nil,
-	)
+// Producer adds data into a queue using a long-lived connection.
+type Producer struct {
+	queue     rmq.Queue
+	queueName string
+}
+
+// OpenQueueConnection opens the rmq connection using the Redis client, so both share the same pool.
+// The errors of the connection heartbeat are sent to the errChan.
+func OpenQueueConnection(tag string, r *RedisClient, errChan chan<- error) (rmq.Connection, error) {
+	logger := log.WithField("tag", tag)
+	logger.Info("Opening the queue connection")
+	connection, err := rmq.OpenConnectionWithRedisClient(tag, r.client, errChan)
 	if err != nil {
-		log.Error("Error: ", err)
-		return err
+		logger.WithError(err).Error("Error opening the queue connection")
+		return nil, err
 	}
 
+	return connection, nil
+}
+
+// NewProducer returns a Producer which publishes into the queue specified.
+func NewProducer(connection rmq.Connection, queueName string) (*Producer, error) {
 	queue, err := connection.OpenQueue(queueName)
 	if err != nil {
-		log.Error("Error: ", err)
-		return err
+		log.WithField("queue", queueName).WithError(err).Error("Error opening the queue")
+		return nil, err
 	}
 
-	if err := queue.Publish(data); err != nil {
-		log.Error("Error, failed to publish: ", err)
+	return &Producer{queue: queue, queueName: queueName}, nil
+}
+
+// Publish add data into the queue.
+func (p *Producer) Publish(data string) error {
+	logger := log.WithFields(log.Fields{"queue": p.queueName, "statefulSet": data})
+	logger.Info("Adding STS node to the queue")
+	data += "-0" // we add the suffix as the pods have it in their name when we use a StatefulSet.
+	logger = logger.WithField(logging.FieldNode, data)
+	logger.Info("Getting the pod from the STS")
+
+	if err := p.queue.Publish(data); err != nil {
+		logger.WithError(err).Error("Error, failed to publish")
 		return err
 	}
+	metrics.IncQueueEnqueued(p.queueName)
 
 	return nil
 }
@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	rmqConnectionsKey  = "rmq::connections"                                         // rmqConnectionsKey set of the rmq connections.
+	rmqReadyTemplate   = "rmq::queue::[{queue}]::ready"                             // rmqReadyTemplate list of the deliveries ready.
+	rmqRejectTemplate  = "rmq::queue::[{queue}]::rejected"                          // rmqRejectTemplate list of the deliveries rejected.
+	rmqUnackedTemplate = "rmq::connection::{connection}::queue::[{queue}]::unacked" // rmqUnackedTemplate list of the deliveries being consumed.
+)
+
+// QueueState represents the items of an rmq queue grouped by their state.
+type QueueState struct {
+	Name        string      `json:"name"`        // Name of the queue.
+	Ready       []QueueTask `json:"ready"`       // Ready deliveries waiting to be consumed.
+	Unacked     []QueueTask `json:"unacked"`     // Unacked deliveries being consumed.
+	Rejected    []QueueTask `json:"rejected"`    // Rejected deliveries waiting to be returned to the queue.
+	Delayed     []QueueTask `json:"delayed"`     // Delayed tasks waiting to be published again.
+	DeadLetters []QueueTask `json:"deadLetters"` // DeadLetters tasks which reached the max number of attempts.
+}
+
+// Counts returns the number of items of the queue by state.
+func (s QueueState) Counts() map[string]int64 {
+	return map[string]int64{
+		"ready":    int64(len(s.Ready)),
+		"unacked":  int64(len(s.Unacked)),
+		"rejected": int64(len(s.Rejected)),
+		"delayed":  int64(len(s.Delayed)),
+		"dead":     int64(len(s.DeadLetters)),
+	}
+}
+
+// queueKey returns the key of the queue replacing the placeholders of the template.
+func queueKey(template, queueName, connection string) string {
+	key := strings.Replace(template, "{queue}", queueName, 1)
+	return strings.Replace(key, "{connection}", connection, 1)
+}
+
+// GetQueueState returns the items of the rmq queue, including the unacked deliveries of every connection,
+// the tasks waiting to be retried and the dead letters.
+func GetQueueState(r *RedisClient, ctx context.Context, queueName string) (QueueState, error) {
+	state := QueueState{Name: queueName}
+
+	var err error
+	state.Ready, err = listQueueTasks(r, ctx, queueKey(rmqReadyTemplate, queueName, ""))
+	if err != nil {
+		return state, err
+	}
+	state.Rejected, err = listQueueTasks(r, ctx, queueKey(rmqRejectTemplate, queueName, ""))
+	if err != nil {
+		return state, err
+	}
+
+	connections, err := r.client.SMembers(ctx, rmqConnectionsKey).Result()
+	if err != nil {
+		return state, err
+	}
+	state.Unacked = []QueueTask{}
+	for _, connection := range connections {
+		tasks, err := listQueueTasks(r, ctx, queueKey(rmqUnackedTemplate, queueName, connection))
+		if err != nil {
+			return state, err
+		}
+		state.Unacked = append(state.Unacked, tasks...)
+	}
+
+	state.Delayed, err = GetDelayedTasks(r, ctx, queueName)
+	if err != nil {
+		return state, err
+	}
+	state.DeadLetters, err = GetDeadLetters(r, ctx, queueName)
+	if err != nil {
+		return state, err
+	}
+
+	return state, nil
+}
+
+// listQueueTasks returns the tasks stored in the list of the key.
+func listQueueTasks(r *RedisClient, ctx context.Context, key string) ([]QueueTask, error) {
+	payloads, err := r.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]QueueTask, 0, len(payloads))
+	for _, payload := range payloads {
+		tasks = append(tasks, ParseQueueTask(payload))
+	}
+	return tasks, nil
+}
@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// TestGetQueueState checks that the items of the queue are grouped by their state.
+func TestGetQueueState(t *testing.T) {
+	s := miniredis.RunT(t)
+	red := NewRedisClient(s.Addr(), "", 0)
+	ctx := context.Background()
+
+	connection, err := OpenQueueConnection("test", red, nil)
+	if err != nil {
+		t.Fatalf("OpenQueueConnection() error = %v", err)
+	}
+	producer, err := NewProducer(connection, "k8s")
+	if err != nil {
+		t.Fatalf("NewProducer() error = %v", err)
+	}
+	if err := producer.Publish("da-bridge-1"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	// a delivery being processed by another replica and a rejected one
+	s.SAdd(rmqConnectionsKey, "other")
+	s.Lpush(queueKey(rmqUnackedTemplate, "k8s", "other"), QueueTask{NodeName: "da-full-1-0", Attempts: 1}.Payload())
+	s.Lpush(queueKey(rmqRejectTemplate, "k8s", ""), "da-full-2-0")
+
+	if err := ScheduleRetry(red, ctx, "k8s", QueueTask{NodeName: "da-light-1-0", Attempts: 2}, 0); err != nil {
+		t.Fatalf("ScheduleRetry() error = %v", err)
+	}
+	if err := AddDeadLetter(red, ctx, "k8s", QueueTask{NodeName: "da-light-2-0", Attempts: 5}); err != nil {
+		t.Fatalf("AddDeadLetter() error = %v", err)
+	}
+
+	state, err := GetQueueState(red, ctx, "k8s")
+	if err != nil {
+		t.Fatalf("GetQueueState() error = %v", err)
+	}
+
+	want := QueueState{
+		Name:        "k8s",
+		Ready:       []QueueTask{{NodeName: "da-bridge-1-0"}},
+		Unacked:     []QueueTask{{NodeName: "da-full-1-0", Attempts: 1}},
+		Rejected:    []QueueTask{{NodeName: "da-full-2-0"}},
+		Delayed:     []QueueTask{{NodeName: "da-light-1-0", Attempts: 2}},
+		DeadLetters: []QueueTask{{NodeName: "da-light-2-0", Attempts: 5}},
+	}
+	if !reflect.DeepEqual(state, want) {
+		t.Errorf("GetQueueState() = %+v, want %+v", state, want)
+	}
+
+	wantCounts := map[string]int64{"ready": 1, "unacked": 1, "rejected": 1, "delayed": 1, "dead": 1}
+	if counts := state.Counts(); !reflect.DeepEqual(counts, wantCounts) {
+		t.Errorf("Counts() = %v, want %v", counts, wantCounts)
+	}
+}
@@ -5,7 +5,8 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
-	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/pkg/logging"
 )
 
 type RedisClient struct {
@@ -20,14 +21,41 @@ func NewRedisClient(addr string, password string, db int) *RedisClient {
 		DB:       db,       // use DB.
 		Protocol: 3,        // specify 2 for RESP 2 or 3 for RESP 3.
 	})
+	client.AddHook(metricsHook{})
 	return &RedisClient{client}
 }
 
+// Ping checks that the connection with the DB is healthy.
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Close closes the connections of the pool, the client cannot be used afterward.
+func (r *RedisClient) Close() error {
+	return r.client.Close()
+}
+
+// PoolStats returns the stats of the connection pool.
+func (r *RedisClient) PoolStats() *redis.PoolStats {
+	return r.client.PoolStats()
+}
+
 // SetKey receives a key - value and stores it into the DB.
 func (r *RedisClient) SetKey(ctx context.Context, key, value string, expiration time.Duration) error {
 	return r.client.Set(ctx, key, value, expiration).Err()
 }
 
+// SwapKey stores the key - value and returns the previous value, it returns false if the key didn't exist.
+func (r *RedisClient) SwapKey(ctx context.Context, key, value string, expiration time.Duration) (string, bool, error) {
+	previous, err := r.client.SetArgs(ctx, key, value, redis.SetArgs{TTL: expiration, Get: true}).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return previous, true, nil
+}
+
 // GetKey receives a key and tries to return it from the DB.
 func (r *RedisClient) GetKey(ctx context.Context, key string) (string, error) {
 	result, err := r.client.Get(ctx, key).Result()
@@ -39,17 +67,30 @@ func (r *RedisClient) GetKey(ctx context.Context, key string) (string, error) {
 	return result, nil
 }
 
-// GetAllKeys returns all the keys from the DB.
+// DelKey receives a key and removes it from the DB, it returns true if the key existed.
+func (r *RedisClient) DelKey(ctx context.Context, key string) (bool, error) {
+	deleted, err := r.client.Del(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
+
+// GetAllKeys returns all the node records from the DB, the internal keys like the locks or the queues are skipped.
 func (r *RedisClient) GetAllKeys(ctx context.Context) (map[string]string, error) {
 	result := make(map[string]string)
 	iter, err := r.client.Keys(ctx, "*").Result()
 	if err != nil {
-		log.Error("Error getting the key ", err)
+		logging.FromContext(ctx).WithError(err).Error("Error getting the keys")
 	}
 	for _, s := range iter {
+		// the internal keys are not strings, so they can't be read with GET
+		if !IsNodeKey(s) {
+			continue
+		}
 		value, err := r.GetKey(ctx, s)
 		if err != nil {
-			log.Error("Error getting the key ", s, ": ", err)
+			logging.FromContext(ctx).WithField("key", s).WithError(err).Error("Error getting the key")
 		} else {
 			result[s] = value
 		}
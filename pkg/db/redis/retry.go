@@ -0,0 +1,177 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/adjust/rmq/v5"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/pkg/logging"
+)
+
+// QueueTask represents a node in a queue, including the number of attempts to process it.
+type QueueTask struct {
+	NodeName  string    `json:"nodeName"`            // NodeName name of the node.
+	Attempts  int       `json:"attempts"`            // Attempts number of times the node has been processed.
+	LastError string    `json:"lastError,omitempty"` // LastError error of the last attempt.
+	UpdatedAt time.Time `json:"updatedAt"`           // UpdatedAt last time the task was processed.
+	JobID     string    `json:"jobID,omitempty"`     // JobID id of the task, it's the same in all its attempts.
+	RequestID string    `json:"requestID,omitempty"` // RequestID id of the HTTP request which published the task.
+}
+
+// ParseQueueTask returns the task of the payload, the payloads published by the producer only have the node name.
+func ParseQueueTask(payload string) QueueTask {
+	var task QueueTask
+	if err := json.Unmarshal([]byte(payload), &task); err != nil || task.NodeName == "" {
+		return QueueTask{NodeName: payload}
+	}
+	return task
+}
+
+// Payload returns the task encoded to publish it in the queue.
+func (t QueueTask) Payload() string {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		// it can't fail, the task only has strings, ints and time
+		return t.NodeName
+	}
+	return string(payload)
+}
+
+// delayedKey returns the key of the sorted set where the tasks wait to be published again.
+func delayedKey(queueName string) string {
+	return "torch::queue::" + queueName + "::delayed"
+}
+
+// deadLetterKey returns the key of the hash which keeps the tasks that reached the max number of attempts.
+func deadLetterKey(queueName string) string {
+	return "torch::queue::" + queueName + "::dead"
+}
+
+// Logger returns the logger of the context with the node and the ids of the task.
+func (t QueueTask) Logger(ctx context.Context) *log.Entry {
+	if t.RequestID != "" {
+		ctx = logging.WithRequestID(ctx, t.RequestID)
+	}
+	if t.JobID != "" {
+		ctx = logging.WithJobID(ctx, t.JobID)
+	}
+	return logging.ForNodeName(ctx, t.NodeName)
+}
+
+// ScheduleRetry keeps the task in Redis to publish it again in the queue after the delay.
+func ScheduleRetry(r *RedisClient, ctx context.Context, queueName string, task QueueTask, delay time.Duration) error {
+	task.Logger(ctx).WithFields(log.Fields{
+		"queue":    queueName,
+		"delay":    delay,
+		"attempts": task.Attempts,
+	}).Info("Scheduling the retry of the node")
+	return r.client.ZAdd(ctx, delayedKey(queueName), redis.Z{
+		Score:  float64(time.Now().Add(delay).UnixMilli()),
+		Member: task.Payload(),
+	}).Err()
+}
+
+// GetDelayedTasks returns the tasks waiting to be published again in the queue.
+func GetDelayedTasks(r *RedisClient, ctx context.Context, queueName string) ([]QueueTask, error) {
+	payloads, err := r.client.ZRange(ctx, delayedKey(queueName), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]QueueTask, 0, len(payloads))
+	for _, payload := range payloads {
+		tasks = append(tasks, ParseQueueTask(payload))
+	}
+	return tasks, nil
+}
+
+// PublishDueRetries publishes in the queue the tasks whose delay has expired, it returns the number of tasks published.
+// The tasks are removed before publishing them, so only one replica publishes each task, and they are scheduled again
+// with the same due time if they can't be published.
+func PublishDueRetries(r *RedisClient, ctx context.Context, queue rmq.Queue, queueName string) (int, error) {
+	key := delayedKey(queueName)
+	due, err := r.client.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, z := range due {
+		payload, _ := z.Member.(string)
+		removed, err := r.client.ZRem(ctx, key, payload).Result()
+		if err != nil {
+			return published, err
+		}
+		// another replica has already published it
+		if removed == 0 {
+			continue
+		}
+
+		if err := queue.Publish(payload); err != nil {
+			logger := ParseQueueTask(payload).Logger(ctx).WithField("queue", queueName)
+			logger.WithError(err).Error("Error publishing the retry of the task")
+			// keep the task, so it's published in the next run
+			if zErr := r.client.ZAdd(context.WithoutCancel(ctx), key, z).Err(); zErr != nil {
+				logger.WithError(zErr).Error("Error scheduling again the retry of the task, the task is lost")
+			}
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+// AddDeadLetter keeps the task which reached the max number of attempts, so it can be inspected and replayed.
+func AddDeadLetter(r *RedisClient, ctx context.Context, queueName string, task QueueTask) error {
+	task.Logger(ctx).WithFields(log.Fields{
+		"queue":     queueName,
+		"attempts":  task.Attempts,
+		"lastError": task.LastError,
+	}).Error("Adding the node to the dead letters")
+	return r.client.HSet(ctx, deadLetterKey(queueName), task.NodeName, task.Payload()).Err()
+}
+
+// GetDeadLetters returns the tasks which reached the max number of attempts, sorted by node name.
+func GetDeadLetters(r *RedisClient, ctx context.Context, queueName string) ([]QueueTask, error) {
+	values, err := r.client.HGetAll(ctx, deadLetterKey(queueName)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]QueueTask, 0, len(values))
+	for _, payload := range values {
+		tasks = append(tasks, ParseQueueTask(payload))
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].NodeName < tasks[j].NodeName
+	})
+
+	return tasks, nil
+}
+
+// ReplayDeadLetter removes the task from the dead letters and schedules it to be published again in the queue,
+// resetting the attempts. It returns false if the node is not in the dead letters.
+func ReplayDeadLetter(r *RedisClient, ctx context.Context, queueName, nodeName string) (bool, error) {
+	deleted, err := r.client.HDel(ctx, deadLetterKey(queueName), nodeName).Result()
+	if err != nil {
+		return false, err
+	}
+	if deleted == 0 {
+		return false, nil
+	}
+
+	// the replay is a new job of the request which asked for it
+	task := QueueTask{NodeName: nodeName, RequestID: logging.RequestID(ctx)}
+	task.Logger(ctx).WithField("queue", queueName).Info("Replaying the node from the dead letters")
+	return true, ScheduleRetry(r, ctx, queueName, task, 0)
+}
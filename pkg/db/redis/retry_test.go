@@ -0,0 +1,152 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adjust/rmq/v5"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// failingQueue is a queue which can't publish the payloads.
+type failingQueue struct {
+	rmq.Queue
+}
+
+// Publish returns always an error.
+func (failingQueue) Publish(...string) error {
+	return errors.New("error: publish failed")
+}
+
+func TestParseQueueTask(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    QueueTask
+	}{
+		{
+			name:    "Case 1: Payload published by the producer",
+			payload: "da-bridge-1-0",
+			want:    QueueTask{NodeName: "da-bridge-1-0"},
+		},
+		{
+			name:    "Case 2: Payload of a retry",
+			payload: `{"nodeName":"da-bridge-1-0","attempts":2,"lastError":"error"}`,
+			want:    QueueTask{NodeName: "da-bridge-1-0", Attempts: 2, LastError: "error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseQueueTask(tt.payload); got != tt.want {
+				t.Errorf("ParseQueueTask() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRetriesAndDeadLetters checks that the retries are published once they are due, and the dead letters replayed.
+func TestRetriesAndDeadLetters(t *testing.T) {
+	s := miniredis.RunT(t)
+	red := NewRedisClient(s.Addr(), "", 0)
+	ctx := context.Background()
+
+	connection, err := OpenQueueConnection("test", red, nil)
+	if err != nil {
+		t.Fatalf("OpenQueueConnection() error = %v", err)
+	}
+	queue, err := connection.OpenQueue("k8s")
+	if err != nil {
+		t.Fatalf("OpenQueue() error = %v", err)
+	}
+
+	if err := ScheduleRetry(red, ctx, "k8s", QueueTask{NodeName: "da-full-1-0", Attempts: 1}, 0); err != nil {
+		t.Fatalf("ScheduleRetry() error = %v", err)
+	}
+	if err := ScheduleRetry(red, ctx, "k8s", QueueTask{NodeName: "da-full-2-0", Attempts: 1}, time.Hour); err != nil {
+		t.Fatalf("ScheduleRetry() error = %v", err)
+	}
+
+	published, err := PublishDueRetries(red, ctx, queue, "k8s")
+	if err != nil || published != 1 {
+		t.Fatalf("PublishDueRetries() = %d, error = %v, want 1", published, err)
+	}
+	payloads, err := queue.Drain(1)
+	if err != nil || len(payloads) != 1 || ParseQueueTask(payloads[0]).NodeName != "da-full-1-0" {
+		t.Fatalf("Drain() = %v, error = %v, want da-full-1-0", payloads, err)
+	}
+	delayed, err := GetDelayedTasks(red, ctx, "k8s")
+	if err != nil || len(delayed) != 1 || delayed[0].NodeName != "da-full-2-0" {
+		t.Fatalf("GetDelayedTasks() = %v, error = %v, want da-full-2-0", delayed, err)
+	}
+
+	if err := AddDeadLetter(red, ctx, "k8s", QueueTask{NodeName: "da-full-3-0", Attempts: 5}); err != nil {
+		t.Fatalf("AddDeadLetter() error = %v", err)
+	}
+	dead, err := GetDeadLetters(red, ctx, "k8s")
+	if err != nil || len(dead) != 1 || dead[0].Attempts != 5 {
+		t.Fatalf("GetDeadLetters() = %v, error = %v", dead, err)
+	}
+
+	if found, err := ReplayDeadLetter(red, ctx, "k8s", "da-full-3-0"); err != nil || !found {
+		t.Fatalf("ReplayDeadLetter() = %v, error = %v", found, err)
+	}
+	if found, err := ReplayDeadLetter(red, ctx, "k8s", "da-full-3-0"); err != nil || found {
+		t.Fatalf("ReplayDeadLetter() = %v, error = %v, want not found", found, err)
+	}
+
+	published, err = PublishDueRetries(red, ctx, queue, "k8s")
+	if err != nil || published != 1 {
+		t.Fatalf("PublishDueRetries() = %d, error = %v, want 1", published, err)
+	}
+	payloads, err = queue.Drain(1)
+	if err != nil || len(payloads) != 1 || ParseQueueTask(payloads[0]) != (QueueTask{NodeName: "da-full-3-0"}) {
+		t.Fatalf("Drain() = %v, error = %v, want da-full-3-0 without attempts", payloads, err)
+	}
+
+	<-connection.StopAllConsuming()
+}
+
+// TestPublishDueRetriesKeepsFailedTasks checks that the tasks which can't be published are scheduled again.
+func TestPublishDueRetriesKeepsFailedTasks(t *testing.T) {
+	s := miniredis.RunT(t)
+	red := NewRedisClient(s.Addr(), "", 0)
+	ctx := context.Background()
+
+	connection, err := OpenQueueConnection("test", red, nil)
+	if err != nil {
+		t.Fatalf("OpenQueueConnection() error = %v", err)
+	}
+	queue, err := connection.OpenQueue("k8s")
+	if err != nil {
+		t.Fatalf("OpenQueue() error = %v", err)
+	}
+
+	task := QueueTask{NodeName: "da-full-1-0", Attempts: 1}
+	if err := ScheduleRetry(red, ctx, "k8s", task, 0); err != nil {
+		t.Fatalf("ScheduleRetry() error = %v", err)
+	}
+	score, err := s.ZScore(delayedKey("k8s"), task.Payload())
+	if err != nil {
+		t.Fatalf("ZScore() error = %v", err)
+	}
+
+	if published, err := PublishDueRetries(red, ctx, failingQueue{queue}, "k8s"); err == nil || published != 0 {
+		t.Fatalf("PublishDueRetries() = %d, error = %v, want an error", published, err)
+	}
+	if got, err := s.ZScore(delayedKey("k8s"), task.Payload()); err != nil || got != score {
+		t.Fatalf("ZScore() = %v, error = %v, want %v", got, err, score)
+	}
+
+	published, err := PublishDueRetries(red, ctx, queue, "k8s")
+	if err != nil || published != 1 {
+		t.Fatalf("PublishDueRetries() = %d, error = %v, want 1", published, err)
+	}
+	payloads, err := queue.Drain(1)
+	if err != nil || len(payloads) != 1 || ParseQueueTask(payloads[0]).NodeName != "da-full-1-0" {
+		t.Fatalf("Drain() = %v, error = %v, want da-full-1-0", payloads, err)
+	}
+
+	<-connection.StopAllConsuming()
+}
@@ -4,15 +4,25 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 
 	"github.com/jrmanes/torch/config"
 	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/logging"
+	"github.com/jrmanes/torch/pkg/metrics"
 	"github.com/jrmanes/torch/pkg/nodes"
+	"github.com/jrmanes/torch/pkg/topology"
 )
 
 const (
@@ -40,8 +50,14 @@ type Response struct {
 	Errors interface{} `json:"errors,omitempty"`
 }
 
-// GetConfig handles the HTTP GET request for retrieving the config as JSON.
-func GetConfig(w http.ResponseWriter, cfg config.MutualPeersConfig) {
+// GetConfig handles the HTTP GET request for retrieving the config as JSON, the peers generated by the rules are only
+// included using the query param expanded=true.
+func GetConfig(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig) {
+	expanded, _ := strconv.ParseBool(r.URL.Query().Get("expanded"))
+	if !expanded {
+		cfg = cfg.Unexpanded()
+	}
+
 	// Generate the response, including the configuration
 	resp := Response{
 		Status: http.StatusOK,
@@ -53,10 +69,9 @@ func GetConfig(w http.ResponseWriter, cfg config.MutualPeersConfig) {
 }
 
 // List handles the HTTP GET request for retrieving the list of matching pods as JSON.
-func List(w http.ResponseWriter) {
-	red := redis.InitRedisConfig()
+func List(w http.ResponseWriter, r *http.Request, red *redis.RedisClient) {
 	// Create a new context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeoutDuration)
 
 	// Make sure to call the cancel function to release resources when you're done
 	defer cancel()
@@ -78,7 +93,7 @@ func List(w http.ResponseWriter) {
 }
 
 // GetNoId handles the HTTP GET request for retrieving the list of matching pods as JSON.
-func GetNoId(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig) {
+func GetNoId(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig, red *redis.RedisClient) {
 	nodeName := mux.Vars(r)["nodeName"]
 	if nodeName == "" {
 		log.Error("User param nodeName is empty", http.StatusNotFound)
@@ -86,20 +101,20 @@ func GetNoId(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfi
 	}
 
 	// verify that the node is in the config
-	ok, peer := nodes.ValidateNode(nodeName, cfg)
+	ok, _ := nodes.ValidateNode(nodeName, cfg)
 	if !ok {
 		log.Error(errorMsg, "Pod doesn't exists in the config")
 		resp := Response{
 			Status: http.StatusNotFound,
-			Body:   peer.NodeName,
+			Body:   nodeName,
 			Errors: errors.New("error: Pod doesn't exists in the config"),
 		}
 		ReturnResponse(resp, w)
+		return
 	}
 
-	red := redis.InitRedisConfig()
 	// Create a new context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeoutDuration)
 
 	// Make sure to call the cancel function to release resources when you're done
 	defer cancel()
@@ -127,18 +142,11 @@ func GetNoId(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfi
 		}
 	}
 
-	// Generate the response, including the configuration
-	resp = Response{
-		Status: http.StatusOK,
-		Body:   nodeIDs,
-		Errors: nil,
-	}
-
 	ReturnResponse(resp, w)
 }
 
 // Gen handles the HTTP POST request to create the files with their ids.
-func Gen(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig) {
+func Gen(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig, red *redis.RedisClient) {
 	var body RequestBody
 	var resp Response
 
@@ -151,6 +159,7 @@ func Gen(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig) {
 			Errors: err,
 		}
 		ReturnResponse(resp, w)
+		return
 	}
 
 	// verify that the node is in the config
@@ -163,53 +172,78 @@ func Gen(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig) {
 			Errors: errors.New("error: Pod doesn't exists in the config"),
 		}
 		ReturnResponse(resp, w)
+		return
 	}
 
-	log.Info("Pod to setup: ", "[", peer.NodeName, "]")
+	// with dryRun=true, Torch returns the actions it would run without running them
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	if dryRun {
+		logging.ForNode(r.Context(), peer).Info("Pod to plan")
+		ReturnResponse(PlanNode(cfg, peer, red), w)
+		return
+	}
 
-	resp = ConfigureNode(cfg, peer, err)
+	logging.ForNode(r.Context(), peer).Info("Pod to setup")
+
+	// the configuration is part of the trace of the request, but it's not cancelled if the client disconnects
+	resp = ConfigureNode(context.WithoutCancel(r.Context()), cfg, peer, red, err)
 
 	ReturnResponse(resp, w)
 }
 
+// PlanNode returns the actions that Torch would run to configure the node received, without running them.
+func PlanNode(cfg config.MutualPeersConfig, peer config.Peer, red *redis.RedisClient) Response {
+	plan, err := nodes.PlanNode(peer, cfg, red)
+	if err != nil {
+		log.Error(errorMsg, err)
+		return Response{
+			Status: http.StatusBadRequest,
+			Body:   plan,
+			Errors: err.Error(),
+		}
+	}
+
+	return Response{
+		Status: http.StatusOK,
+		Body:   plan,
+		Errors: nil,
+	}
+}
+
+// ConfigureNode configures the node received using the connections specified in the config, it's traced as part of
+// the span in the context.
 func ConfigureNode(
+	ctx context.Context,
 	cfg config.MutualPeersConfig,
 	peer config.Peer,
+	red *redis.RedisClient,
 	err error,
 ) Response {
-	// Get the default values in case we need
-	switch peer.NodeType {
-	case "da":
-		peer = nodes.SetDaNodeDefault(peer)
-	case "consensus":
-		peer = nodes.SetConsNodeDefault(peer)
-	}
-
-	// check if the node uses env var
-	if peer.ConnectsAsEnvVar {
-		log.Info("Pod: [", peer.NodeName, "] ", "uses env var to connect.")
-		// configure the env vars for the node
-		err = nodes.SetupNodesEnvVarAndConnections(peer, cfg)
-		if err != nil {
-			log.Error(errorMsg, err)
-			return Response{
-				Status: http.StatusInternalServerError,
-				Body:   peer.NodeName,
-				Errors: err,
-			}
+	ctx, span := metrics.StartSpan(ctx, "ConfigureNode", attribute.String("node_name", peer.NodeName))
+	defer func() { metrics.EndSpan(span, err) }()
+
+	nodeType, err := nodes.ResolveNodeType(peer)
+	if err != nil {
+		logging.ForNode(ctx, peer).WithError(err).Error("Error resolving the type of the node")
+		return Response{
+			Status: http.StatusBadRequest,
+			Body:   peer.NodeName,
+			Errors: err.Error(),
 		}
 	}
+	span.SetAttributes(attribute.String("node_type", nodeType.Name()))
 
-	// Configure DA Nodes with which are not using env var
-	if peer.NodeType == "da" && !peer.ConnectsAsEnvVar {
-		err := nodes.SetupDANodeWithConnections(peer)
-		if err != nil {
-			log.Error(errorMsg, err)
-			return Response{
-				Status: http.StatusInternalServerError,
-				Body:   peer.NodeName,
-				Errors: err,
-			}
+	// Get the default values in case we need
+	peer = nodeType.SetDefaults(peer)
+
+	// deliver the connections of the node, using env vars or multi addresses depending on the node type
+	err = nodeType.Connect(ctx, peer, cfg, red)
+	if err != nil {
+		logging.ForNode(ctx, peer).WithError(err).Error("Error configuring the node")
+		return Response{
+			Status: http.StatusInternalServerError,
+			Body:   peer.NodeName,
+			Errors: err,
 		}
 	}
 
@@ -221,7 +255,443 @@ func ConfigureNode(
 	}
 }
 
-// ReturnResponse assert function to write the response.
+// DeleteNode handles the HTTP DELETE request to remove the node from the DB and its metric.
+// Using the query param reconfigure=true, Torch configures again the peers that connect to the node.
+func DeleteNode(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig, red *redis.RedisClient) {
+	nodeName := mux.Vars(r)["nodeName"]
+	reconfigure, _ := strconv.ParseBool(r.URL.Query().Get("reconfigure"))
+
+	// the keys of the queues and the locks are not nodes
+	if !redis.IsNodeKey(nodeName) {
+		ReturnResponse(Response{
+			Status: http.StatusBadRequest,
+			Body:   nodeName,
+			Errors: "[ERROR] Invalid node name [" + nodeName + "]",
+		}, w)
+		return
+	}
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	deleted, err := redis.DeleteNodeId(nodeName, red, ctx)
+	if err != nil {
+		logging.ForNodeName(ctx, nodeName).WithError(err).Error("Error deleting the node")
+		ReturnResponse(Response{
+			Status: http.StatusInternalServerError,
+			Body:   nodeName,
+			Errors: err.Error(),
+		}, w)
+		return
+	}
+	if !deleted {
+		ReturnResponse(Response{
+			Status: http.StatusNotFound,
+			Body:   nodeName,
+			Errors: "[ERROR] Node [" + nodeName + "] not found",
+		}, w)
+		return
+	}
+
+	// remove the stale series
+	metrics.UnregisterMetric(nodeName)
+	metrics.UnregisterConsensusNodeMetric(nodeName)
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   nodeName,
+		Errors: nil,
+	}
+	if reconfigure {
+		resp = reconfigureDependentPeers(context.WithoutCancel(r.Context()), nodeName, cfg, red)
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// RegenerateNode handles the HTTP POST request to generate the node id again and overwrite the one stored.
+// Using the query param reconfigure=true, Torch configures again the peers that connect to the node.
+func RegenerateNode(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig, red *redis.RedisClient) {
+	nodeName := mux.Vars(r)["nodeName"]
+	reconfigure, _ := strconv.ParseBool(r.URL.Query().Get("reconfigure"))
+
+	// verify that the node is in the config
+	ok, peer := nodes.ValidateNode(nodeName, cfg)
+	if !ok {
+		log.Error(errorMsg, "Pod doesn't exists in the config")
+		ReturnResponse(Response{
+			Status: http.StatusNotFound,
+			Body:   nodeName,
+			Errors: "error: Pod doesn't exists in the config",
+		}, w)
+		return
+	}
+	nodeType, err := nodes.ResolveNodeType(peer)
+	if err != nil || nodeType.Family() != nodes.FamilyDA {
+		ReturnResponse(Response{
+			Status: http.StatusBadRequest,
+			Body:   nodeName,
+			Errors: "error: only the ids of the DA nodes can be generated",
+		}, w)
+		return
+	}
+	peer = nodeType.SetDefaults(peer)
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	ma, err := nodes.RegenerateNodeId(peer, red, ctx)
+	if err != nil {
+		logging.ForNode(ctx, peer).WithError(err).Error("Error regenerating the node")
+		ReturnResponse(Response{
+			Status: http.StatusInternalServerError,
+			Body:   nodeName,
+			Errors: err.Error(),
+		}, w)
+		return
+	}
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   map[string]string{nodeName: ma},
+		Errors: nil,
+	}
+	if reconfigure {
+		ctx := context.WithoutCancel(r.Context())
+		if dependents := reconfigureDependentPeers(ctx, nodeName, cfg, red); dependents.Status != http.StatusOK {
+			resp = dependents
+		}
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// reconfigureDependentPeers runs the configuration of the peers which connect to the node received.
+func reconfigureDependentPeers(ctx context.Context, nodeName string, cfg config.MutualPeersConfig, red *redis.RedisClient) Response {
+	var configured []string
+	for _, peer := range nodes.DependentPeers(nodeName, cfg) {
+		logging.ForNode(ctx, peer).WithField("connection", nodeName).Info("Reconfiguring the peer which connects to the node")
+		resp := ConfigureNode(ctx, cfg, peer, red, nil)
+		if resp.Status != http.StatusOK {
+			return resp
+		}
+		configured = append(configured, peer.NodeName)
+	}
+
+	return Response{
+		Status: http.StatusOK,
+		Body:   map[string]interface{}{"node": nodeName, "reconfigured": configured},
+		Errors: nil,
+	}
+}
+
+// Export handles the HTTP GET request to dump all the node records stored in the DB as JSON or YAML.
+func Export(w http.ResponseWriter, r *http.Request, red *redis.RedisClient) {
+	format := getFormat(r)
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	nodeIDs, err := redis.ExportNodes(red, ctx)
+	if err != nil {
+		log.Error("Error exporting the nodes: ", err)
+		ReturnResponse(Response{
+			Status: http.StatusInternalServerError,
+			Body:   "",
+			Errors: err.Error(),
+		}, w)
+		return
+	}
+
+	data, err := redis.EncodeNodes(nodeIDs, format)
+	if err != nil {
+		log.Error("Error encoding the nodes: ", err)
+		ReturnResponse(Response{
+			Status: http.StatusBadRequest,
+			Body:   "",
+			Errors: err.Error(),
+		}, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/"+format)
+	w.Header().Set("Content-Disposition", "attachment; filename=torch-nodes."+format)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		log.Error("Error writing response:", err)
+	}
+}
+
+// Import handles the HTTP POST request to load the node records received in the body into the DB.
+// Using the query param dryRun=true, the DB is not modified and Torch returns what it would do.
+func Import(w http.ResponseWriter, r *http.Request, red *redis.RedisClient) {
+	format := getFormat(r)
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Error("Error reading the request body: ", err)
+		ReturnResponse(Response{
+			Status: http.StatusBadRequest,
+			Body:   "",
+			Errors: err.Error(),
+		}, w)
+		return
+	}
+
+	nodeIDs, err := redis.DecodeNodes(data, format)
+	if err != nil {
+		log.Error("Error decoding the nodes: ", err)
+		ReturnResponse(Response{
+			Status: http.StatusBadRequest,
+			Body:   "",
+			Errors: err.Error(),
+		}, w)
+		return
+	}
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	result, err := redis.ImportNodes(red, ctx, nodeIDs, dryRun)
+	if err != nil {
+		log.Error("Error importing the nodes: ", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, redis.ErrInvalidNodeName) {
+			status = http.StatusBadRequest
+		}
+		ReturnResponse(Response{
+			Status: status,
+			Body:   result,
+			Errors: err.Error(),
+		}, w)
+		return
+	}
+
+	ReturnResponse(Response{
+		Status: http.StatusOK,
+		Body:   result,
+		Errors: nil,
+	}, w)
+}
+
+// getFormat returns the format requested by the user, either with the query param format or the Content-Type header.
+func getFormat(r *http.Request) string {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "yml" {
+		format = redis.FormatYAML
+	}
+	if format == "" && strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+		format = redis.FormatYAML
+	}
+	if format == "" {
+		format = redis.FormatJSON
+	}
+	return format
+}
+
+// DeadLetters handles the HTTP GET request to list the nodes which reached the max number of attempts in the queue.
+func DeadLetters(w http.ResponseWriter, r *http.Request, red *redis.RedisClient) {
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	tasks, err := redis.GetDeadLetters(red, ctx, queueK8SNodes)
+	if err != nil {
+		log.Error("Error getting the dead letters: ", err)
+		ReturnResponse(Response{
+			Status: http.StatusInternalServerError,
+			Body:   "",
+			Errors: err.Error(),
+		}, w)
+		return
+	}
+
+	ReturnResponse(Response{
+		Status: http.StatusOK,
+		Body:   tasks,
+		Errors: nil,
+	}, w)
+}
+
+// Queues handles the HTTP GET request to list the nodes of the in-memory queue and the k8s queue by their state.
+func Queues(w http.ResponseWriter, r *http.Request, red *redis.RedisClient) {
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	k8sQueue, err := redis.GetQueueState(red, ctx, queueK8SNodes)
+	if err != nil {
+		log.Error("Error getting the state of the queue [", queueK8SNodes, "]: ", err)
+		ReturnResponse(Response{
+			Status: http.StatusInternalServerError,
+			Body:   "",
+			Errors: err.Error(),
+		}, w)
+		return
+	}
+
+	ReturnResponse(Response{
+		Status: http.StatusOK,
+		Body: map[string]interface{}{
+			nodes.TaskQueueName: nodes.TaskQueueSnapshot(),
+			queueK8SNodes:       k8sQueue,
+		},
+		Errors: nil,
+	}, w)
+}
+
+// ReplayDeadLetter handles the HTTP POST request to add the node from the dead letters to the queue again.
+func ReplayDeadLetter(w http.ResponseWriter, r *http.Request, red *redis.RedisClient) {
+	nodeName := mux.Vars(r)["nodeName"]
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	found, err := redis.ReplayDeadLetter(red, ctx, queueK8SNodes, nodeName)
+	if err != nil {
+		logging.ForNodeName(ctx, nodeName).WithError(err).Error("Error replaying the node")
+		ReturnResponse(Response{
+			Status: http.StatusInternalServerError,
+			Body:   nodeName,
+			Errors: err.Error(),
+		}, w)
+		return
+	}
+	if !found {
+		ReturnResponse(Response{
+			Status: http.StatusNotFound,
+			Body:   nodeName,
+			Errors: "[ERROR] Node [" + nodeName + "] not found in the dead letters",
+		}, w)
+		return
+	}
+
+	ReturnResponse(Response{
+		Status: http.StatusOK,
+		Body:   nodeName,
+		Errors: nil,
+	}, w)
+}
+
+// Reconcile handles the HTTP GET request to list the last result of the reconciliation of each node.
+func Reconcile(w http.ResponseWriter) {
+	ReturnResponse(Response{
+		Status: http.StatusOK,
+		Body:   nodes.ReconcileSnapshot(),
+		Errors: nil,
+	}, w)
+}
+
+// Connectivity handles the HTTP GET request to list the expected peers of each node which are connected.
+func Connectivity(w http.ResponseWriter) {
+	ReturnResponse(Response{
+		Status: http.StatusOK,
+		Body:   nodes.ConnectivitySnapshot(),
+		Errors: nil,
+	}, w)
+}
+
+// Health handles the HTTP GET request to check the connection with the DB, including the stats of the pool.
+func Health(w http.ResponseWriter, r *http.Request, red *redis.RedisClient) {
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   red.PoolStats(),
+		Errors: nil,
+	}
+
+	if err := red.Ping(ctx); err != nil {
+		log.Error("Error connecting to Redis: ", err)
+		resp.Status = http.StatusServiceUnavailable
+		resp.Errors = err.Error()
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// Topology handles the HTTP GET request for retrieving the nodes and their connections, with the values stored in
+// the DB, as JSON or as Graphviz DOT using the query param format=dot.
+func Topology(w http.ResponseWriter, r *http.Request, cfg config.MutualPeersConfig, red *redis.RedisClient) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format != "" && format != topology.FormatJSON && format != topology.FormatDOT {
+		ReturnResponse(Response{
+			Status: http.StatusBadRequest,
+			Body:   "",
+			Errors: "unsupported format: [" + format + "]",
+		}, w)
+		return
+	}
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	// the topology is returned even if the DB is not available, with the status unknown
+	var errs interface{}
+	stored, err := redis.ExportNodes(red, ctx)
+	if err != nil {
+		log.Error("Error getting the nodes from the DB: ", err)
+		errs = err.Error()
+	}
+
+	export := topology.Build(cfg).Export(stored)
+
+	if format == topology.FormatDOT {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(topology.EncodeDOT(export)); err != nil {
+			log.Error("Error writing response:", err)
+		}
+		return
+	}
+
+	ReturnResponse(Response{
+		Status: http.StatusOK,
+		Body:   export,
+		Errors: errs,
+	}, w)
+}
+
+// TopologyCheck handles the HTTP GET request for checking the connections between the nodes of the config.
+func TopologyCheck(w http.ResponseWriter, cfg config.MutualPeersConfig) {
+	resp := Response{
+		Status: http.StatusOK,
+		Body:   topology.Build(cfg).Check(),
+		Errors: nil,
+	}
+
+	ReturnResponse(resp, w)
+}
+
+// ReturnResponse assert function to write the response, its status is used as the HTTP code, 200 if it is not set.
 func ReturnResponse(resp Response, w http.ResponseWriter) {
 	jsonData, err := json.Marshal(resp)
 	if err != nil {
@@ -230,19 +700,105 @@ func ReturnResponse(resp Response, w http.ResponseWriter) {
 		return
 	}
 
+	// the responses which don't specify the status are successful
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
 	// write all the headers
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 	_, err = w.Write(jsonData)
 	if err != nil {
 		log.Error("Error writing response:", err)
 	}
 }
 
+// RequestID is a middleware function that keeps the id of the request in its context and returns it in the
+// X-Request-ID header, the id received from the client is reused, otherwise, a new one is generated.
+func RequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(logging.RequestIDHeader)
+		if id == "" {
+			id = logging.NewID()
+		}
+		w.Header().Set(logging.RequestIDHeader, id)
+		handler.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
 // LogRequest is a middleware function that logs the incoming request.
 func LogRequest(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Info(r.Method, " ", r.URL.Path)
+		logging.FromContext(r.Context()).Info(r.Method, " ", r.URL.Path)
 		handler.ServeHTTP(w, r)
 	})
 }
+
+// statusRecorder keeps the status written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int  // status written by the handler, 200 if it's not written explicitly.
+	wroteHeader bool // wroteHeader true once the status has been sent.
+}
+
+// WriteHeader keeps the first status written, which is the one the client receives, and writes it.
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the body, the status is 200 if it was not written before.
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// MetricsRequest is a middleware function that records the requests by route, method and status.
+func MetricsRequest(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(rec, r)
+
+		metrics.ObserveHTTPRequest(routeTemplate(r), r.Method, rec.status, time.Since(start))
+	})
+}
+
+// TraceRequest is a middleware function that starts the span of the request, continuing the trace of the client if
+// it's propagated in the headers.
+func TraceRequest(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := metrics.StartSpan(ctx, r.Method+" "+route,
+			attribute.String("http.method", r.Method),
+			attribute.String("http.route", route),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.status_code", rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// routeTemplate returns the path template of the route of the request, so the paths with a node name share the same
+// value, or unknown if the request didn't match any route.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/mux"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+)
+
+// TestReturnResponseStatus checks that the status of the response is written, so the middlewares record it.
+func TestReturnResponseStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		resp       Response
+		wantStatus int
+	}{
+		{
+			name:       "Case 1: Successful response",
+			resp:       Response{Status: http.StatusOK, Body: "ok"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Case 2: Response without status",
+			resp:       Response{Body: "ok"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Case 3: Node not found",
+			resp:       Response{Status: http.StatusNotFound, Errors: "node not found"},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Case 4: Internal error",
+			resp:       Response{Status: http.StatusInternalServerError, Errors: "error"},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recorded int
+			r := mux.NewRouter()
+			r.HandleFunc("/api/v1/noId/{nodeName}", func(w http.ResponseWriter, r *http.Request) {
+				ReturnResponse(tt.resp, w)
+			})
+			// the same status recorder used by the metrics and the traces
+			r.Use(func(handler http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+					handler.ServeHTTP(rec, r)
+					recorded = rec.status
+				})
+			})
+			r.Use(MetricsRequest)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/noId/da-bridge-1-0", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("ReturnResponse() code = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if recorded != tt.wantStatus {
+				t.Errorf("statusRecorder.status = %d, want %d", recorded, tt.wantStatus)
+			}
+
+			var got Response
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if got.Status != tt.resp.Status {
+				t.Errorf("Response.Status = %d, want %d", got.Status, tt.resp.Status)
+			}
+		})
+	}
+}
+
+// TestDeleteNodeInternalKeys checks that the keys of the queues and the locks can't be removed using the API.
+func TestDeleteNodeInternalKeys(t *testing.T) {
+	s := miniredis.RunT(t)
+	red := redis.NewRedisClient(s.Addr(), "", 0)
+
+	s.Lpush("rmq::queues", "k8s")
+	if err := s.Set("torch::lock::da-full-1-0", "token"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := s.Set("da-full-1-0", "12D3KooW"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/v1/nodes/{nodeName}", func(w http.ResponseWriter, r *http.Request) {
+		DeleteNode(w, r, config.MutualPeersConfig{}, red)
+	}).Methods("DELETE")
+
+	tests := []struct {
+		name       string
+		nodeName   string
+		wantStatus int
+	}{
+		{
+			name:       "Case 1: Queues of rmq",
+			nodeName:   "rmq::queues",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Case 2: Lock of a node",
+			nodeName:   "torch::lock::da-full-1-0",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Case 3: Node",
+			nodeName:   "da-full-1-0",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/nodes/"+tt.nodeName, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("DeleteNode() code = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	if !s.Exists("rmq::queues") || !s.Exists("torch::lock::da-full-1-0") {
+		t.Errorf("the internal keys were removed")
+	}
+	if s.Exists("da-full-1-0") {
+		t.Errorf("the node was not removed")
+	}
+}
+
+// TestHandlersWriteOneResponse checks that the handlers stop after writing an error, so the client gets only one
+// status and one body.
+func TestHandlersWriteOneResponse(t *testing.T) {
+	s := miniredis.RunT(t)
+	red := redis.NewRedisClient(s.Addr(), "", 0)
+	cfg := config.MutualPeersConfig{
+		MutualPeers: []*config.MutualPeer{
+			{Peers: []config.Peer{{NodeName: "da-bridge-1-0", NodeType: "da"}}},
+		},
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/v1/noId/{nodeName}", func(w http.ResponseWriter, r *http.Request) {
+		GetNoId(w, r, cfg, red)
+	}).Methods("GET")
+	r.HandleFunc("/api/v1/gen", func(w http.ResponseWriter, r *http.Request) {
+		Gen(w, r, cfg, red)
+	}).Methods("POST")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "Case 1: Node not in the config",
+			method:     http.MethodGet,
+			path:       "/api/v1/noId/da-full-9-0",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Case 2: Node without id",
+			method:     http.MethodGet,
+			path:       "/api/v1/noId/da-bridge-1-0",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Case 3: Invalid body",
+			method:     http.MethodPost,
+			path:       "/api/v1/gen",
+			body:       "{",
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "Case 4: Node to generate not in the config",
+			method:     http.MethodPost,
+			path:       "/api/v1/gen",
+			body:       `{"pod_name":"da-full-9-0"}`,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Errorf("code = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			// a second response would be concatenated after the first one
+			dec := json.NewDecoder(w.Body)
+			var got Response
+			if err := dec.Decode(&got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("Response.Status = %d, want %d", got.Status, tt.wantStatus)
+			}
+			if dec.More() {
+				t.Errorf("more than one response written")
+			}
+		})
+	}
+}
+
+// TestStatusRecorderKeepsFirstStatus checks that the status recorded is the one sent to the client.
+func TestStatusRecorderKeepsFirstStatus(t *testing.T) {
+	rec := &statusRecorder{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+	rec.WriteHeader(http.StatusNotFound)
+	rec.WriteHeader(http.StatusOK)
+	if rec.status != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.status, http.StatusNotFound)
+	}
+
+	rec = &statusRecorder{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+	_, _ = rec.Write([]byte("ok"))
+	rec.WriteHeader(http.StatusInternalServerError)
+	if rec.status != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.status, http.StatusOK)
+	}
+}
@@ -7,33 +7,93 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
 )
 
-func Router(r *mux.Router, cfg config.MutualPeersConfig) *mux.Router {
+// Router registers all the paths, the handlers use the config and the Redis client received.
+func Router(r *mux.Router, cfg config.MutualPeersConfig, red *redis.RedisClient) *mux.Router {
+	r.Use(RequestID)
 	r.Use(LogRequest)
+	r.Use(MetricsRequest)
+	r.Use(TraceRequest)
 
 	// group the current version to /api/v1
 	s := r.PathPrefix("/api/v1").Subrouter()
 
 	// get config
 	s.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
-		GetConfig(w, cfg)
+		GetConfig(w, r, cfg)
 	}).Methods("GET")
 
 	// get nodes
 	s.HandleFunc("/list", func(w http.ResponseWriter, r *http.Request) {
-		List(w)
+		List(w, r, red)
 	}).Methods("GET")
 	// get node details by node name
 	s.HandleFunc("/noId/{nodeName}", func(w http.ResponseWriter, r *http.Request) {
-		GetNoId(w, r, cfg)
+		GetNoId(w, r, cfg, red)
 	}).Methods("GET")
 
+	// delete the node from the DB
+	s.HandleFunc("/nodes/{nodeName}", func(w http.ResponseWriter, r *http.Request) {
+		DeleteNode(w, r, cfg, red)
+	}).Methods("DELETE")
+	// generate the node id again
+	s.HandleFunc("/nodes/{nodeName}/regenerate", func(w http.ResponseWriter, r *http.Request) {
+		RegenerateNode(w, r, cfg, red)
+	}).Methods("POST")
+
 	// generate
 	s.HandleFunc("/gen", func(w http.ResponseWriter, r *http.Request) {
-		Gen(w, r, cfg)
+		Gen(w, r, cfg, red)
+	}).Methods("POST")
+
+	// export the nodes stored in the DB
+	s.HandleFunc("/export", func(w http.ResponseWriter, r *http.Request) {
+		Export(w, r, red)
+	}).Methods("GET")
+	// import nodes into the DB
+	s.HandleFunc("/import", func(w http.ResponseWriter, r *http.Request) {
+		Import(w, r, red)
+	}).Methods("POST")
+
+	// nodes waiting in the queues
+	s.HandleFunc("/queues", func(w http.ResponseWriter, r *http.Request) {
+		Queues(w, r, red)
+	}).Methods("GET")
+	// nodes which reached the max number of attempts in the queue
+	s.HandleFunc("/deadletters", func(w http.ResponseWriter, r *http.Request) {
+		DeadLetters(w, r, red)
+	}).Methods("GET")
+	// add the node to the queue again
+	s.HandleFunc("/deadletters/{nodeName}/replay", func(w http.ResponseWriter, r *http.Request) {
+		ReplayDeadLetter(w, r, red)
 	}).Methods("POST")
 
+	// status of the reconciliation of the nodes
+	s.HandleFunc("/reconcile", func(w http.ResponseWriter, r *http.Request) {
+		Reconcile(w)
+	}).Methods("GET")
+
+	// expected peers of the nodes which are connected
+	s.HandleFunc("/connectivity", func(w http.ResponseWriter, r *http.Request) {
+		Connectivity(w)
+	}).Methods("GET")
+
+	// get the nodes and their connections
+	s.HandleFunc("/topology", func(w http.ResponseWriter, r *http.Request) {
+		Topology(w, r, cfg, red)
+	}).Methods("GET")
+	// check the connections between the nodes of the config
+	s.HandleFunc("/topology/check", func(w http.ResponseWriter, r *http.Request) {
+		TopologyCheck(w, cfg)
+	}).Methods("GET")
+
+	// health of the connections
+	s.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		Health(w, r, red)
+	}).Methods("GET")
+
 	// metrics
 	r.Handle("/metrics", promhttp.Handler())
 
@@ -2,11 +2,10 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
-	"os/signal"
 	"strconv"
-	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -18,9 +17,11 @@ import (
 	"github.com/jrmanes/torch/pkg/k8s"
 	"github.com/jrmanes/torch/pkg/metrics"
 	"github.com/jrmanes/torch/pkg/nodes"
+	"github.com/jrmanes/torch/pkg/topology"
 )
 
 const (
+	queueK8SNodes        = "k8s"            // queueK8SNodes name of the queue to process the nodes from the StatefulSets.
 	retryInterval        = 10 * time.Second // retryInterval Retry interval in seconds to generate the consensus metric.
 	hashMetricGenTimeout = 5 * time.Minute  // hashMetricGenTimeout specify the max time to retry to generate the metric.
 )
@@ -44,94 +45,207 @@ func GetHttpPort() string {
 }
 
 // Run initializes the HTTP server, registers metrics for all nodes in the configuration,
-// and starts the server.
-func Run(cfg config.MutualPeersConfig) {
+// and starts the server and the workers. It blocks until the context is done or one of them fails, then it stops
+// taking new requests and nodes, waits for the ones in-flight, and closes the connections.
+func Run(ctx context.Context, cfg config.MutualPeersConfig) error {
 	// Get http port
 	httpPort := GetHttpPort()
 
+	// Initialize the config and register the metrics for all nodes
+	shutdownTelemetry, err := metrics.InitConfig(ctx)
+	if err != nil {
+		log.Errorf("Error initializing metrics: %v", err)
+		return err
+	}
+	defer func() {
+		// Create a new context with a timeout
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+		defer cancel()
+
+		// flush the metrics and the spans which were not exported yet
+		if err := shutdownTelemetry(shutdownCtx); err != nil {
+			log.Error("Error stopping the telemetry exporters: ", err)
+		}
+		log.Info("Telemetry exporters stopped")
+	}()
+
+	// send the Kubernetes events which are pending before exiting
+	defer k8s.ShutdownEvents()
+
+	// Check the connections between the nodes, the issues are only reported, the nodes are configured anyway
+	LogTopologyCheck(cfg)
+
+	// Create the Redis client, it keeps a pool of connections that is shared by the handlers and the workers
+	red := redis.InitRedisConfig()
+	defer func() {
+		if err := red.Close(); err != nil {
+			log.Error("Error closing the Redis client: ", err)
+		}
+		log.Info("Redis client closed")
+	}()
+
+	pingCtx, pingCancel := context.WithTimeout(ctx, timeoutDuration)
+	if err := red.Ping(pingCtx); err != nil {
+		log.Error("Error connecting to Redis: ", err)
+	}
+	pingCancel()
+
+	// Lock the nodes using Redis, so the replicas don't configure the same node at the same time
+	nodes.SetLocker(redis.NewRedisLocker(red, nodes.LockLease))
+
+	err = metrics.WithMetricsRedisPool(func() metrics.RedisPoolStats {
+		stats := red.PoolStats()
+		return metrics.RedisPoolStats{
+			Hits:       stats.Hits,
+			Misses:     stats.Misses,
+			Timeouts:   stats.Timeouts,
+			TotalConns: stats.TotalConns,
+			IdleConns:  stats.IdleConns,
+			StaleConns: stats.StaleConns,
+		}
+	})
+	if err != nil {
+		log.Error("Error registering the Redis pool metrics: ", err)
+	}
+
+	// All the goroutines are stopped when the context is done, or when one of them returns an error.
+	eg, ctx := errgroup.WithContext(ctx)
+
+	// Subscribe to the changes of the node records, so the metrics are kept in sync with the other replicas.
+	// It subscribes again when Redis is not available, so it only returns when the context is done.
+	eg.Go(func() error {
+		redis.WatchNodeEvents(ctx, red, func(event redis.NodeEvent) {
+			nodes.HandleNodeEvent(cfg, event)
+		})
+		return nil
+	})
+
 	// Set up the HTTP server
 	r := mux.NewRouter()
 	// Get the routers
-	r = Router(r, cfg)
+	r = Router(r, cfg, red)
 	// Use the middleware
 	r.Use(LogRequest)
 
-	// Initialize the config and register the metrics for all nodes
-	err := metrics.InitConfig()
-	if err != nil {
-		log.Errorf("Error initializing metrics: %v", err)
-		return
-	}
-
 	// Create the server
 	server := &http.Server{
 		Addr:    ":" + httpPort,
 		Handler: r,
 	}
 
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	eg.Go(func() error {
+		log.Info("Server Started...")
+		log.Info("Listening on port: " + httpPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Errorf("Listening on: %v", err)
+			return err
 		}
-	}()
-	log.Info("Server Started...")
-	log.Info("Listening on port: " + httpPort)
+		return nil
+	})
+	eg.Go(func() error {
+		<-ctx.Done()
+		log.Info("Server Stopped")
+
+		// wait for the requests in-flight
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), nodes.DrainTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Errorf("Server Shutdown Failed: %v", err)
+			return err
+		}
+		return nil
+	})
 
 	// check if Torch has to generate the metric or not, we invoke this function async to continue the execution flow.
-	go BackgroundGenerateHashMetric(cfg)
-	go BackgroundGenerateLBMetric()
+	eg.Go(func() error {
+		BackgroundGenerateHashMetric(ctx, cfg)
+		return nil
+	})
+	eg.Go(func() error {
+		BackgroundGenerateLBMetric(ctx)
+		return nil
+	})
 
 	// Initialize the goroutine to check the nodes in the queue.
 	log.Info("Initializing queues to process the nodes...")
-	// Create a new context without timeout as we want to keep this goroutine running forever, if we specify a timeout,
-	// it will be canceled at some point.c
-	go func() {
-		go nodes.ProcessTaskQueue()
-	}()
+	eg.Go(func() error {
+		nodes.ProcessTaskQueue(ctx, red)
+		return nil
+	})
+
+	// Initialize the goroutine to configure again the nodes which drifted from the config.
+	eg.Go(func() error {
+		nodes.RunReconciler(ctx, cfg, red)
+		return nil
+	})
+
+	// Initialize the goroutine to verify that the nodes are connected to their peers.
+	eg.Go(func() error {
+		nodes.RunConnectivityVerifier(ctx, cfg, red)
+		return nil
+	})
 
-	log.Info("Initializing goroutine to watch over the StatefulSets...")
-	// Initialize a goroutine to watch for changes in StatefulSets in the namespace.
-	go func() {
-		// Call the WatchStatefulSets function and capture any potential error.
-		err := k8s.WatchStatefulSets()
+	// Initialize the goroutine to record the heights of the nodes.
+	eg.Go(func() error {
+		nodes.RunHeightPoller(ctx, cfg)
+		return nil
+	})
+
+	// Open the queue connection, it's shared by the producer and the consumer, and uses the same Redis client.
+	errChan := make(chan error, 10)
+	go nodes.LogQueueErrors(errChan)
+
+	connection, err := redis.OpenQueueConnection("torch", red, errChan)
+	if err != nil {
+		log.Error("Error opening the queue connection, the StatefulSets won't be processed: ", err)
+	} else {
+		defer func() {
+			<-connection.StopAllConsuming() // make sure the heartbeat is stopped if the consumer didn't start
+			log.Info("Queue connection closed")
+		}()
+
+		producer, err := redis.NewProducer(connection, queueK8SNodes)
 		if err != nil {
-			// Log an error message if WatchStatefulSets encounters an error.
-			log.Error("Error in WatchStatefulSets: ", err)
+			log.Error("Error creating the producer: ", err)
+		} else {
+			log.Info("Initializing goroutine to watch over the StatefulSets...")
+			// Initialize a goroutine to watch for changes in StatefulSets in the namespace.
+			eg.Go(func() error {
+				err := k8s.WatchStatefulSets(ctx, producer)
+				if err != nil {
+					// Log an error message if WatchStatefulSets encounters an error.
+					log.Error("Error in WatchStatefulSets: ", err)
+				}
+				return err
+			})
 		}
-	}()
 
-	// Initialize the goroutine to add a watcher to the StatefulSets in the namespace.
-	log.Info("Initializing Redis consumer")
-	go func() {
-		nodes.ConsumerInit("k8s")
-	}()
+		// Initialize the goroutine to consume the nodes added to the queue.
+		log.Info("Initializing Redis consumer")
+		eg.Go(func() error {
+			return nodes.ConsumerInit(ctx, queueK8SNodes, red, connection)
+		})
+	}
 
 	// Check if we already have some multi addresses in the DB and expose them, there might be a situation where Torch
 	// get restarted, and we already have the nodes IDs, so we can expose them.
-	err = RegisterMetrics(cfg)
+	err = RegisterMetrics(cfg, red)
 	if err != nil {
 		log.Error("Couldn't generate the metrics...", err)
 	}
 
-	<-done
-	log.Info("Server Stopped")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer func() {
-		cancel()
-	}()
-
-	if err := server.Shutdown(ctx); err != nil {
-		log.Errorf("Server Shutdown Failed: %v", err)
+	if err := eg.Wait(); err != nil {
+		log.Error("Server Exited with error: ", err)
+		return err
 	}
 	log.Info("Server Exited Properly")
+
+	return nil
 }
 
-// BackgroundGenerateLBMetric initializes a goroutine to generate the load_balancer metric.
-func BackgroundGenerateLBMetric() {
+// BackgroundGenerateLBMetric generates the load_balancer metric and keeps it updated until the context is done.
+func BackgroundGenerateLBMetric(ctx context.Context) {
 	log.Info("Initializing goroutine to generate the metric: load_balancer ")
 
 	// Retrieve the list of Load Balancers
@@ -142,28 +256,25 @@ func BackgroundGenerateLBMetric() {
 
 	// Start watching for changes to the services in a separate goroutine
 	done := make(chan error)
-	go k8s.WatchServices(done)
+	go k8s.WatchServices(ctx, done)
 
-	// Handle errors from WatchServices
-	for {
-		select {
-		case err := <-done:
-			if err != nil {
-				log.Error("Error in WatchServices: ", err)
-			}
+	// Handle errors from WatchServices, the channel is closed when it stops
+	for err := range done {
+		if err != nil {
+			log.Error("Error in WatchServices: ", err)
 		}
 	}
 }
 
 // BackgroundGenerateHashMetric checks if the consensusNode field is defined in the config to generate the metric from the Genesis Hash data.
-func BackgroundGenerateHashMetric(cfg config.MutualPeersConfig) {
+func BackgroundGenerateHashMetric(ctx context.Context, cfg config.MutualPeersConfig) {
 	log.Info("BackgroundGenerateHashMetric...")
 
 	if cfg.MutualPeers[0].ConsensusNode != "" {
 		log.Info("Initializing goroutine to generate the metric: hash ")
 
 		// Create an errgroup with a context
-		eg, ctx := errgroup.WithContext(context.Background())
+		eg, ctx := errgroup.WithContext(ctx)
 
 		// Run the WatchHashMetric function in a separate goroutine
 		eg.Go(func() error {
@@ -208,7 +319,7 @@ func watchMetricsWithRetry(cfg config.MutualPeersConfig, ctx context.Context) er
 			return ctx.Err()
 		default:
 			hashMetricsErr := GenerateHashMetrics(cfg)
-			consensusMetricsErr := ConsNodesIDs(cfg)
+			consensusMetricsErr := ConsNodesIDs(ctx, cfg)
 
 			// Check if both metrics generation are successful
 			if hashMetricsErr == nil && consensusMetricsErr == nil {
@@ -274,13 +385,18 @@ func GenerateHashMetrics(cfg config.MutualPeersConfig) error {
 }
 
 // ConsNodesIDs generates the metric with the consensus nodes ids.
-func ConsNodesIDs(cfg config.MutualPeersConfig) error {
+func ConsNodesIDs(ctx context.Context, cfg config.MutualPeersConfig) error {
 	log.Info("Generating the metric for the consensus nodes ids...")
 
 	for _, mutualPeer := range cfg.MutualPeers {
 		for _, peer := range mutualPeer.Peers {
-			if peer.NodeType == "consensus" {
-				consNodeId, err := nodes.ConsensusNodesIDs(peer.ServiceName)
+			nodeType, err := nodes.ResolveNodeType(peer)
+			if err != nil {
+				log.Error("Error resolving the type of the node [", peer.NodeName, "]: ", err)
+				continue
+			}
+			if nodeType.Family() == nodes.FamilyConsensus {
+				consNodeId, err := nodeType.FetchID(ctx, peer)
 				if err != nil {
 					log.Error("Error getting consensus node ID for service [", peer.ServiceName, "]: ", err)
 					return err
@@ -302,9 +418,26 @@ func ConsNodesIDs(cfg config.MutualPeersConfig) error {
 	return nil
 }
 
+// LogTopologyCheck checks the topology of the config and logs the issues found.
+func LogTopologyCheck(cfg config.MutualPeersConfig) {
+	report := topology.Build(cfg).Check()
+	log.Info("Topology checked: [", report.Nodes, "] nodes, [", report.Edges, "] connections, [", len(report.Issues), "] issues")
+
+	for _, issue := range report.Issues {
+		msg := "Topology " + issue.Kind + " in node [" + issue.Node + "]"
+		if issue.Target != "" {
+			msg += " -> [" + issue.Target + "]"
+		}
+		if issue.Severity == topology.SeverityError {
+			log.Error(msg, ": ", issue.Message)
+		} else {
+			log.Warn(msg, ": ", issue.Message)
+		}
+	}
+}
+
 // RegisterMetrics generates and registers the metrics for all nodes in case they already exist in the DB.
-func RegisterMetrics(cfg config.MutualPeersConfig) error {
-	red := redis.InitRedisConfig()
+func RegisterMetrics(cfg config.MutualPeersConfig, red *redis.RedisClient) error {
 	// Create a new context with a timeout
 	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
 
@@ -316,6 +449,11 @@ func RegisterMetrics(cfg config.MutualPeersConfig) error {
 	// Adding nodes from config to register the initial metrics
 	for _, n := range cfg.MutualPeers {
 		for _, no := range n.Peers {
+			// the consensus nodes store their id instead of a multi address
+			if t, err := nodes.ResolveNodeType(no); err != nil || t.Family() != nodes.FamilyDA {
+				continue
+			}
+
 			// checking the node in the DB first
 			ma, err := redis.CheckIfNodeExistsInDB(red, ctx, no.NodeName)
 			if err != nil {
@@ -2,25 +2,43 @@ package k8s
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/jrmanes/torch/pkg/logging"
+	"github.com/jrmanes/torch/pkg/metrics"
 )
 
-// RunRemoteCommand executes a remote command on the specified node.
-func RunRemoteCommand(nodeName, container, namespace string, command []string) (string, error) {
+// RunRemoteCommand executes a remote command on the specified node, it's traced as part of the span in the context.
+func RunRemoteCommand(ctx context.Context, nodeName, container, namespace string, command []string) (string, error) {
+	ctx, span := metrics.StartSpan(ctx, "RunRemoteCommand",
+		attribute.String("pod", nodeName),
+		attribute.String("container", container),
+		attribute.String("namespace", namespace),
+	)
+	logger := logging.FromContext(ctx).WithFields(log.Fields{
+		logging.FieldNode:      nodeName,
+		logging.FieldNamespace: namespace,
+		"container":            container,
+	})
+
 	clusterConfig, err := rest.InClusterConfig()
 	if err != nil {
-		log.Error("Error: ", err.Error())
+		logger.WithError(err).Error("Error getting the in-cluster config")
 	}
 	// creates the client
 	client, err := kubernetes.NewForConfig(clusterConfig)
 	if err != nil {
-		log.Fatalf("Error: %v", err.Error())
+		logger.WithError(err).Fatal("Error creating the Kubernetes client")
 	}
 
 	// Create a request to execute the command on the specified node.
@@ -39,32 +57,36 @@ func RunRemoteCommand(nodeName, container, namespace string, command []string) (
 		}, scheme.ParameterCodec)
 
 	// Execute the remote command.
-	output, err := executeCommand(clusterConfig, req)
+	start := time.Now()
+	output, err := executeCommand(ctx, clusterConfig, req)
+	metrics.ObserveExec(nodeName, container, time.Since(start), err)
+	metrics.EndSpan(span, err)
 	if err != nil {
-		log.Error("failed to execute remote command: ", err)
+		logger.WithError(err).Error("Failed to execute remote command")
 	}
 
 	return output, nil
 }
 
-// executeCommand executes the remote command using the provided configuration, request, and output writer.
-func executeCommand(config *rest.Config, req *rest.Request) (string, error) {
+// executeCommand executes the remote command using the provided configuration, request, and output writer, the errors
+// are logged by the caller with the fields of the node.
+func executeCommand(ctx context.Context, config *rest.Config, req *rest.Request) (string, error) {
 	executor, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
 	if err != nil {
-		log.Error("failed to create SPDY executor: ", err)
+		return "", fmt.Errorf("failed to create SPDY executor: %w", err)
 	}
 
 	// Prepare the standard I/O streams.
 	var stdout, stderr bytes.Buffer
 
 	// Execute the remote command and capture the output.
-	err = executor.Stream(remotecommand.StreamOptions{
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
 		Stdout: &stdout,
 		Stderr: &stderr,
 		Tty:    false,
 	})
 	if err != nil {
-		log.Error("failed to execute command stream: ", err)
+		return stdout.String(), fmt.Errorf("failed to execute command stream: %w", err)
 	}
 
 	return stdout.String(), nil
@@ -0,0 +1,183 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/jrmanes/torch/pkg/logging"
+)
+
+const (
+	ReasonNodeIDGenerated = "NodeIDGenerated" // ReasonNodeIDGenerated the id of the node has been generated and stored.
+	ReasonPeersWritten    = "PeersWritten"    // ReasonPeersWritten the connections of the node have been written.
+	ReasonRetryScheduled  = "RetryScheduled"  // ReasonRetryScheduled the node failed and it will be processed again.
+	ReasonGaveUp          = "GaveUp"          // ReasonGaveUp the node reached the max number of retries.
+
+	eventsComponent = "torch"          // eventsComponent source of the events.
+	eventTimeout    = 10 * time.Second // eventTimeout max time to get the object of the event.
+)
+
+var (
+	eventsOnce    sync.Once
+	eventsMu      sync.RWMutex                  // eventsMu protects the recorder.
+	eventRecorder record.EventRecorder          // eventRecorder records the events, nil if they are disabled.
+	eventsStop    func()                        // eventsStop stops the broadcaster of the events.
+	eventObject   = getEventObject              // eventObject returns the object where the event of the node is recorded.
+	podOrdinal    = regexp.MustCompile(`-\d+$`) // podOrdinal suffix of the pods created by a StatefulSet.
+
+	// errNoEventObject returned when the node name is not a pod, a StatefulSet or a Service with pods.
+	errNoEventObject = errors.New("error: the node doesn't have a pod where the event can be recorded")
+)
+
+// recorder returns the recorder of the events, it's created the first time. If Torch is not running in a cluster,
+// it returns nil and the events are disabled.
+func recorder() record.EventRecorder {
+	eventsOnce.Do(func() {
+		clientSet, _, err := newClient()
+		if err != nil {
+			log.WithError(err).Warn("Error creating the events recorder, the Kubernetes events are disabled")
+			return
+		}
+
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientSet.CoreV1().Events("")})
+
+		eventsMu.Lock()
+		defer eventsMu.Unlock()
+		eventRecorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventsComponent})
+		eventsStop = broadcaster.Shutdown
+	})
+
+	eventsMu.RLock()
+	defer eventsMu.RUnlock()
+	return eventRecorder
+}
+
+// SetEventRecorder replaces the recorder used to record the events, nil disables them.
+func SetEventRecorder(r record.EventRecorder) {
+	eventsOnce.Do(func() {})
+
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	eventRecorder = r
+}
+
+// ShutdownEvents stops the broadcaster of the events, the events pending are sent before.
+func ShutdownEvents() {
+	eventsMu.RLock()
+	defer eventsMu.RUnlock()
+	if eventsStop != nil {
+		eventsStop()
+	}
+}
+
+// RecordNodeEvent records the event on the pod of the node, or on its StatefulSet if the pod doesn't exist, so
+// kubectl describe shows what Torch did to the node. It doesn't block, and the errors are only logged.
+func RecordNodeEvent(ctx context.Context, nodeName, namespace, eventType, reason, messageFmt string, args ...interface{}) {
+	if namespace == "" {
+		namespace = GetCurrentNamespace()
+	}
+	message := fmt.Sprintf(messageFmt, args...)
+
+	go func() {
+		// Create a new context with a timeout
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), eventTimeout)
+		defer cancel()
+
+		recordNodeEvent(ctx, nodeName, namespace, eventType, reason, message)
+	}()
+}
+
+// recordNodeEvent gets the object of the node and records the event on it.
+func recordNodeEvent(ctx context.Context, nodeName, namespace, eventType, reason, message string) {
+	r := recorder()
+	if r == nil {
+		return
+	}
+
+	logger := logging.ForNodeName(ctx, nodeName).WithFields(log.Fields{
+		logging.FieldNamespace: namespace,
+		"reason":               reason,
+	})
+	obj, err := eventObject(ctx, nodeName, namespace)
+	if errors.Is(err, errNoEventObject) {
+		logger.Debug("The node is not a pod, the event is not recorded")
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Warn("Error getting the object of the node, the event is not recorded")
+		return
+	}
+
+	r.Event(obj, eventType, reason, message)
+}
+
+// getEventObject returns the object where the event of the node is recorded, using the API of the cluster.
+func getEventObject(ctx context.Context, nodeName, namespace string) (runtime.Object, error) {
+	clientSet, _, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	return findEventObject(ctx, clientSet, nodeName, namespace)
+}
+
+// findEventObject returns the pod of the node, or the StatefulSet which creates it if the pod doesn't exist. The
+// nodes referenced by their Service, like the consensus nodes, use the first pod of the Service. It returns
+// errNoEventObject if none of them exist.
+func findEventObject(ctx context.Context, clientSet kubernetes.Interface, nodeName, namespace string) (runtime.Object, error) {
+	pod, err := clientSet.CoreV1().Pods(namespace).Get(ctx, nodeName, metav1.GetOptions{})
+	if err == nil {
+		return pod, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	if podOrdinal.MatchString(nodeName) {
+		statefulSetName := podOrdinal.ReplaceAllString(nodeName, "")
+		statefulSet, err := clientSet.AppsV1().StatefulSets(namespace).Get(ctx, statefulSetName, metav1.GetOptions{})
+		if err == nil {
+			return statefulSet, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	svc, err := clientSet.CoreV1().Services(namespace).Get(ctx, nodeName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, errNoEventObject
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(svc.Spec.Selector) == 0 {
+		return nil, errNoEventObject
+	}
+
+	pods, err := clientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, errNoEventObject
+	}
+	return &pods.Items[0], nil
+}
@@ -0,0 +1,165 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+// TestRecordNodeEvent checks that the event is recorded on the object of the node, and that it's skipped when the
+// object can't be found or the events are disabled.
+func TestRecordNodeEvent(t *testing.T) {
+	defer func(lookup func(context.Context, string, string) (runtime.Object, error)) {
+		eventObject = lookup
+	}(eventObject)
+	defer SetEventRecorder(nil)
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "da-full-1-0", Namespace: "celestia"}}
+
+	tests := []struct {
+		name      string
+		disabled  bool
+		lookupErr error
+		want      string
+	}{
+		{
+			name: "Case 1: The event is recorded on the pod",
+			want: "Normal PeersWritten Torch wrote the trusted peers",
+		},
+		{
+			name:      "Case 2: The object of the node doesn't exist",
+			lookupErr: errors.New("not found"),
+		},
+		{
+			name:     "Case 3: The events are disabled",
+			disabled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := record.NewFakeRecorder(1)
+			SetEventRecorder(fake)
+			if tt.disabled {
+				SetEventRecorder(nil)
+			}
+
+			var lookups int
+			eventObject = func(ctx context.Context, nodeName, namespace string) (runtime.Object, error) {
+				lookups++
+				if nodeName != pod.Name || namespace != pod.Namespace {
+					t.Errorf("eventObject() = %s/%s, want %s/%s", namespace, nodeName, pod.Namespace, pod.Name)
+				}
+				return pod, tt.lookupErr
+			}
+
+			recordNodeEvent(context.Background(), pod.Name, pod.Namespace, corev1.EventTypeNormal, ReasonPeersWritten,
+				"Torch wrote the trusted peers")
+
+			if tt.disabled && lookups != 0 {
+				t.Errorf("eventObject() called %d times with the events disabled", lookups)
+			}
+
+			select {
+			case got := <-fake.Events:
+				if got != tt.want {
+					t.Errorf("event = %q, want %q", got, tt.want)
+				}
+			default:
+				if tt.want != "" {
+					t.Errorf("event not recorded, want %q", tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestFindEventObject checks that the event is recorded on the pod of the node, its StatefulSet, or the pod of its
+// Service, and that the references which are not pods are skipped.
+func TestFindEventObject(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "da-full-1-0", Namespace: "celestia"}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "da-bridge-1", Namespace: "celestia"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      "consensus-validator-1-0",
+			Namespace: "celestia",
+			Labels:    map[string]string{"app": "consensus-validator-1"},
+		}},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "consensus-validator-1", Namespace: "celestia"},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "consensus-validator-1"}},
+		},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "consensus-external", Namespace: "celestia"}},
+	)
+
+	tests := []struct {
+		name     string
+		nodeName string
+		wantKind string
+		wantName string
+		wantErr  error
+	}{
+		{
+			name:     "Case 1: Pod of the node",
+			nodeName: "da-full-1-0",
+			wantKind: "Pod",
+			wantName: "da-full-1-0",
+		},
+		{
+			name:     "Case 2: StatefulSet of the pod which doesn't exist",
+			nodeName: "da-bridge-1-0",
+			wantKind: "StatefulSet",
+			wantName: "da-bridge-1",
+		},
+		{
+			name:     "Case 3: Pod of the Service",
+			nodeName: "consensus-validator-1",
+			wantKind: "Pod",
+			wantName: "consensus-validator-1-0",
+		},
+		{
+			name:     "Case 4: Service without selector",
+			nodeName: "consensus-external",
+			wantErr:  errNoEventObject,
+		},
+		{
+			name:     "Case 5: Reference which is not in the cluster",
+			nodeName: "consensus-full-1",
+			wantErr:  errNoEventObject,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findEventObject(context.Background(), clientSet, tt.nodeName, "celestia")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("findEventObject() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			obj, ok := got.(metav1.Object)
+			if !ok {
+				t.Fatalf("findEventObject() = %T, want a Kubernetes object", got)
+			}
+			kind := ""
+			switch got.(type) {
+			case *corev1.Pod:
+				kind = "Pod"
+			case *appsv1.StatefulSet:
+				kind = "StatefulSet"
+			}
+			if kind != tt.wantKind || obj.GetName() != tt.wantName {
+				t.Errorf("findEventObject() = %s %s, want %s %s", kind, obj.GetName(), tt.wantKind, tt.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+
+	"github.com/jrmanes/torch/pkg/logging"
+)
+
+// newClient returns the clientSet and its config using the Service Account of Torch.
+func newClient() (*kubernetes.Clientset, *rest.Config, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		log.WithError(err).Error("Failed to get in-cluster config")
+		return nil, nil, err
+	}
+
+	clientSet, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		log.WithError(err).Error("Failed to create Kubernetes clientSet")
+		return nil, nil, err
+	}
+
+	return clientSet, cfg, nil
+}
+
+// podLogger returns the logger of the context with the name and the namespace of the pod.
+func podLogger(ctx context.Context, podName, namespace string) *log.Entry {
+	return logging.ForNodeName(ctx, podName).WithField(logging.FieldNamespace, namespace)
+}
+
+// GetPodIP returns the IP of the pod, it returns an error if the pod doesn't have an IP yet.
+func GetPodIP(ctx context.Context, podName, namespace string) (string, error) {
+	clientSet, _, err := newClient()
+	if err != nil {
+		return "", err
+	}
+
+	pod, err := clientSet.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		podLogger(ctx, podName, namespace).WithError(err).Error("Error getting the pod")
+		return "", err
+	}
+	if pod.Status.PodIP == "" {
+		return "", fmt.Errorf("the pod [%s] doesn't have an IP yet", podName)
+	}
+
+	return pod.Status.PodIP, nil
+}
+
+// GetSecretValue returns the value of the key in the Secret.
+func GetSecretValue(ctx context.Context, namespace, name, key string) (string, error) {
+	clientSet, _, err := newClient()
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := clientSet.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		logging.FromContext(ctx).WithFields(log.Fields{
+			logging.FieldNamespace: namespace,
+			"secret":               name,
+		}).WithError(err).Error("Error getting the secret")
+		return "", err
+	}
+
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("the secret [%s] doesn't have the key [%s]", name, key)
+	}
+
+	return string(value), nil
+}
+
+// PortForward forwards a random local port to the port of the pod through the API server, it returns the local port
+// and the function to stop the forwarding.
+func PortForward(ctx context.Context, podName, namespace string, port int) (uint16, func(), error) {
+	clientSet, cfg, err := newClient()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	logger := podLogger(ctx, podName, namespace).WithField("port", port)
+	transport, upgrader, err := spdy.RoundTripperFor(cfg)
+	if err != nil {
+		logger.WithError(err).Error("Error creating the round tripper")
+		return 0, nil, err
+	}
+
+	url := clientSet.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stopChan := make(chan struct{})
+	readyChan := make(chan struct{})
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(stopChan) })
+	}
+
+	forwarder, err := portforward.NewOnAddresses(
+		dialer,
+		[]string{"127.0.0.1"},
+		[]string{fmt.Sprintf("0:%d", port)},
+		stopChan,
+		readyChan,
+		io.Discard,
+		io.Discard,
+	)
+	if err != nil {
+		logger.WithError(err).Error("Error creating the port forward to the pod")
+		return 0, nil, err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- forwarder.ForwardPorts()
+	}()
+
+	select {
+	case <-readyChan:
+	case err := <-errChan:
+		if err == nil {
+			err = errors.New("the port forward stopped before being ready")
+		}
+		logger.WithError(err).Error("Error forwarding the port of the pod")
+		return 0, nil, err
+	case <-ctx.Done():
+		stop()
+		return 0, nil, ctx.Err()
+	}
+
+	ports, err := forwarder.GetPorts()
+	if err != nil || len(ports) == 0 {
+		stop()
+		return 0, nil, fmt.Errorf("error getting the local port of the port forward: %v", err)
+	}
+
+	return ports[0].Local, stop, nil
+}
@@ -5,24 +5,13 @@ import (
 )
 
 var (
-	trustedPeerFile          = "/tmp/TP-ADDR"
-	trustedPeerFileConsensus = "/home/celestia/config/TP-ADDR"
-	trustedPeerFileDA        = "/tmp/CONSENSUS_NODE_SERVICE"
-	nodeIpFile               = "/tmp/NODE_IP"
-	cmd                      = `$(ifconfig | grep -oE 'inet addr:([0-9]+\.[0-9]+\.[0-9]+\.[0-9]+)' | grep -v '127.0.0.1' | awk '{print substr($2, 6)}')`
-	trustedPeerPrefix        = "/ip4/" + cmd + "/tcp/2121/p2p/"
+	nodeIpFile        = "/tmp/NODE_IP"
+	cmd               = `$(ifconfig | grep -oE 'inet addr:([0-9]+\.[0-9]+\.[0-9]+\.[0-9]+)' | grep -v '127.0.0.1' | awk '{print substr($2, 6)}')`
+	trustedPeerPrefix = "/ip4/" + cmd + "/tcp/2121/p2p/"
 )
 
-// CreateFileWithEnvVar creates the file in the FS with the node to connect.
-func CreateFileWithEnvVar(nodeToFile, nodeType string) []string {
-	f := ""
-	if nodeType == "consensus" {
-		f = trustedPeerFileConsensus
-	}
-	if nodeType == "da" {
-		f = trustedPeerFileDA
-	}
-
+// CreateFileWithEnvVar creates the file in the FS with the node to connect, each node type specifies the file.
+func CreateFileWithEnvVar(nodeToFile, f string) []string {
 	script := fmt.Sprintf(`
 #!/bin/sh
 echo -n "%[2]s" > "%[1]s"`, f, nodeToFile)
@@ -30,30 +19,10 @@ echo -n "%[2]s" > "%[1]s"`, f, nodeToFile)
 	return []string{"sh", "-c", script}
 }
 
-// CreateTrustedPeerCommand generates the command for creating trusted peers.
-// we have to use the shell script because we can only get the token and the
-// nodeID from the node itself.
-func CreateTrustedPeerCommand() []string {
-	script := fmt.Sprintf(`
-#!/bin/sh
-# generate the token
-export AUTHTOKEN=$(celestia bridge auth admin --node.store /home/celestia)
-
-# remove the first warning line...
-export AUTHTOKEN=$(echo $AUTHTOKEN|rev|cut -d' ' -f1|rev)
-
-# make the request and parse the response
-TP_ADDR=$(wget --header="Authorization: Bearer $AUTHTOKEN" \
-   --header="Content-Type: application/json" \
-   --post-data='{"jsonrpc":"2.0","id":0,"method":"p2p.Info","params":[]}' \
-   --output-document - \
-   http://localhost:26658 | grep -o '"ID":"[^"]*"' | sed 's/"ID":"\([^"]*\)"/\1/')
-
-echo -n "${TP_ADDR}" >> "%[1]s"
-cat "%[1]s"
-`, trustedPeerFile, trustedPeerPrefix)
-
-	return []string{"sh", "-c", script}
+// CreateAuthTokenCommand generates the command to mint an admin token, using the auth
+// command of the node type (bridge, full or light) and its store.
+func CreateAuthTokenCommand(nodeType, nodeStore string) []string {
+	return []string{"celestia", nodeType, "auth", "admin", "--node.store", nodeStore}
 }
 
 // GetNodeIP adds the node IP to a file.
@@ -75,3 +44,12 @@ cat "%[2]s"`, content, file)
 
 	return []string{"sh", "-c", script}
 }
+
+// ReadFile reads the content of a file, the output is empty if the file doesn't exist.
+func ReadFile(file string) []string {
+	script := fmt.Sprintf(`
+#!/bin/sh
+[ -f "%[1]s" ] && cat "%[1]s" || true`, file)
+
+	return []string{"sh", "-c", script}
+}
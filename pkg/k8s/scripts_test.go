@@ -8,11 +8,11 @@ import (
 // case1 common message.
 const case1 = "Case 1: Successfully script generated."
 
-// TestCreateFileWithEnvVar validates the node types and their path
+// TestCreateFileWithEnvVar validates the script to write the node in the path of each node type
 func TestCreateFileWithEnvVar(t *testing.T) {
 	type args struct {
 		nodeToFile string
-		nodeType   string
+		file       string
 	}
 	tests := []struct {
 		name string
@@ -23,7 +23,7 @@ func TestCreateFileWithEnvVar(t *testing.T) {
 			name: "Case 1: Check [consensus] nodes",
 			args: args{
 				nodeToFile: "/home/celestia/config/TP-ADDR",
-				nodeType:   "consensus",
+				file:       "/home/celestia/config/TP-ADDR",
 			},
 			want: []string{"sh", "-c", `
 #!/bin/sh
@@ -33,7 +33,7 @@ echo -n "/home/celestia/config/TP-ADDR" > "/home/celestia/config/TP-ADDR"`},
 			name: "Case 2: Check [da] nodes",
 			args: args{
 				nodeToFile: "/tmp/CONSENSUS_NODE_SERVICE",
-				nodeType:   "da",
+				file:       "/tmp/CONSENSUS_NODE_SERVICE",
 			},
 			want: []string{"sh", "-c", `
 #!/bin/sh
@@ -43,45 +43,45 @@ echo -n "/tmp/CONSENSUS_NODE_SERVICE" > "/tmp/CONSENSUS_NODE_SERVICE"`},
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := CreateFileWithEnvVar(tt.args.nodeToFile, tt.args.nodeType); !reflect.DeepEqual(got, tt.want) {
+			if got := CreateFileWithEnvVar(tt.args.nodeToFile, tt.args.file); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("CreateFileWithEnvVar() = got: \n%v, \nwant \n%v", got, tt.want)
 			}
 		})
 	}
 }
 
-// TestCreateTrustedPeerCommand checks the script to generate the multi address.
-func TestCreateTrustedPeerCommand(t *testing.T) {
+// TestCreateAuthTokenCommand checks the command to mint the token of each node type.
+func TestCreateAuthTokenCommand(t *testing.T) {
+	type args struct {
+		nodeType  string
+		nodeStore string
+	}
 	tests := []struct {
 		name string
+		args args
 		want []string
 	}{
 		{
 			name: case1,
-			want: []string{"sh", "-c", `
-#!/bin/sh
-# generate the token
-export AUTHTOKEN=$(celestia bridge auth admin --node.store /home/celestia)
-
-# remove the first warning line...
-export AUTHTOKEN=$(echo $AUTHTOKEN|rev|cut -d' ' -f1|rev)
-
-# make the request and parse the response
-TP_ADDR=$(wget --header="Authorization: Bearer $AUTHTOKEN" \
-   --header="Content-Type: application/json" \
-   --post-data='{"jsonrpc":"2.0","id":0,"method":"p2p.Info","params":[]}' \
-   --output-document - \
-   http://localhost:26658 | grep -o '"ID":"[^"]*"' | sed 's/"ID":"\([^"]*\)"/\1/')
-
-echo -n "${TP_ADDR}" >> "/tmp/TP-ADDR"
-cat "/tmp/TP-ADDR"
-`},
+			args: args{
+				nodeType:  "bridge",
+				nodeStore: "/home/celestia",
+			},
+			want: []string{"celestia", "bridge", "auth", "admin", "--node.store", "/home/celestia"},
+		},
+		{
+			name: "Case 2: Light node with its own store",
+			args: args{
+				nodeType:  "light",
+				nodeStore: "/home/celestia/.celestia-light",
+			},
+			want: []string{"celestia", "light", "auth", "admin", "--node.store", "/home/celestia/.celestia-light"},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := CreateTrustedPeerCommand(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("CreateTrustedPeerCommand() = %v, want %v", got, tt.want)
+			if got := CreateAuthTokenCommand(tt.args.nodeType, tt.args.nodeStore); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CreateAuthTokenCommand() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -3,14 +3,15 @@ package k8s
 import (
 	"context"
 	"errors"
-	"fmt"
 
 	log "github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
+	"github.com/jrmanes/torch/pkg/logging"
 	"github.com/jrmanes/torch/pkg/metrics"
 )
 
@@ -21,47 +22,44 @@ func RetrieveAndGenerateMetrics() ([]metrics.LoadBalancer, error) {
 	// Get list of LBs
 	svc, err := ListServices()
 	if err != nil {
-		log.Error("Failed to retrieve the LoadBalancers: ", err)
+		log.WithError(err).Error("Failed to retrieve the LoadBalancers")
 		return nil, err
 	}
 
 	// Get the list of the LBs
 	loadBalancers, err := GetLoadBalancers(svc)
 	if err != nil {
-		log.Error("Error getting the load balancers: ", err)
+		log.WithError(err).Error("Error getting the load balancers")
 		return nil, err
 	}
 
-	// Generate the metrics with the LBs
-	err = metrics.WithMetricsLoadBalancer(loadBalancers)
-	if err != nil {
-		log.Error("Failed to update metrics: ", err)
-		return nil, err
-	}
+	// Replace the metrics with the current LBs
+	metrics.SetLoadBalancers(loadBalancers)
 
 	return loadBalancers, nil
 }
 
 // ListServices retrieves the list of services in a namespace
 func ListServices() (*corev1.ServiceList, error) {
+	logger := log.WithField(logging.FieldNamespace, GetCurrentNamespace())
 	// Authentication in cluster - using Service Account, Role, RoleBinding
 	config, err := rest.InClusterConfig()
 	if err != nil {
-		log.Error("ERROR: ", err)
+		logger.WithError(err).Error("Failed to get in-cluster config")
 		return nil, err
 	}
 
 	// Create the Kubernetes clientSet
 	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		log.Error("ERROR: ", err)
+		logger.WithError(err).Error("Failed to create Kubernetes clientSet")
 		return nil, err
 	}
 
 	// Get all services in the namespace
 	services, err := clientSet.CoreV1().Services(GetCurrentNamespace()).List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		log.Error("ERROR: ", err)
+		logger.WithError(err).Error("Failed to list the services")
 		return nil, err
 	}
 
@@ -75,7 +73,11 @@ func GetLoadBalancers(svc *corev1.ServiceList) ([]metrics.LoadBalancer, error) {
 	for _, svc := range svc.Items {
 		if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
 			for _, ingress := range svc.Status.LoadBalancer.Ingress {
-				log.Info(fmt.Sprintf("Updating metrics for service: [%s] with IP: [%s]", svc.Name, ingress.IP))
+				log.WithFields(log.Fields{
+					logging.FieldNamespace: svc.Namespace,
+					"service":              svc.Name,
+					"ip":                   ingress.IP,
+				}).Info("Updating metrics for service")
 
 				// Create a LoadBalancer struct and append it to the loadBalancers list
 				loadBalancer := metrics.LoadBalancer{
@@ -97,14 +99,16 @@ func GetLoadBalancers(svc *corev1.ServiceList) ([]metrics.LoadBalancer, error) {
 	return loadBalancers, nil
 }
 
-// WatchServices watches for changes to the services in the specified namespace and updates the metrics accordingly
-func WatchServices(done chan<- error) {
+// WatchServices watches for changes to the services in the specified namespace and updates the metrics accordingly,
+// until the context is done.
+func WatchServices(ctx context.Context, done chan<- error) {
 	defer close(done)
 
+	logger := log.WithField(logging.FieldNamespace, GetCurrentNamespace())
 	// Authentication in cluster - using Service Account, Role, RoleBinding
 	config, err := rest.InClusterConfig()
 	if err != nil {
-		log.Error("Failed to get in-cluster config: ", err)
+		logger.WithError(err).Error("Failed to get in-cluster config")
 		done <- err
 		return
 	}
@@ -112,36 +116,66 @@ func WatchServices(done chan<- error) {
 	// Create the Kubernetes clientSet
 	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		log.Error("Failed to create Kubernetes clientSet: ", err)
+		logger.WithError(err).Error("Failed to create Kubernetes clientSet")
 		done <- err
 		return
 	}
 
 	// Create a service watcher
-	watcher, err := clientSet.CoreV1().Services(GetCurrentNamespace()).Watch(context.Background(), metav1.ListOptions{})
+	open := func() (watch.Interface, error) {
+		return clientSet.CoreV1().Services(GetCurrentNamespace()).Watch(ctx, metav1.ListOptions{})
+	}
+	watcher, err := open()
 	if err != nil {
-		log.Error("Failed to create service watcher: ", err)
+		logger.WithError(err).Error("Failed to create service watcher")
 		done <- err
 		return
 	}
+	defer func() { watcher.Stop() }()
 
 	// Watch for events on the watcher channel
-	for event := range watcher.ResultChan() {
-		if service, ok := event.Object.(*corev1.Service); ok {
-			if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
-				loadBalancers, err := GetLoadBalancers(&corev1.ServiceList{Items: []corev1.Service{*service}})
+	for {
+		var event watch.Event
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping the services watcher")
+			return
+		case e, ok := <-watcher.ResultChan():
+			if !ok {
+				w, err := restartWatch(ctx, "services", open)
 				if err != nil {
-					log.Error("Failed to get the load balancers metrics: %v", err)
-					done <- err
-					return
-				}
-
-				if err := metrics.WithMetricsLoadBalancer(loadBalancers); err != nil {
-					log.Error("Failed to update metrics with load balancers: ", err)
-					done <- err
+					if ctx.Err() == nil {
+						logger.WithError(err).Error("Failed to restart the service watcher")
+						done <- err
+					}
 					return
 				}
+				watcher = w
+				continue
 			}
+			event = e
+		}
+
+		if service, ok := event.Object.(*corev1.Service); ok {
+			updateLoadBalancerMetric(event.Type, service)
 		}
 	}
 }
+
+// updateLoadBalancerMetric replaces the load_balancer metric of the service, removing it when the service is deleted
+// or it's not a LoadBalancer anymore.
+func updateLoadBalancerMetric(eventType watch.EventType, service *corev1.Service) {
+	if eventType == watch.Deleted || service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		metrics.RemoveLoadBalancer(service.Namespace, service.Name)
+		return
+	}
+
+	// the service doesn't have an IP yet, or it was released
+	loadBalancers, err := GetLoadBalancers(&corev1.ServiceList{Items: []corev1.Service{*service}})
+	if err != nil {
+		metrics.RemoveLoadBalancer(service.Namespace, service.Name)
+		return
+	}
+
+	metrics.UpdateLoadBalancer(service.Namespace, service.Name, loadBalancers)
+}
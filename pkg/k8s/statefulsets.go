@@ -7,61 +7,87 @@ import (
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/logging"
 )
 
 const (
-	queueK8SNodes = "k8s" // queueK8SNodes name of the queue.
-	daNodePrefix  = "da"  // daNodePrefix name prefix that Torch will use to filter the StatefulSets.
+	daNodePrefix = "da" // daNodePrefix name prefix that Torch will use to filter the StatefulSets.
 )
 
-// WatchStatefulSets watches for changes to the StatefulSets in the specified namespace and updates the metrics accordingly
-func WatchStatefulSets() error {
+// WatchStatefulSets watches for changes to the StatefulSets in the specified namespace and adds the valid ones
+// to the queue using the producer, until the context is done.
+func WatchStatefulSets(ctx context.Context, producer *redis.Producer) error {
 	// namespace get the current namespace where torch is running
 	namespace := GetCurrentNamespace()
+	logger := log.WithField(logging.FieldNamespace, namespace)
 	// Authentication in cluster - using Service Account, Role, RoleBinding
 	cfg, err := rest.InClusterConfig()
 	if err != nil {
-		log.Error("Error: ", err)
+		logger.WithError(err).Error("Failed to get in-cluster config")
 		return err
 	}
 
 	// Create the Kubernetes clientSet
 	clientSet, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
-		log.Error("Error: ", err)
+		logger.WithError(err).Error("Failed to create Kubernetes clientSet")
 		return err
 	}
 
 	// Create a StatefulSet watcher
-	watcher, err := clientSet.AppsV1().StatefulSets(namespace).Watch(context.Background(), metav1.ListOptions{})
+	open := func() (watch.Interface, error) {
+		return clientSet.AppsV1().StatefulSets(namespace).Watch(ctx, metav1.ListOptions{})
+	}
+	watcher, err := open()
 	if err != nil {
-		log.Error("Error: ", err)
+		logger.WithError(err).Error("Failed to create the StatefulSets watcher")
 		return err
 	}
+	defer func() { watcher.Stop() }()
 
 	// Watch for events on the watcher channel
-	for event := range watcher.ResultChan() {
+	for {
+		var event watch.Event
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping the StatefulSets watcher")
+			return nil
+		case e, ok := <-watcher.ResultChan():
+			if !ok {
+				w, err := restartWatch(ctx, "statefulsets", open)
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					logger.WithError(err).Error("Error restarting the StatefulSets watcher")
+					return err
+				}
+				watcher = w
+				continue
+			}
+			event = e
+		}
+
 		if statefulSet, ok := event.Object.(*v1.StatefulSet); ok {
 			if !ok {
-				log.Warn("Received an event that is not a StatefulSet. Skipping this resource...")
+				logger.Warn("Received an event that is not a StatefulSet. Skipping this resource...")
 				continue
 			}
 
 			if isStatefulSetValid(statefulSet) {
-				err := redis.Producer(statefulSet.Name, queueK8SNodes)
+				err := producer.Publish(statefulSet.Name)
 				if err != nil {
-					log.Error("ERROR adding the node to the queue: ", err)
+					logger.WithField(logging.FieldNode, statefulSet.Name).WithError(err).Error("Error adding the node to the queue")
 					return err
 				}
 			}
 		}
 	}
-
-	return nil
 }
 
 // isStatefulSetValid validates the StatefulSet received.
@@ -1,11 +1,19 @@
 package k8s
 
 import (
+	"context"
 	"os"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/jrmanes/torch/pkg/metrics"
 )
 
+// watchRestartDelay time to wait before opening a watch again once the API server closes it.
+var watchRestartDelay = 5 * time.Second
+
 // GetCurrentNamespace gets the current namespace from the environment variable.
 // If the variable is not defined, the default value "default" is used.
 func GetCurrentNamespace() string {
@@ -17,3 +25,18 @@ func GetCurrentNamespace() string {
 	}
 	return currentNamespace
 }
+
+// restartWatch opens the watch again after watchRestartDelay, the API server closes the watches periodically.
+// It returns the error of the context if it's done before.
+func restartWatch(ctx context.Context, name string, open func() (watch.Interface, error)) (watch.Interface, error) {
+	log.WithField("watcher", name).Warn("The watcher was closed, restarting it")
+	metrics.IncWatcherRestarts(name)
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(watchRestartDelay):
+	}
+
+	return open()
+}
@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// TestRestartWatch checks that the watch is opened again, unless the context is done.
+func TestRestartWatch(t *testing.T) {
+	defer func(delay time.Duration) { watchRestartDelay = delay }(watchRestartDelay)
+
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		cancel   bool
+		wantOpen bool
+	}{
+		{
+			name:     "Case 1: The watch is opened again",
+			delay:    time.Millisecond,
+			wantOpen: true,
+		},
+		{
+			name:     "Case 2: The context is done",
+			delay:    time.Hour,
+			cancel:   true,
+			wantOpen: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watchRestartDelay = tt.delay
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			opened := false
+			w, err := restartWatch(ctx, "test", func() (watch.Interface, error) {
+				opened = true
+				return watch.NewFake(), nil
+			})
+			if tt.cancel && err == nil {
+				t.Errorf("restartWatch() expected an error with the context done")
+			}
+			if !tt.cancel && (err != nil || w == nil) {
+				t.Errorf("restartWatch() = %v, %v, want a watch", w, err)
+			}
+			if opened != tt.wantOpen {
+				t.Errorf("restartWatch() opened = %v, want %v", opened, tt.wantOpen)
+			}
+		})
+	}
+}
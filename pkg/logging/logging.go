@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+)
+
+const (
+	FormatText = "text" // FormatText logs the lines as key=value, it's the default format.
+	FormatJSON = "json" // FormatJSON logs the lines as JSON objects.
+
+	FieldNode      = "node"      // FieldNode name of the node.
+	FieldNamespace = "namespace" // FieldNamespace namespace of the node.
+	FieldNodeType  = "nodeType"  // FieldNodeType type of the node.
+	FieldRequestID = "requestID" // FieldRequestID id of the HTTP request which started the work.
+	FieldJobID     = "jobID"     // FieldJobID id of the node in the queue, it's the same in all its attempts.
+
+	RequestIDHeader = "X-Request-ID" // RequestIDHeader header used to receive and return the request id.
+)
+
+// contextKey type of the keys stored in the context by this package.
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota // requestIDKey key of the request id in the context.
+	jobIDKey                       // jobIDKey key of the job id in the context.
+)
+
+// DefaultFormat returns the format in LOG_FORMAT, or text if it's not defined.
+func DefaultFormat() string {
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		return format
+	}
+	return FormatText
+}
+
+// DefaultLevel returns the level in LOG_LEVEL, or info if it's not defined.
+func DefaultLevel() string {
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		return level
+	}
+	return log.InfoLevel.String()
+}
+
+// Configure sets the format and the level of the logs, it returns an error if one of them is not valid, and the
+// current value is kept.
+func Configure(format, level string) error {
+	switch strings.ToLower(format) {
+	case FormatText:
+		log.SetFormatter(&log.TextFormatter{})
+	case FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("invalid log format [%s], valid formats: %s, %s", format, FormatText, FormatJSON)
+	}
+
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("invalid log level [%s]: %w", level, err)
+	}
+	log.SetLevel(lvl)
+
+	return nil
+}
+
+// NewID returns a random id to correlate the logs of a request or a job.
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// it can't fail on the supported platforms, the logs are only less correlated
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of the context with the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request id of the context, or an empty string.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithJobID returns a copy of the context with the job id.
+func WithJobID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, jobIDKey, id)
+}
+
+// JobID returns the job id of the context, or an empty string.
+func JobID(ctx context.Context) string {
+	id, _ := ctx.Value(jobIDKey).(string)
+	return id
+}
+
+// FromContext returns the logger with the request id and the job id of the context.
+func FromContext(ctx context.Context) *log.Entry {
+	fields := log.Fields{}
+	if id := RequestID(ctx); id != "" {
+		fields[FieldRequestID] = id
+	}
+	if id := JobID(ctx); id != "" {
+		fields[FieldJobID] = id
+	}
+	return log.WithFields(fields)
+}
+
+// ForNode returns the logger of the context with the name, the namespace and the type of the node.
+func ForNode(ctx context.Context, peer config.Peer) *log.Entry {
+	fields := log.Fields{FieldNode: peer.NodeName}
+	if peer.Namespace != "" {
+		fields[FieldNamespace] = peer.Namespace
+	}
+	if peer.NodeType != "" {
+		fields[FieldNodeType] = peer.NodeType
+	}
+	return FromContext(ctx).WithFields(fields)
+}
+
+// ForNodeName returns the logger of the context with the name of the node, when the rest of the peer is not known.
+func ForNodeName(ctx context.Context, nodeName string) *log.Entry {
+	return FromContext(ctx).WithField(FieldNode, nodeName)
+}
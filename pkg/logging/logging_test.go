@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+)
+
+// TestConfigure checks the formats and levels accepted.
+func TestConfigure(t *testing.T) {
+	defer func() {
+		log.SetFormatter(&log.TextFormatter{})
+		log.SetLevel(log.InfoLevel)
+	}()
+
+	tests := []struct {
+		name      string
+		format    string
+		level     string
+		wantErr   bool
+		wantLevel log.Level
+	}{
+		{
+			name:      "Case 1: Text and debug",
+			format:    "text",
+			level:     "debug",
+			wantLevel: log.DebugLevel,
+		},
+		{
+			name:      "Case 2: JSON is case insensitive",
+			format:    "JSON",
+			level:     "warn",
+			wantLevel: log.WarnLevel,
+		},
+		{
+			name:    "Case 3: Invalid format",
+			format:  "xml",
+			level:   "info",
+			wantErr: true,
+		},
+		{
+			name:    "Case 4: Invalid level",
+			format:  "json",
+			level:   "verbose",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Configure(tt.format, tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Configure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && log.GetLevel() != tt.wantLevel {
+				t.Errorf("Configure() level = %v, want %v", log.GetLevel(), tt.wantLevel)
+			}
+		})
+	}
+}
+
+// TestForNode checks the fields of the node and the ids of the context in the JSON logs.
+func TestForNode(t *testing.T) {
+	var buf bytes.Buffer
+	out := log.StandardLogger().Out
+	log.SetOutput(&buf)
+	log.SetFormatter(&log.JSONFormatter{})
+	defer func() {
+		log.SetOutput(out)
+		log.SetFormatter(&log.TextFormatter{})
+	}()
+
+	ctx := WithJobID(WithRequestID(context.Background(), "req-1"), "job-1")
+	ForNode(ctx, config.Peer{NodeName: "da-bridge-1-0", Namespace: "celestia", NodeType: "da-bridge"}).Info("test")
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON log %q: %v", buf.String(), err)
+	}
+
+	want := map[string]string{
+		FieldNode:      "da-bridge-1-0",
+		FieldNamespace: "celestia",
+		FieldNodeType:  "da-bridge",
+		FieldRequestID: "req-1",
+		FieldJobID:     "job-1",
+		"msg":          "test",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("field %s = %v, want %v", key, got[key], value)
+		}
+	}
+}
+
+// TestContextIDs checks that the contexts without ids don't add empty fields.
+func TestContextIDs(t *testing.T) {
+	entry := FromContext(context.Background())
+	if len(entry.Data) != 0 {
+		t.Errorf("FromContext() fields = %v, want none", entry.Data)
+	}
+
+	if a, b := NewID(), NewID(); a == "" || a == b {
+		t.Errorf("NewID() = %q, %q, want different non empty ids", a, b)
+	}
+}
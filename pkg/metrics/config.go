@@ -1,25 +1,150 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"os"
+
 	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/propagation"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
-// InitConfig initializes the configs Prometheus - OTEL
-func InitConfig() error {
+const (
+	ProtocolGRPC = "grpc"          // ProtocolGRPC exports using OTLP/gRPC.
+	ProtocolHTTP = "http/protobuf" // ProtocolHTTP exports using OTLP/HTTP, it's the default of the OTLP exporters.
+
+	signalMetrics = "METRICS" // signalMetrics suffix of the env vars of the metrics exporter.
+	signalTraces  = "TRACES"  // signalTraces suffix of the env vars of the traces exporter.
+)
+
+// InitConfig initializes the configs Prometheus - OTEL, the metrics and the traces are also exported using OTLP when
+// the endpoint is defined in the OTEL_EXPORTER_OTLP_* env vars. It returns the function to flush and stop the exporters.
+func InitConfig(ctx context.Context) (func(context.Context) error, error) {
+	res, err := newResource(ctx)
+	if err != nil {
+		log.Error("Error creating the resource: ", err)
+		return nil, err
+	}
+
 	// Initialize the Prometheus exporter
 	log.Info("Initializing Prometheus client...")
 	exporter, err := prometheus.New()
 	if err != nil {
-		log.Fatal(err)
-		return err
+		log.Error("Error creating the Prometheus exporter: ", err)
+		return nil, err
+	}
+
+	meterProvider, err := newMeterProvider(ctx, res, sdkmetric.WithReader(exporter))
+	if err != nil {
+		log.Error("Error creating the OTLP metrics exporter: ", err)
+		return nil, err
+	}
+	tracerProvider, err := newTracerProvider(ctx, res)
+	if err != nil {
+		log.Error("Error creating the OTLP traces exporter: ", err)
+		return nil, err
 	}
-	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(exporter))
 
 	log.Info("Initializing OTEL Provider...")
-	otel.SetMeterProvider(provider)
+	otel.SetMeterProvider(meterProvider)
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	shutdown := func(ctx context.Context) error {
+		return errors.Join(meterProvider.Shutdown(ctx), tracerProvider.Shutdown(ctx))
+	}
+
+	return shutdown, nil
+}
+
+// newResource returns the resource of Torch, the attributes can be overwritten with OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES.
+func newResource(ctx context.Context) (*resource.Resource, error) {
+	return resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "torch")),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+}
+
+// newMeterProvider returns the meter provider with the readers received, plus a periodic OTLP reader if the endpoint
+// of the metrics is defined.
+func newMeterProvider(ctx context.Context, res *resource.Resource, opts ...sdkmetric.Option) (*sdkmetric.MeterProvider, error) {
+	opts = append(opts, sdkmetric.WithResource(res))
+
+	protocol, ok := otlpProtocol(signalMetrics)
+	if ok {
+		var exporter sdkmetric.Exporter
+		var err error
+		if protocol == ProtocolGRPC {
+			exporter, err = otlpmetricgrpc.New(ctx)
+		} else {
+			exporter, err = otlpmetrichttp.New(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		log.Info("Exporting the metrics using OTLP: ", protocol)
+		opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)))
+	}
+
+	return sdkmetric.NewMeterProvider(opts...), nil
+}
+
+// newTracerProvider returns the tracer provider, the spans are exported using OTLP if the endpoint of the traces is
+// defined, otherwise, they are only propagated.
+func newTracerProvider(ctx context.Context, res *resource.Resource) (*sdktrace.TracerProvider, error) {
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+
+	protocol, ok := otlpProtocol(signalTraces)
+	if ok {
+		var exporter sdktrace.SpanExporter
+		var err error
+		if protocol == ProtocolGRPC {
+			exporter, err = otlptracegrpc.New(ctx)
+		} else {
+			exporter, err = otlptracehttp.New(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	return nil
+		log.Info("Exporting the traces using OTLP: ", protocol)
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// otlpProtocol returns the protocol of the OTLP exporter of the signal, and false if its endpoint is not defined.
+// The protocol of the signal takes precedence over the general one, like the endpoint.
+func otlpProtocol(signal string) (string, bool) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_"+signal+"_ENDPOINT") == "" {
+		return "", false
+	}
+
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_" + signal + "_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	switch protocol {
+	case ProtocolGRPC, ProtocolHTTP:
+		return protocol, true
+	case "":
+		return ProtocolHTTP, true
+	default:
+		log.Error("Invalid OTLP protocol [", protocol, "] for ", signal, ", using the default value: ", ProtocolHTTP)
+		return ProtocolHTTP, true
+	}
 }
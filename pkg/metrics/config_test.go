@@ -0,0 +1,216 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+)
+
+// collectorStub records the signals received by an in-process OTLP collector.
+type collectorStub struct {
+	mu       sync.Mutex
+	received map[string]int // received number of exports by signal.
+}
+
+// newCollectorStub returns an empty collector.
+func newCollectorStub() *collectorStub {
+	return &collectorStub{received: map[string]int{}}
+}
+
+// record adds an export of the signal.
+func (c *collectorStub) record(signal string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.received[signal]++
+}
+
+// count returns the number of exports of the signal.
+func (c *collectorStub) count(signal string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.received[signal]
+}
+
+// ServeHTTP receives the metrics and the spans using OTLP/HTTP.
+func (c *collectorStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/metrics":
+		c.record("metrics")
+	case "/v1/traces":
+		c.record("traces")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcTraceService exposes the trace service of the stub.
+type grpcTraceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	stub *collectorStub
+}
+
+// Export receives the spans using OTLP/gRPC.
+func (s grpcTraceService) Export(context.Context, *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	s.stub.record("traces")
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+// grpcMetricsService exposes the metrics service of the stub.
+type grpcMetricsService struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	stub *collectorStub
+}
+
+// Export receives the metrics using OTLP/gRPC.
+func (s grpcMetricsService) Export(context.Context, *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	s.stub.record("metrics")
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+// TestOTLPProtocol checks the protocol used by the exporters depending on the env vars.
+func TestOTLPProtocol(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantProtocol string
+		wantEnabled  bool
+	}{
+		{
+			name:        "Case 1: No endpoint, the signal is not exported",
+			env:         map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": ProtocolGRPC},
+			wantEnabled: false,
+		},
+		{
+			name:         "Case 2: General endpoint, default protocol",
+			env:          map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318"},
+			wantProtocol: ProtocolHTTP,
+			wantEnabled:  true,
+		},
+		{
+			name: "Case 3: The protocol of the signal takes precedence",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://collector:4317",
+				"OTEL_EXPORTER_OTLP_PROTOCOL":        ProtocolHTTP,
+				"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL": ProtocolGRPC,
+			},
+			wantProtocol: ProtocolGRPC,
+			wantEnabled:  true,
+		},
+		{
+			name: "Case 4: Invalid protocol, default protocol",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+				"OTEL_EXPORTER_OTLP_PROTOCOL": "http/json",
+			},
+			wantProtocol: ProtocolHTTP,
+			wantEnabled:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+				"OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL",
+			} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			protocol, enabled := otlpProtocol(signalTraces)
+			if protocol != tt.wantProtocol || enabled != tt.wantEnabled {
+				t.Errorf("otlpProtocol() = %s, %v, want %s, %v", protocol, enabled, tt.wantProtocol, tt.wantEnabled)
+			}
+		})
+	}
+}
+
+// TestOTLPExport checks that the metrics and the spans are exported to the collector with both protocols.
+func TestOTLPExport(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		serve    func(t *testing.T, stub *collectorStub) string
+	}{
+		{
+			name:     "Case 1: OTLP/HTTP",
+			protocol: ProtocolHTTP,
+			serve: func(t *testing.T, stub *collectorStub) string {
+				server := httptest.NewServer(stub)
+				t.Cleanup(server.Close)
+				return server.URL
+			},
+		},
+		{
+			name:     "Case 2: OTLP/gRPC",
+			protocol: ProtocolGRPC,
+			serve: func(t *testing.T, stub *collectorStub) string {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatalf("Listen() error = %v", err)
+				}
+				server := grpc.NewServer()
+				coltracepb.RegisterTraceServiceServer(server, grpcTraceService{stub: stub})
+				colmetricpb.RegisterMetricsServiceServer(server, grpcMetricsService{stub: stub})
+				go func() { _ = server.Serve(listener) }()
+				t.Cleanup(server.Stop)
+				return "http://" + listener.Addr().String()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stub := newCollectorStub()
+			t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", tt.serve(t, stub))
+			t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", tt.protocol)
+			t.Setenv("OTEL_EXPORTER_OTLP_INSECURE", "true")
+
+			res, err := newResource(ctx)
+			if err != nil {
+				t.Fatalf("newResource() error = %v", err)
+			}
+
+			meterProvider, err := newMeterProvider(ctx, res)
+			if err != nil {
+				t.Fatalf("newMeterProvider() error = %v", err)
+			}
+			counter, err := meterProvider.Meter("test").Int64Counter("test_total")
+			if err != nil {
+				t.Fatalf("Int64Counter() error = %v", err)
+			}
+			counter.Add(ctx, 1)
+
+			tracerProvider, err := newTracerProvider(ctx, res)
+			if err != nil {
+				t.Fatalf("newTracerProvider() error = %v", err)
+			}
+			_, span := tracerProvider.Tracer("test").Start(ctx, "ConfigureNode")
+			span.End()
+
+			// the shutdown flushes the metrics and the spans
+			if err := meterProvider.Shutdown(ctx); err != nil {
+				t.Errorf("meterProvider.Shutdown() error = %v", err)
+			}
+			if err := tracerProvider.Shutdown(ctx); err != nil {
+				t.Errorf("tracerProvider.Shutdown() error = %v", err)
+			}
+
+			for _, signal := range []string{"metrics", "traces"} {
+				if stub.count(signal) == 0 {
+					t.Errorf("the collector didn't receive the %s", signal)
+				}
+			}
+		})
+	}
+}
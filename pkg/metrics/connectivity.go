@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	connectivityMetricsOnce sync.Once
+	peerConnectivityRatio   metric.Float64ObservableGauge // peerConnectivityRatio connected/expected peers by node.
+
+	peerConnectivityMu sync.Mutex
+	peerConnectivity   = map[string]PeerConnectivity{} // peerConnectivity last values by node name.
+)
+
+// PeerConnectivity represents the number of peers of a node which are connected and expected.
+type PeerConnectivity struct {
+	NodeName  string // NodeName name of the node.
+	Namespace string // Namespace of the node.
+	Connected int    // Connected number of expected peers which are connected.
+	Expected  int    // Expected number of peers in the config.
+}
+
+// initConnectivityMetrics creates the gauge of the connectivity and registers its callback only once.
+func initConnectivityMetrics() {
+	connectivityMetricsOnce.Do(func() {
+		var err error
+		peerConnectivityRatio, err = meter.Float64ObservableGauge(
+			"peer_connectivity_ratio",
+			metric.WithDescription("Torch - Ratio of the expected peers of the node which are connected"),
+		)
+		if err != nil {
+			log.Error("Error creating metric peer_connectivity_ratio: ", err)
+			return
+		}
+
+		// Define the callback function that will be called periodically to observe metrics.
+		callback := func(ctx context.Context, observer metric.Observer) error {
+			peerConnectivityMu.Lock()
+			defer peerConnectivityMu.Unlock()
+
+			for _, c := range peerConnectivity {
+				observer.ObserveFloat64(peerConnectivityRatio, c.Ratio(), metric.WithAttributes(
+					attribute.String("node_name", c.NodeName),
+					attribute.String("namespace", c.Namespace),
+				))
+			}
+			return nil
+		}
+
+		// Register the callback with the meter and the Float64ObservableGauge.
+		if _, err := meter.RegisterCallback(callback, peerConnectivityRatio); err != nil {
+			log.Error("Error registering the callback of peer_connectivity_ratio: ", err)
+		}
+	})
+}
+
+// Ratio returns the ratio of the expected peers which are connected, 1 if the node doesn't expect any peer.
+func (c PeerConnectivity) Ratio() float64 {
+	if c.Expected == 0 {
+		return 1
+	}
+	return float64(c.Connected) / float64(c.Expected)
+}
+
+// SetPeerConnectivity replaces the connectivity of the node.
+func SetPeerConnectivity(c PeerConnectivity) {
+	initConnectivityMetrics()
+
+	peerConnectivityMu.Lock()
+	defer peerConnectivityMu.Unlock()
+	peerConnectivity[c.NodeName] = c
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	heightMetricsOnce sync.Once
+	nodeHeight        metric.Int64ObservableGauge   // nodeHeight latest height of the node.
+	nodeBlockTime     metric.Float64ObservableGauge // nodeBlockTime time of the latest block of the consensus nodes.
+	nodeHeightLag     metric.Int64ObservableGauge   // nodeHeightLag blocks between the node and the network head.
+
+	nodeHeightsMu sync.Mutex
+	nodeHeights   = map[string]NodeHeight{} // nodeHeights last height by node name.
+)
+
+// NodeHeight represents the latest height of a node.
+type NodeHeight struct {
+	NodeName  string    // NodeName name of the node.
+	Namespace string    // Namespace of the node.
+	NodeType  string    // NodeType type of the node.
+	Consensus bool      // Consensus true if it's a consensus node, their heights define the network head.
+	Height    int64     // Height latest block of the consensus node, or header synced by the DA node.
+	Time      time.Time // Time of the latest block, zero if it's not known.
+}
+
+// initHeightMetrics creates the gauges of the heights and registers their callback only once.
+func initHeightMetrics() {
+	heightMetricsOnce.Do(func() {
+		var err error
+		nodeHeight, err = meter.Int64ObservableGauge(
+			"node_block_height",
+			metric.WithDescription("Torch - Latest block of the consensus nodes, or header synced by the DA nodes"),
+		)
+		if err != nil {
+			log.Error("Error creating metric node_block_height: ", err)
+			return
+		}
+		nodeBlockTime, err = meter.Float64ObservableGauge(
+			"node_block_time_seconds",
+			metric.WithDescription("Torch - Unix time of the latest block of the consensus nodes"),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			log.Error("Error creating metric node_block_time_seconds: ", err)
+			return
+		}
+		nodeHeightLag, err = meter.Int64ObservableGauge(
+			"node_height_lag",
+			metric.WithDescription("Torch - Blocks between the node and the network head"),
+		)
+		if err != nil {
+			log.Error("Error creating metric node_height_lag: ", err)
+			return
+		}
+
+		// Define the callback function that will be called periodically to observe metrics.
+		callback := func(ctx context.Context, observer metric.Observer) error {
+			nodeHeightsMu.Lock()
+			defer nodeHeightsMu.Unlock()
+
+			head := networkHead()
+			for _, h := range nodeHeights {
+				labels := metric.WithAttributes(
+					attribute.String("node_name", h.NodeName),
+					attribute.String("namespace", h.Namespace),
+					attribute.String("node_type", h.NodeType),
+				)
+				observer.ObserveInt64(nodeHeight, h.Height, labels)
+				observer.ObserveInt64(nodeHeightLag, head-h.Height, labels)
+				if !h.Time.IsZero() {
+					observer.ObserveFloat64(nodeBlockTime, float64(h.Time.UnixNano())/1e9, labels)
+				}
+			}
+			return nil
+		}
+
+		// Register the callback with the meter and the gauges.
+		if _, err := meter.RegisterCallback(callback, nodeHeight, nodeBlockTime, nodeHeightLag); err != nil {
+			log.Error("Error registering the callback of node_block_height: ", err)
+		}
+	})
+}
+
+// SetNodeHeight replaces the latest height of the node.
+func SetNodeHeight(h NodeHeight) {
+	initHeightMetrics()
+
+	nodeHeightsMu.Lock()
+	defer nodeHeightsMu.Unlock()
+	nodeHeights[h.NodeName] = h
+}
+
+// HeightLag returns the blocks between the node and the network head, and false if the height of the node is unknown.
+func HeightLag(nodeName string) (int64, bool) {
+	nodeHeightsMu.Lock()
+	defer nodeHeightsMu.Unlock()
+
+	h, ok := nodeHeights[nodeName]
+	if !ok {
+		return 0, false
+	}
+	return networkHead() - h.Height, true
+}
+
+// networkHead returns the highest height of the consensus nodes, or of all the nodes if there are no consensus nodes.
+// The lock of the heights must be held.
+func networkHead() int64 {
+	var head, consensusHead int64
+	for _, h := range nodeHeights {
+		if h.Height > head {
+			head = h.Height
+		}
+		if h.Consensus && h.Height > consensusHead {
+			consensusHead = h.Height
+		}
+	}
+	if consensusHead > 0 {
+		return consensusHead
+	}
+	return head
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	LockAcquired  = "acquired"  // LockAcquired the lock was free.
+	LockContended = "contended" // LockContended the lock was held, and it was acquired after waiting.
+	LockFailure   = "failure"   // LockFailure the lock couldn't be acquired.
+)
+
+var (
+	lockMetricsOnce sync.Once
+	lockWait        metric.Float64Histogram // lockWait time waiting to acquire the lock of a node.
+	lockContention  metric.Int64Counter     // lockContention number of times the lock of a node was already held.
+)
+
+// initLockMetrics creates the instruments of the node locks only once.
+func initLockMetrics() {
+	lockMetricsOnce.Do(func() {
+		var err error
+		lockWait, err = meter.Float64Histogram(
+			"node_lock_wait_seconds",
+			metric.WithDescription("Torch - Time waiting to acquire the lock of a node"),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			log.Error("Error creating metric node_lock_wait_seconds: ", err)
+		}
+
+		lockContention, err = meter.Int64Counter(
+			"node_lock_contention_total",
+			metric.WithDescription("Torch - Number of times the lock of a node was already held"),
+		)
+		if err != nil {
+			log.Error("Error creating metric node_lock_contention_total: ", err)
+		}
+	})
+}
+
+// ObserveNodeLock records the time waiting to acquire the lock of a node by its result, and if it was already held.
+// The node name is not an attribute, so the series don't grow with the nodes.
+func ObserveNodeLock(wait time.Duration, contended bool, err error) {
+	initLockMetrics()
+
+	outcome := LockAcquired
+	switch {
+	case err != nil:
+		outcome = LockFailure
+	case contended:
+		outcome = LockContended
+	}
+	if lockWait != nil {
+		lockWait.Record(context.Background(), wait.Seconds(), metric.WithAttributes(attribute.String("result", outcome)))
+	}
+	if contended && lockContention != nil {
+		lockContention.Add(context.Background(), 1)
+	}
+}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -13,6 +14,26 @@ import (
 // Get the meter from the global meter provider with the name "torch".
 var meter = otel.GetMeterProvider().Meter("torch")
 
+var (
+	blockHeightMetricsOnce sync.Once
+	blockHeightGauge       metric.Float64ObservableGauge // blockHeightGauge first block of the chain.
+
+	blockHeightMu sync.Mutex
+	blockHeight   *BlockHeight // blockHeight current first block, nil until it's known.
+
+	loadBalancerMetricsOnce sync.Once
+	loadBalancersGauge      metric.Float64ObservableGauge // loadBalancersGauge public IPs of the load balancers.
+
+	loadBalancersMu sync.Mutex
+	loadBalancers   = map[string][]LoadBalancer{} // loadBalancers current IPs by namespace/name of the service.
+
+	consensusNodeMetricsOnce sync.Once
+	consensusNodeGauge       metric.Float64ObservableGauge // consensusNodeGauge ids of the consensus nodes.
+
+	consensusNodesMu sync.Mutex
+	consensusNodes   = map[string]ConsensusNodeMetric{} // consensusNodes current ids by node name.
+)
+
 // MultiAddrs represents the information for a Multi Addresses.
 type MultiAddrs struct {
 	ServiceName string  // ServiceName Name of the service associated with the Multi Addresses.
@@ -22,61 +43,58 @@ type MultiAddrs struct {
 	Value       float64 // Value to be observed for the Multi Addresses.
 }
 
-// WithMetricsMultiAddress creates a callback function to observe metrics for multiple Multi Addresses.
-func WithMetricsMultiAddress(multiAddrs []MultiAddrs) error {
-	log.Info("registering metric: ", multiAddrs)
-	// Create a Float64ObservableGauge named "Multi Addresses" with a description for the metric.
-	multiAddressesGauge, err := meter.Float64ObservableGauge(
-		"multiaddr",
-		metric.WithDescription("Torch - MultiAddresses"),
-	)
-	if err != nil {
-		log.Fatalf(err.Error())
-		return err
-	}
+// BlockHeight represents the information for the block height 1.
+type BlockHeight struct {
+	ServiceName       string // ServiceName Name of the service associated with the multi-address.
+	BlockHeight       string // BlockHeight height of the block.
+	EarliestBlockTime string // EarliestBlockTime time when the chain was created.
+	DaysRunning       int    // DaysRunning number of days since the chain was created.
+	Namespace         string // Namespace where the service is deployed.
+}
 
-	// Define the callback function that will be called periodically to observe metrics.
-	callback := func(ctx context.Context, observer metric.Observer) error {
-		for _, ma := range multiAddrs {
-			// Create labels with attributes for each Multi Addresses.
-			labels := metric.WithAttributes(
-				attribute.String("service_name", ma.ServiceName),
-				attribute.String("node_name", ma.NodeName),
-				attribute.String("multiaddress", ma.MultiAddr),
-				attribute.String("namespace", ma.Namespace),
-			)
-			// Observe the float64 value for the current Multi Addresses with the associated labels.
-			observer.ObserveFloat64(multiAddressesGauge, ma.Value, labels)
+// initBlockHeightMetrics creates the gauge of block_height_1 and registers its callback only once.
+func initBlockHeightMetrics() {
+	blockHeightMetricsOnce.Do(func() {
+		var err error
+		blockHeightGauge, err = meter.Float64ObservableGauge(
+			"block_height_1",
+			metric.WithDescription("Torch - BlockHeight"),
+		)
+		if err != nil {
+			log.Error("Error creating metric block_height_1: ", err)
+			return
 		}
 
-		return nil
-	}
+		// Define the callback function that will be called periodically to observe metrics.
+		callback := func(ctx context.Context, observer metric.Observer) error {
+			blockHeightMu.Lock()
+			defer blockHeightMu.Unlock()
 
-	// Register the callback with the meter and the Float64ObservableGauge.
-	_, err = meter.RegisterCallback(callback, multiAddressesGauge)
-	return err
-}
+			if blockHeight == nil {
+				return nil
+			}
+			observer.ObserveFloat64(blockHeightGauge, 1, metric.WithAttributes(
+				attribute.String("service_name", blockHeight.ServiceName),
+				attribute.String("block_height_1", blockHeight.BlockHeight),
+				attribute.String("earliest_block_time", blockHeight.EarliestBlockTime),
+				attribute.Int("days_running", blockHeight.DaysRunning),
+				attribute.String("namespace", blockHeight.Namespace),
+			))
+			return nil
+		}
 
-// BlockHeight represents the information for the block height 1.
-type BlockHeight struct {
-	ServiceName string  // ServiceName Name of the service associated with the multi-address.
-	BlockHeight string  // BlockHeight height of the block.
-	Value       float64 // Value to be observed for the multi-address.
+		// Register the callback with the meter and the Float64ObservableGauge.
+		if _, err := meter.RegisterCallback(callback, blockHeightGauge); err != nil {
+			log.Error("Error registering the callback of block_height_1: ", err)
+		}
+	})
 }
 
-// WithMetricsBlockHeight creates a callback function to observe metrics for block_height_1.
+// WithMetricsBlockHeight replaces the first block exposed in block_height_1.
 // consensus-node:26657/block?height=1
-func WithMetricsBlockHeight(blockHeight, earliestBlockTime, serviceName, namespace string) error {
-	log.Info("registering metric: ", blockHeight)
-	// Create a Float64ObservableGauge named "block_height_1" with a description for the metric.
-	blockHeightGauge, err := meter.Float64ObservableGauge(
-		"block_height_1",
-		metric.WithDescription("Torch - BlockHeight"),
-	)
-	if err != nil {
-		log.Fatalf(err.Error())
-		return err
-	}
+func WithMetricsBlockHeight(height, earliestBlockTime, serviceName, namespace string) error {
+	log.Info("registering metric: ", height)
+	initBlockHeightMetrics()
 
 	// Calculate the days that the chain is live.
 	daysRunning, err := calculateDaysDifference(earliestBlockTime)
@@ -85,25 +103,17 @@ func WithMetricsBlockHeight(blockHeight, earliestBlockTime, serviceName, namespa
 		return err
 	}
 
-	callback := func(ctx context.Context, observer metric.Observer) error {
-		// Define the callback function that will be called periodically to observe metrics.
-		// Create labels with attributes for each block_height_1.
-		labels := metric.WithAttributes(
-			attribute.String("service_name", serviceName),
-			attribute.String("block_height_1", blockHeight),
-			attribute.String("earliest_block_time", earliestBlockTime),
-			attribute.Int("days_running", daysRunning),
-			attribute.String("namespace", namespace),
-		)
-		// Observe the float64 value for the current block_height_1 with the associated labels.
-		observer.ObserveFloat64(blockHeightGauge, 1, labels)
-
-		return nil
+	blockHeightMu.Lock()
+	defer blockHeightMu.Unlock()
+	blockHeight = &BlockHeight{
+		ServiceName:       serviceName,
+		BlockHeight:       height,
+		EarliestBlockTime: earliestBlockTime,
+		DaysRunning:       daysRunning,
+		Namespace:         namespace,
 	}
 
-	// Register the callback with the meter and the Float64ObservableGauge.
-	_, err = meter.RegisterCallback(callback, blockHeightGauge)
-	return err
+	return nil
 }
 
 // calculateDaysDifference based on the date received, returns the number of days since this day.
@@ -131,38 +141,81 @@ type LoadBalancer struct {
 	Value            float64 // Value to be observed for the load balancer.
 }
 
-// WithMetricsLoadBalancer creates a callback function to observe metrics for multiple load balancers.
-func WithMetricsLoadBalancer(loadBalancers []LoadBalancer) error {
-	// Create a Float64ObservableGauge named "load_balancer" with a description for the metric.
-	loadBalancersGauge, err := meter.Float64ObservableGauge(
-		"load_balancer",
-		metric.WithDescription("Torch - Load Balancers"),
-	)
-	if err != nil {
-		log.Fatalf(err.Error())
-		return err
-	}
+// initLoadBalancerMetrics creates the gauge of the load balancers and registers its callback only once.
+func initLoadBalancerMetrics() {
+	loadBalancerMetricsOnce.Do(func() {
+		var err error
+		loadBalancersGauge, err = meter.Float64ObservableGauge(
+			"load_balancer",
+			metric.WithDescription("Torch - Load Balancers"),
+		)
+		if err != nil {
+			log.Error("Error creating metric load_balancer: ", err)
+			return
+		}
 
-	// Define the callback function that will be called periodically to observe metrics.
-	callback := func(ctx context.Context, observer metric.Observer) error {
-		for _, lb := range loadBalancers {
-			// Create labels with attributes for each load balancer.
-			labels := metric.WithAttributes(
-				attribute.String("service_name", lb.ServiceName),
-				attribute.String("load_balancer_name", lb.LoadBalancerName),
-				attribute.String("load_balancer_ip", lb.LoadBalancerIP),
-				attribute.String("namespace", lb.Namespace),
-			)
-			// Observe the float64 value for the current load balancer with the associated labels.
-			observer.ObserveFloat64(loadBalancersGauge, lb.Value, labels)
+		// Define the callback function that will be called periodically to observe metrics.
+		callback := func(ctx context.Context, observer metric.Observer) error {
+			loadBalancersMu.Lock()
+			defer loadBalancersMu.Unlock()
+
+			for _, lbs := range loadBalancers {
+				for _, lb := range lbs {
+					// Create labels with attributes for each load balancer.
+					observer.ObserveFloat64(loadBalancersGauge, lb.Value, metric.WithAttributes(
+						attribute.String("service_name", lb.ServiceName),
+						attribute.String("load_balancer_name", lb.LoadBalancerName),
+						attribute.String("load_balancer_ip", lb.LoadBalancerIP),
+						attribute.String("namespace", lb.Namespace),
+					))
+				}
+			}
+			return nil
+		}
+
+		// Register the callback with the meter and the Float64ObservableGauge.
+		if _, err := meter.RegisterCallback(callback, loadBalancersGauge); err != nil {
+			log.Error("Error registering the callback of load_balancer: ", err)
 		}
+	})
+}
+
+// loadBalancerKey returns the key of the service of the load balancer in the state.
+func loadBalancerKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// SetLoadBalancers replaces all the load balancers exposed, the ones which are not in the list are removed.
+func SetLoadBalancers(lbs []LoadBalancer) {
+	initLoadBalancerMetrics()
 
-		return nil
+	state := make(map[string][]LoadBalancer)
+	for _, lb := range lbs {
+		key := loadBalancerKey(lb.Namespace, lb.LoadBalancerName)
+		state[key] = append(state[key], lb)
 	}
 
-	// Register the callback with the meter and the Float64ObservableGauge.
-	_, err = meter.RegisterCallback(callback, loadBalancersGauge)
-	return err
+	loadBalancersMu.Lock()
+	defer loadBalancersMu.Unlock()
+	loadBalancers = state
+}
+
+// UpdateLoadBalancer replaces the IPs of the load balancer service, if there are no IPs, it's removed.
+func UpdateLoadBalancer(namespace, name string, lbs []LoadBalancer) {
+	initLoadBalancerMetrics()
+
+	loadBalancersMu.Lock()
+	defer loadBalancersMu.Unlock()
+	if len(lbs) == 0 {
+		delete(loadBalancers, loadBalancerKey(namespace, name))
+		return
+	}
+	loadBalancers[loadBalancerKey(namespace, name)] = lbs
+}
+
+// RemoveLoadBalancer removes the load balancer service, so its series are not exposed anymore.
+func RemoveLoadBalancer(namespace, name string) {
+	UpdateLoadBalancer(namespace, name, nil)
 }
 
 // ConsensusNodeMetric represents the information for consensus node metrics.
@@ -172,34 +225,60 @@ type ConsensusNodeMetric struct {
 	Namespace string // Namespace of the node.
 }
 
-// RegisterConsensusNodeMetric creates and registers metrics for consensus nodes.
+// initConsensusNodeMetrics creates the gauge of the consensus node ids and registers its callback only once.
+func initConsensusNodeMetrics() {
+	consensusNodeMetricsOnce.Do(func() {
+		var err error
+		consensusNodeGauge, err = meter.Float64ObservableGauge(
+			"consensus_node_ids_metric",
+			metric.WithDescription("Metric for Consensus Node IDs"),
+		)
+		if err != nil {
+			log.Error("Error creating metric consensus_node_ids_metric: ", err)
+			return
+		}
+
+		// Define the callback function that will be called periodically to observe metrics.
+		callback := func(ctx context.Context, observer metric.Observer) error {
+			consensusNodesMu.Lock()
+			defer consensusNodesMu.Unlock()
+
+			for _, node := range consensusNodes {
+				observer.ObserveFloat64(consensusNodeGauge, 1, metric.WithAttributes(
+					attribute.String("node_name", node.NodeName),
+					attribute.String("node_id", node.NodeID),
+					attribute.String("namespace", node.Namespace),
+				))
+			}
+			return nil
+		}
+
+		// Register the callback with the meter and the ObservableGauge.
+		if _, err := meter.RegisterCallback(callback, consensusNodeGauge); err != nil {
+			log.Error("Error registering the callback of consensus_node_ids_metric: ", err)
+		}
+	})
+}
+
+// RegisterConsensusNodeMetric adds the id of the consensus node, replacing the previous one of the node.
 func RegisterConsensusNodeMetric(nodeID, nodeName, namespace string) error {
 	log.Info("Registering metric for consensus node: ", nodeName)
+	initConsensusNodeMetrics()
 
-	// Create an ObservableGauge for consensus node metrics.
-	consensusNodeGauge, err := meter.Float64ObservableGauge(
-		"consensus_node_ids_metric",
-		metric.WithDescription("Metric for Consensus Node IDs"),
-	)
-	if err != nil {
-		log.Fatalf("Error creating metric: ", err)
-		return err
+	consensusNodesMu.Lock()
+	defer consensusNodesMu.Unlock()
+	consensusNodes[nodeName] = ConsensusNodeMetric{
+		NodeName:  nodeName,
+		NodeID:    nodeID,
+		Namespace: namespace,
 	}
 
-	callback := func(ctx context.Context, observer metric.Observer) error {
-		// Define the callback function that will be called periodically to observe metrics.
-		labels := metric.WithAttributes(
-			attribute.String("node_name", nodeName),
-			attribute.String("node_id", nodeID),
-			attribute.String("namespace", namespace),
-		)
-		// Observe the value for current consensus node metrics with associated labels.
-		observer.ObserveFloat64(consensusNodeGauge, 1, labels)
-
-		return nil
-	}
+	return nil
+}
 
-	// Register the callback with the meter and the ObservableGauge.
-	_, err = meter.RegisterCallback(callback, consensusNodeGauge)
-	return err
+// UnregisterConsensusNodeMetric removes the id of the consensus node, so its series is not exposed anymore.
+func UnregisterConsensusNodeMetric(nodeName string) {
+	consensusNodesMu.Lock()
+	defer consensusNodesMu.Unlock()
+	delete(consensusNodes, nodeName)
 }
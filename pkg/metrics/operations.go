@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	IDGenerationSuccess = "success" // IDGenerationSuccess the node returned its id.
+	IDGenerationEmpty   = "empty"   // IDGenerationEmpty the node didn't return an id, it's not ready yet.
+	IDGenerationFailure = "failure" // IDGenerationFailure the id couldn't be requested to the node.
+)
+
+var (
+	operationMetricsOnce sync.Once
+	httpRequests         metric.Int64Counter     // httpRequests number of requests by route, method and status.
+	httpDuration         metric.Float64Histogram // httpDuration time serving the requests by route, method and status.
+	execCommands         metric.Int64Counter     // execCommands number of commands executed in the pods.
+	execFailures         metric.Int64Counter     // execFailures number of commands executed in the pods which failed.
+	execDuration         metric.Float64Histogram // execDuration time executing the commands in the pods.
+	idGenerations        metric.Int64Counter     // idGenerations number of attempts to get the id of the nodes, by result.
+	redisDuration        metric.Float64Histogram // redisDuration time of the Redis operations, by command and result.
+	watcherRestarts      metric.Int64Counter     // watcherRestarts number of times the Kubernetes watchers were restarted.
+)
+
+// initOperationMetrics creates the instruments of the operations of Torch only once.
+func initOperationMetrics() {
+	operationMetricsOnce.Do(func() {
+		var err error
+		httpRequests, err = meter.Int64Counter(
+			"http_requests_total",
+			metric.WithDescription("Torch - Number of HTTP requests, by route, method and status"),
+		)
+		if err != nil {
+			log.Error("Error creating metric http_requests_total: ", err)
+		}
+		httpDuration, err = meter.Float64Histogram(
+			"http_request_duration_seconds",
+			metric.WithDescription("Torch - Time serving the HTTP requests, by route, method and status"),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			log.Error("Error creating metric http_request_duration_seconds: ", err)
+		}
+
+		execCommands, err = meter.Int64Counter(
+			"exec_commands_total",
+			metric.WithDescription("Torch - Number of commands executed in the pods, by pod and container"),
+		)
+		if err != nil {
+			log.Error("Error creating metric exec_commands_total: ", err)
+		}
+		execFailures, err = meter.Int64Counter(
+			"exec_failures_total",
+			metric.WithDescription("Torch - Number of commands executed in the pods which failed, by pod and container"),
+		)
+		if err != nil {
+			log.Error("Error creating metric exec_failures_total: ", err)
+		}
+		execDuration, err = meter.Float64Histogram(
+			"exec_duration_seconds",
+			metric.WithDescription("Torch - Time executing the commands in the pods, by pod and container"),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			log.Error("Error creating metric exec_duration_seconds: ", err)
+		}
+
+		idGenerations, err = meter.Int64Counter(
+			"node_id_generation_total",
+			metric.WithDescription("Torch - Number of attempts to get the id of the nodes, by family and result"),
+		)
+		if err != nil {
+			log.Error("Error creating metric node_id_generation_total: ", err)
+		}
+
+		redisDuration, err = meter.Float64Histogram(
+			"redis_operation_duration_seconds",
+			metric.WithDescription("Torch - Time of the Redis operations, by command and result"),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			log.Error("Error creating metric redis_operation_duration_seconds: ", err)
+		}
+
+		watcherRestarts, err = meter.Int64Counter(
+			"watcher_restarts_total",
+			metric.WithDescription("Torch - Number of times the Kubernetes watchers were restarted, by watcher"),
+		)
+		if err != nil {
+			log.Error("Error creating metric watcher_restarts_total: ", err)
+		}
+	})
+}
+
+// result returns the label of the result of the operation using the error.
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// ObserveHTTPRequest records the request served by the route, the route is the path template to avoid a series by node.
+func ObserveHTTPRequest(route, method string, status int, duration time.Duration) {
+	initOperationMetrics()
+
+	labels := metric.WithAttributes(
+		attribute.String("route", route),
+		attribute.String("method", method),
+		attribute.String("status", strconv.Itoa(status)),
+	)
+	if httpRequests != nil {
+		httpRequests.Add(context.Background(), 1, labels)
+	}
+	if httpDuration != nil {
+		httpDuration.Record(context.Background(), duration.Seconds(), labels)
+	}
+}
+
+// ObserveExec records the command executed in the container of the pod, and if it failed.
+func ObserveExec(pod, container string, duration time.Duration, err error) {
+	initOperationMetrics()
+
+	labels := metric.WithAttributes(
+		attribute.String("pod", pod),
+		attribute.String("container", container),
+	)
+	if execCommands != nil {
+		execCommands.Add(context.Background(), 1, labels)
+	}
+	if err != nil && execFailures != nil {
+		execFailures.Add(context.Background(), 1, labels)
+	}
+	if execDuration != nil {
+		execDuration.Record(context.Background(), duration.Seconds(), labels)
+	}
+}
+
+// ObserveIDGeneration records the attempt to get the id of a node of the family, the result is taken from the id and
+// the error.
+func ObserveIDGeneration(family, id string, err error) {
+	initOperationMetrics()
+
+	outcome := IDGenerationSuccess
+	switch {
+	case err != nil:
+		outcome = IDGenerationFailure
+	case id == "":
+		outcome = IDGenerationEmpty
+	}
+	if idGenerations != nil {
+		idGenerations.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("family", family),
+			attribute.String("result", outcome),
+		))
+	}
+}
+
+// ObserveRedisOperation records the time of the Redis command, the keys which don't exist are not failures.
+func ObserveRedisOperation(command string, duration time.Duration, err error) {
+	initOperationMetrics()
+	if redisDuration != nil {
+		redisDuration.Record(context.Background(), duration.Seconds(), metric.WithAttributes(
+			attribute.String("command", command),
+			attribute.String("result", result(err)),
+		))
+	}
+}
+
+// IncWatcherRestarts increments the number of times the watcher was restarted.
+func IncWatcherRestarts(watcher string) {
+	initOperationMetrics()
+	if watcherRestarts != nil {
+		watcherRestarts.Add(context.Background(), 1, metric.WithAttributes(attribute.String("watcher", watcher)))
+	}
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// counterValue returns the value of the counter with the attributes received.
+func counterValue(t *testing.T, name string, attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+
+	sum, ok := collectMetric(t, name).(metricdata.Sum[int64])
+	if !ok {
+		return 0
+	}
+	want := attribute.NewSet(attrs...)
+	for _, dp := range sum.DataPoints {
+		if dp.Attributes.Equals(&want) {
+			return dp.Value
+		}
+	}
+	return 0
+}
+
+// TestOperationMetrics checks the increase of the counters recorded by the operations of Torch.
+func TestOperationMetrics(t *testing.T) {
+	errExec := errors.New("error: command failed")
+
+	tests := []struct {
+		name    string
+		observe func()
+		metric  string
+		attrs   []attribute.KeyValue
+		want    int64
+	}{
+		{
+			name: "Case 1: HTTP requests by route and status",
+			observe: func() {
+				ObserveHTTPRequest("/api/v1/noId/{nodeName}", http.MethodGet, http.StatusOK, time.Millisecond)
+				ObserveHTTPRequest("/api/v1/noId/{nodeName}", http.MethodGet, http.StatusOK, time.Millisecond)
+				ObserveHTTPRequest("/api/v1/noId/{nodeName}", http.MethodGet, http.StatusNotFound, time.Millisecond)
+			},
+			metric: "http_requests_total",
+			attrs: []attribute.KeyValue{
+				attribute.String("route", "/api/v1/noId/{nodeName}"),
+				attribute.String("method", http.MethodGet),
+				attribute.String("status", "200"),
+			},
+			want: 2,
+		},
+		{
+			name: "Case 2: Exec failures by pod and container",
+			observe: func() {
+				ObserveExec("da-bridge-1-0", "da", time.Second, nil)
+				ObserveExec("da-bridge-1-0", "da", time.Second, errExec)
+			},
+			metric: "exec_failures_total",
+			attrs: []attribute.KeyValue{
+				attribute.String("pod", "da-bridge-1-0"),
+				attribute.String("container", "da"),
+			},
+			want: 1,
+		},
+		{
+			name: "Case 3: ID generation of a node which is not ready",
+			observe: func() {
+				ObserveIDGeneration("da", "", nil)
+				ObserveIDGeneration("da", "12D3KooW", nil)
+			},
+			metric: "node_id_generation_total",
+			attrs: []attribute.KeyValue{
+				attribute.String("family", "da"),
+				attribute.String("result", IDGenerationEmpty),
+			},
+			want: 1,
+		},
+		{
+			name: "Case 4: Watcher restarts",
+			observe: func() {
+				IncWatcherRestarts("services")
+				IncWatcherRestarts("services")
+			},
+			metric: "watcher_restarts_total",
+			attrs:  []attribute.KeyValue{attribute.String("watcher", "services")},
+			want:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the counters are cumulative, so only the increase is checked
+			before := counterValue(t, tt.metric, tt.attrs...)
+			tt.observe()
+			if got := counterValue(t, tt.metric, tt.attrs...) - before; got != tt.want {
+				t.Errorf("%s%v = %d, want %d", tt.metric, tt.attrs, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestObserveRedisOperation checks that the latency of the Redis commands is recorded by command and result.
+func TestObserveRedisOperation(t *testing.T) {
+	ObserveRedisOperation("get", time.Millisecond, nil)
+	ObserveRedisOperation("get", time.Millisecond, errors.New("error: connection refused"))
+
+	histogram, ok := collectMetric(t, "redis_operation_duration_seconds").(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("redis_operation_duration_seconds is not exposed")
+	}
+	if got := len(histogram.DataPoints); got != 2 {
+		t.Errorf("series of redis_operation_duration_seconds = %d, want 2", got)
+	}
+}
+
+// TestObserveNodeLock checks that the lock wait is recorded by result, without a series by node.
+func TestObserveNodeLock(t *testing.T) {
+	before := counterValue(t, "node_lock_contention_total")
+	ObserveNodeLock(time.Millisecond, false, nil)
+	ObserveNodeLock(time.Second, true, nil)
+	ObserveNodeLock(time.Second, false, errors.New("error: lock timeout"))
+	if got := counterValue(t, "node_lock_contention_total") - before; got != 1 {
+		t.Errorf("node_lock_contention_total = %d, want 1", got)
+	}
+
+	histogram, ok := collectMetric(t, "node_lock_wait_seconds").(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("node_lock_wait_seconds is not exposed")
+	}
+	results := map[string]bool{}
+	for _, dp := range histogram.DataPoints {
+		if dp.Attributes.Len() != 1 {
+			t.Errorf("attributes of node_lock_wait_seconds = %v, want only the result", dp.Attributes.ToSlice())
+		}
+		value, _ := dp.Attributes.Value("result")
+		results[value.AsString()] = true
+	}
+	for _, result := range []string{LockAcquired, LockContended, LockFailure} {
+		if !results[result] {
+			t.Errorf("node_lock_wait_seconds has no series for the result [%s]", result)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RedisPoolStats represents the stats of the Redis connection pool.
+type RedisPoolStats struct {
+	Hits       uint32 // Hits number of times a free connection was found in the pool.
+	Misses     uint32 // Misses number of times a free connection was NOT found in the pool.
+	Timeouts   uint32 // Timeouts number of times a wait timeout occurred.
+	TotalConns uint32 // TotalConns number of total connections in the pool.
+	IdleConns  uint32 // IdleConns number of idle connections in the pool.
+	StaleConns uint32 // StaleConns number of stale connections removed from the pool.
+}
+
+// WithMetricsRedisPool registers the metrics of the Redis connection pool, the stats are read from the function
+// received every time that the metrics are collected, so it has to be called only once.
+func WithMetricsRedisPool(stats func() RedisPoolStats) error {
+	log.Info("registering metrics: redis_pool")
+
+	totalConns, err := meter.Int64ObservableGauge(
+		"redis_pool_total_conns",
+		metric.WithDescription("Torch - Redis pool total connections"),
+	)
+	if err != nil {
+		return err
+	}
+	idleConns, err := meter.Int64ObservableGauge(
+		"redis_pool_idle_conns",
+		metric.WithDescription("Torch - Redis pool idle connections"),
+	)
+	if err != nil {
+		return err
+	}
+	staleConns, err := meter.Int64ObservableCounter(
+		"redis_pool_stale_conns",
+		metric.WithDescription("Torch - Redis pool stale connections removed"),
+	)
+	if err != nil {
+		return err
+	}
+	hits, err := meter.Int64ObservableCounter(
+		"redis_pool_hits",
+		metric.WithDescription("Torch - Redis pool hits"),
+	)
+	if err != nil {
+		return err
+	}
+	misses, err := meter.Int64ObservableCounter(
+		"redis_pool_misses",
+		metric.WithDescription("Torch - Redis pool misses"),
+	)
+	if err != nil {
+		return err
+	}
+	timeouts, err := meter.Int64ObservableCounter(
+		"redis_pool_timeouts",
+		metric.WithDescription("Torch - Redis pool timeouts"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Define the callback function that will be called periodically to observe metrics.
+	callback := func(ctx context.Context, observer metric.Observer) error {
+		s := stats()
+		observer.ObserveInt64(totalConns, int64(s.TotalConns))
+		observer.ObserveInt64(idleConns, int64(s.IdleConns))
+		observer.ObserveInt64(staleConns, int64(s.StaleConns))
+		observer.ObserveInt64(hits, int64(s.Hits))
+		observer.ObserveInt64(misses, int64(s.Misses))
+		observer.ObserveInt64(timeouts, int64(s.Timeouts))
+		return nil
+	}
+
+	// Register the callback with the meter and the instruments.
+	_, err = meter.RegisterCallback(callback, totalConns, idleConns, staleConns, hits, misses, timeouts)
+	return err
+}
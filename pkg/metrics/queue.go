@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	queueMetricsOnce sync.Once
+	queueEnqueued    metric.Int64Counter     // queueEnqueued number of nodes added to the queues.
+	queueProcessed   metric.Int64Counter     // queueProcessed number of nodes processed by the queues, by result.
+	queueFailed      metric.Int64Counter     // queueFailed number of nodes which reached the max number of attempts.
+	queueRetryDelay  metric.Float64Histogram // queueRetryDelay time between a failed attempt and the next one.
+	queueDepth       metric.Int64ObservableGauge
+
+	queueDepthMu        sync.Mutex
+	queueDepthProviders = map[string]func(context.Context) map[string]int64{}
+)
+
+// initQueueMetrics creates the instruments of the queues and registers the callback of the depth only once.
+func initQueueMetrics() {
+	queueMetricsOnce.Do(func() {
+		var err error
+		queueEnqueued, err = meter.Int64Counter(
+			"queue_enqueued_total",
+			metric.WithDescription("Torch - Number of nodes added to the queue"),
+		)
+		if err != nil {
+			log.Error("Error creating metric queue_enqueued_total: ", err)
+		}
+		queueProcessed, err = meter.Int64Counter(
+			"queue_processed_total",
+			metric.WithDescription("Torch - Number of nodes processed by the queue, by result"),
+		)
+		if err != nil {
+			log.Error("Error creating metric queue_processed_total: ", err)
+		}
+		queueFailed, err = meter.Int64Counter(
+			"queue_failed_total",
+			metric.WithDescription("Torch - Number of nodes which reached the max number of attempts"),
+		)
+		if err != nil {
+			log.Error("Error creating metric queue_failed_total: ", err)
+		}
+		queueRetryDelay, err = meter.Float64Histogram(
+			"queue_retry_latency_seconds",
+			metric.WithDescription("Torch - Time between a failed attempt of a node and the next one"),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			log.Error("Error creating metric queue_retry_latency_seconds: ", err)
+		}
+
+		queueDepth, err = meter.Int64ObservableGauge(
+			"queue_depth",
+			metric.WithDescription("Torch - Number of nodes in the queue, by state"),
+		)
+		if err != nil {
+			log.Error("Error creating metric queue_depth: ", err)
+			return
+		}
+
+		// Define the callback function that will be called periodically to observe metrics.
+		callback := func(ctx context.Context, observer metric.Observer) error {
+			queueDepthMu.Lock()
+			defer queueDepthMu.Unlock()
+
+			for queue, provider := range queueDepthProviders {
+				for state, value := range provider(ctx) {
+					observer.ObserveInt64(queueDepth, value, metric.WithAttributes(
+						attribute.String("queue", queue),
+						attribute.String("state", state),
+					))
+				}
+			}
+			return nil
+		}
+
+		// Register the callback with the meter and the Int64ObservableGauge.
+		if _, err := meter.RegisterCallback(callback, queueDepth); err != nil {
+			log.Error("Error registering the callback of queue_depth: ", err)
+		}
+	})
+}
+
+// RegisterQueueDepth sets the function which returns the number of nodes by state of the queue,
+// it's called every time that the metrics are collected.
+func RegisterQueueDepth(queue string, provider func(context.Context) map[string]int64) {
+	initQueueMetrics()
+
+	queueDepthMu.Lock()
+	defer queueDepthMu.Unlock()
+	queueDepthProviders[queue] = provider
+}
+
+// IncQueueEnqueued increments the number of nodes added to the queue.
+func IncQueueEnqueued(queue string) {
+	initQueueMetrics()
+	if queueEnqueued != nil {
+		queueEnqueued.Add(context.Background(), 1, metric.WithAttributes(attribute.String("queue", queue)))
+	}
+}
+
+// ObserveQueueProcessed increments the number of nodes processed by the queue, using the error as result.
+func ObserveQueueProcessed(queue string, err error) {
+	initQueueMetrics()
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	if queueProcessed != nil {
+		queueProcessed.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("queue", queue),
+			attribute.String("result", result),
+		))
+	}
+}
+
+// IncQueueFailed increments the number of nodes which reached the max number of attempts.
+func IncQueueFailed(queue string) {
+	initQueueMetrics()
+	if queueFailed != nil {
+		queueFailed.Add(context.Background(), 1, metric.WithAttributes(attribute.String("queue", queue)))
+	}
+}
+
+// ObserveQueueRetryLatency records the time between the failed attempt of a node and the next one.
+func ObserveQueueRetryLatency(queue string, latency time.Duration) {
+	initQueueMetrics()
+	if queueRetryDelay != nil {
+		queueRetryDelay.Record(context.Background(), latency.Seconds(), metric.WithAttributes(attribute.String("queue", queue)))
+	}
+}
@@ -1,17 +1,69 @@
 package metrics
 
 import (
+	"context"
+	"sync"
+
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
-// Declare a slice to hold multiple Multi Addresses metrics.
-var multiAddresses []MultiAddrs
+var (
+	multiAddrMetricsOnce sync.Once
+	multiAddressesGauge  metric.Float64ObservableGauge // multiAddressesGauge multi addresses of the DA nodes.
+
+	multiAddressesMu sync.Mutex
+	multiAddresses   = map[string]MultiAddrs{} // multiAddresses current multi address by node name.
+)
+
+// initMultiAddrMetrics creates the gauge of the multi addresses and registers its callback only once.
+func initMultiAddrMetrics() {
+	multiAddrMetricsOnce.Do(func() {
+		var err error
+		multiAddressesGauge, err = meter.Float64ObservableGauge(
+			"multiaddr",
+			metric.WithDescription("Torch - MultiAddresses"),
+		)
+		if err != nil {
+			log.Error("Error creating metric multiaddr: ", err)
+			return
+		}
+
+		// Define the callback function that will be called periodically to observe metrics.
+		callback := func(ctx context.Context, observer metric.Observer) error {
+			multiAddressesMu.Lock()
+			defer multiAddressesMu.Unlock()
+
+			for _, ma := range multiAddresses {
+				// Create labels with attributes for each Multi Addresses.
+				observer.ObserveFloat64(multiAddressesGauge, ma.Value, metric.WithAttributes(
+					attribute.String("service_name", ma.ServiceName),
+					attribute.String("node_name", ma.NodeName),
+					attribute.String("multiaddress", ma.MultiAddr),
+					attribute.String("namespace", ma.Namespace),
+				))
+			}
+			return nil
+		}
+
+		// Register the callback with the meter and the Float64ObservableGauge.
+		if _, err := meter.RegisterCallback(callback, multiAddressesGauge); err != nil {
+			log.Error("Error registering the callback of multiaddr: ", err)
+		}
+	})
+}
 
-// MultiAddrExists checks if a given MultiAddr already exists in the multiAddresses slice.
-// It returns true if the MultiAddr already exists, and false otherwise.
+// MultiAddrExists checks if a given MultiAddr is already exposed by any node.
 func MultiAddrExists(multiAddr string) bool {
+	multiAddressesMu.Lock()
+	defer multiAddressesMu.Unlock()
+	return multiAddrExists(multiAddr)
+}
+
+// multiAddrExists checks if the MultiAddr is in the state, the caller must hold multiAddressesMu.
+func multiAddrExists(multiAddr string) bool {
 	for _, addr := range multiAddresses {
-		// Compare each MultiAddr in the slice with the provided multiAddr.
 		if addr.MultiAddr == multiAddr {
 			return true
 		}
@@ -19,23 +71,38 @@ func MultiAddrExists(multiAddr string) bool {
 	return false
 }
 
-// RegisterMetric adds a new Multi Addresses metric to the multiAddresses slice.
-// Before adding, it checks if the MultiAddr already exists in the slice using MultiAddrExists function.
-// If the MultiAddr already exists, it logs a message and skips the addition.
-// Otherwise, it appends the new Multi Addresses to the slice and registers the updated metrics.
+// RegisterMetric adds the Multi Addresses of the node, if the MultiAddr is already exposed, it skips the addition.
 func RegisterMetric(m MultiAddrs) {
-	// Check if the MultiAddr already exists in the array
-	if MultiAddrExists(m.MultiAddr) {
-		log.Info("MultiAddr already exists in the metrics array: ", m.NodeName, " ", m.MultiAddr)
+	initMultiAddrMetrics()
+
+	multiAddressesMu.Lock()
+	defer multiAddressesMu.Unlock()
+
+	// Check if the MultiAddr already exists
+	if multiAddrExists(m.MultiAddr) {
+		log.Info("MultiAddr already exists in the metrics: ", m.NodeName, " ", m.MultiAddr)
 		return
 	}
+	multiAddresses[m.NodeName] = m
+}
+
+// UpdateMetric replaces the Multi Addresses metrics of the node with the new one.
+func UpdateMetric(m MultiAddrs) {
+	initMultiAddrMetrics()
 
-	// Append the new MultiAddr to the array
-	multiAddresses = append(multiAddresses, m)
+	multiAddressesMu.Lock()
+	defer multiAddressesMu.Unlock()
+	multiAddresses[m.NodeName] = m
+}
 
-	// Register the metric
-	err := WithMetricsMultiAddress(multiAddresses)
-	if err != nil {
-		log.Printf("Failed to update metrics: %v", err)
+// UnregisterMetric removes the Multi Addresses metrics of the node, so the series is not exposed anymore.
+func UnregisterMetric(nodeName string) {
+	multiAddressesMu.Lock()
+	defer multiAddressesMu.Unlock()
+
+	if _, ok := multiAddresses[nodeName]; !ok {
+		return
 	}
+	log.Info("Unregistering metric for node: ", nodeName)
+	delete(multiAddresses, nodeName)
 }
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// testReader reads the metrics of the tests, the meter of the package delegates to the first provider set, so all
+// the tests share it.
+var testReader = func() *sdkmetric.ManualReader {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	return reader
+}()
+
+// collectMetric returns the data of the metric, or nil if it's not exposed.
+func collectMetric(t *testing.T, name string) metricdata.Aggregation {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := testReader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data
+			}
+		}
+	}
+	return nil
+}
+
+// collectSeries returns the number of series exposed by the gauge.
+func collectSeries(t *testing.T, name string) int {
+	t.Helper()
+
+	if gauge, ok := collectMetric(t, name).(metricdata.Gauge[float64]); ok {
+		return len(gauge.DataPoints)
+	}
+	return 0
+}
+
+// TestMetricsState checks that the updates don't duplicate the series, and the removed entries are not exposed.
+func TestMetricsState(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric string
+		update func()
+		want   int
+	}{
+		{
+			name:   "Case 1: Register the multi addresses of two nodes",
+			metric: "multiaddr",
+			update: func() {
+				RegisterMetric(MultiAddrs{NodeName: "da-bridge-1-0", MultiAddr: "/dns/da-bridge-1/tcp/2121/p2p/a", Value: 1})
+				RegisterMetric(MultiAddrs{NodeName: "da-full-1-0", MultiAddr: "/dns/da-full-1/tcp/2121/p2p/b", Value: 1})
+				RegisterMetric(MultiAddrs{NodeName: "da-full-1-0", MultiAddr: "/dns/da-full-1/tcp/2121/p2p/b", Value: 1})
+			},
+			want: 2,
+		},
+		{
+			name:   "Case 2: Update the multi address of a node",
+			metric: "multiaddr",
+			update: func() {
+				UpdateMetric(MultiAddrs{NodeName: "da-full-1-0", MultiAddr: "/dns/da-full-1/tcp/2121/p2p/c", Value: 1})
+			},
+			want: 2,
+		},
+		{
+			name:   "Case 3: Unregister a node",
+			metric: "multiaddr",
+			update: func() {
+				UnregisterMetric("da-full-1-0")
+				UnregisterMetric("da-light-1-0")
+			},
+			want: 1,
+		},
+		{
+			name:   "Case 4: Set the load balancers",
+			metric: "load_balancer",
+			update: func() {
+				SetLoadBalancers([]LoadBalancer{
+					{LoadBalancerName: "lb-1", LoadBalancerIP: "10.0.0.1", Namespace: "ns", Value: 1},
+					{LoadBalancerName: "lb-1", LoadBalancerIP: "10.0.0.2", Namespace: "ns", Value: 1},
+					{LoadBalancerName: "lb-2", LoadBalancerIP: "10.0.0.3", Namespace: "ns", Value: 1},
+				})
+			},
+			want: 3,
+		},
+		{
+			name:   "Case 5: Update and remove the load balancers",
+			metric: "load_balancer",
+			update: func() {
+				for i := 0; i < 3; i++ {
+					UpdateLoadBalancer("ns", "lb-1", []LoadBalancer{
+						{LoadBalancerName: "lb-1", LoadBalancerIP: "10.0.0.1", Namespace: "ns", Value: 1},
+					})
+				}
+				RemoveLoadBalancer("ns", "lb-2")
+			},
+			want: 1,
+		},
+		{
+			name:   "Case 6: Register and unregister the consensus nodes",
+			metric: "consensus_node_ids_metric",
+			update: func() {
+				_ = RegisterConsensusNodeMetric("id-1", "consensus-full-1", "ns")
+				_ = RegisterConsensusNodeMetric("id-2", "consensus-full-1", "ns")
+				_ = RegisterConsensusNodeMetric("id-3", "consensus-full-2", "ns")
+				UnregisterConsensusNodeMetric("consensus-full-2")
+			},
+			want: 1,
+		},
+		{
+			name:   "Case 7: Replace the first block",
+			metric: "block_height_1",
+			update: func() {
+				for _, hash := range []string{"A", "B"} {
+					if err := WithMetricsBlockHeight(hash, "2023-01-01T00:00:00Z", "consensus", "ns"); err != nil {
+						t.Fatalf("WithMetricsBlockHeight() error = %v", err)
+					}
+				}
+			},
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.update()
+			if got := collectSeries(t, tt.metric); got != tt.want {
+				t.Errorf("series of %s = %d, want %d", tt.metric, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package nodes
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/logging"
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+var (
+	// ConnectivityInterval time between the checks of the peers of the nodes, CONNECTIVITY_INTERVAL env var in seconds.
+	ConnectivityInterval = time.Duration(getEnvInt("CONNECTIVITY_INTERVAL", 60)) * time.Second
+	// ConnectivityGracePeriod time that the expected peers can be missing before the node is flagged,
+	// CONNECTIVITY_GRACE_PERIOD env var in seconds.
+	ConnectivityGracePeriod = time.Duration(getEnvInt("CONNECTIVITY_GRACE_PERIOD", 300)) * time.Second
+
+	connectivityVerifier = NewConnectivityVerifier(fetchPeers) // connectivityVerifier checks the peers of the nodes.
+)
+
+// Connectivity represents the expected peers of a node which are connected.
+type Connectivity struct {
+	Node         string     `json:"node"`                   // Node name.
+	Expected     []string   `json:"expected"`               // Expected peers of the node, from its connections.
+	Connected    []string   `json:"connected"`              // Connected expected peers which are connected.
+	Missing      []string   `json:"missing"`                // Missing expected peers which are not connected.
+	Ratio        float64    `json:"ratio"`                  // Ratio connected/expected, 1 if the node doesn't expect any peer.
+	Flagged      bool       `json:"flagged"`                // Flagged true if some peers are missing for longer than the grace period.
+	MissingSince *time.Time `json:"missingSince,omitempty"` // MissingSince first check where some peers were missing.
+	Error        string     `json:"error,omitempty"`        // Error found getting the peers of the node.
+	CheckedAt    time.Time  `json:"checkedAt"`              // CheckedAt last time the node was checked.
+}
+
+// expectedPeer represents a peer that the node should be connected to.
+type expectedPeer struct {
+	name string // name of the node, or its multi address.
+	id   string // id of the peer, empty if it's not stored in the DB yet.
+}
+
+// ConnectivityVerifier compares the peers connected to the nodes with the connections of the config.
+type ConnectivityVerifier struct {
+	mu         sync.Mutex
+	results    map[string]Connectivity                                       // results last result by node name.
+	fetchPeers func(ctx context.Context, peer config.Peer) ([]string, error) // fetchPeers returns the ids of the peers connected.
+	now        func() time.Time                                              // now returns the current time.
+}
+
+// NewConnectivityVerifier returns a verifier which gets the peers of the nodes with the function received.
+func NewConnectivityVerifier(fetchPeers func(ctx context.Context, peer config.Peer) ([]string, error)) *ConnectivityVerifier {
+	return &ConnectivityVerifier{
+		results:    make(map[string]Connectivity),
+		fetchPeers: fetchPeers,
+		now:        time.Now,
+	}
+}
+
+// Run verifies all the nodes of the config on every interval, until the context is done.
+func (v *ConnectivityVerifier) Run(ctx context.Context, cfg config.MutualPeersConfig, red *redis.RedisClient, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Connectivity verifier stopped")
+			return
+		case <-ticker.C:
+			for _, mutualPeer := range cfg.MutualPeers {
+				for _, peer := range mutualPeer.Peers {
+					if ctx.Err() != nil {
+						return
+					}
+					result := v.VerifyNode(ctx, peer, cfg, red)
+					if result.Flagged {
+						logging.ForNode(ctx, peer).WithFields(log.Fields{
+							"missing":      result.Missing,
+							"missingSince": result.MissingSince,
+						}).Warn("The node is not connected to its peers")
+					}
+				}
+			}
+		}
+	}
+}
+
+// VerifyNode gets the peers connected to the node, and compares them with the ids of its connections. The node is
+// flagged when some peers are missing for longer than the grace period.
+func (v *ConnectivityVerifier) VerifyNode(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) Connectivity {
+	result := Connectivity{
+		Node:      peer.NodeName,
+		Expected:  []string{},
+		Connected: []string{},
+		Missing:   []string{},
+		CheckedAt: v.now(),
+	}
+
+	v.mu.Lock()
+	previous, ok := v.results[peer.NodeName]
+	v.mu.Unlock()
+	if ok {
+		result.MissingSince = previous.MissingSince
+	}
+	defer func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.results[peer.NodeName] = result
+	}()
+
+	nodeType, err := ResolveNodeType(peer)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	peer = nodeType.SetDefaults(peer)
+
+	expected, err := expectedPeers(ctx, nodeType, peer, cfg, red)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	var connected map[string]bool
+	if len(expected) > 0 {
+		ids, err := v.fetchPeers(ctx, peer)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		connected = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			connected[id] = true
+		}
+	}
+
+	for _, e := range expected {
+		result.Expected = append(result.Expected, e.name)
+		if e.id != "" && connected[e.id] {
+			result.Connected = append(result.Connected, e.name)
+		} else {
+			result.Missing = append(result.Missing, e.name)
+		}
+	}
+
+	c := metrics.PeerConnectivity{
+		NodeName:  peer.NodeName,
+		Namespace: peer.Namespace,
+		Connected: len(result.Connected),
+		Expected:  len(result.Expected),
+	}
+	result.Ratio = c.Ratio()
+	metrics.SetPeerConnectivity(c)
+
+	if len(result.Missing) == 0 {
+		result.MissingSince = nil
+		return result
+	}
+	if result.MissingSince == nil {
+		since := result.CheckedAt
+		result.MissingSince = &since
+	}
+	result.Flagged = result.CheckedAt.Sub(*result.MissingSince) >= ConnectivityGracePeriod
+
+	return result
+}
+
+// Results returns the last result of each node, sorted by node name.
+func (v *ConnectivityVerifier) Results() []Connectivity {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	results := make([]Connectivity, 0, len(v.results))
+	for _, result := range v.results {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Node < results[j].Node })
+
+	return results
+}
+
+// RunConnectivityVerifier verifies the peers of the nodes of the config periodically, until the context is done.
+func RunConnectivityVerifier(ctx context.Context, cfg config.MutualPeersConfig, red *redis.RedisClient) {
+	log.Info("Verifying the peers of the nodes every [", ConnectivityInterval, "]")
+	connectivityVerifier.Run(ctx, cfg, red, ConnectivityInterval)
+}
+
+// ConnectivitySnapshot returns the last result of the verification of each node.
+func ConnectivitySnapshot() []Connectivity {
+	return connectivityVerifier.Results()
+}
+
+// expectedPeers returns the peers that the node should be connected to, with the ids stored in the DB or written in
+// the multi addresses of the config. The DA nodes using env vars connect to a consensus node, which is not a peer.
+func expectedPeers(
+	ctx context.Context,
+	nodeType NodeType,
+	peer config.Peer,
+	cfg config.MutualPeersConfig,
+	red *redis.RedisClient,
+) ([]expectedPeer, error) {
+	if nodeType.Family() == FamilyDA && peer.ConnectsAsEnvVar {
+		return nil, nil
+	}
+
+	connectsTo := peer.ConnectsTo
+	if nodeType.Name() == TypeDALight && len(connectsTo) == 0 {
+		connectsTo = TrustedPeerPool(peer.NodeName, cfg)
+	}
+
+	var expected []expectedPeer
+	for _, conn := range connectsTo {
+		// the multi addresses of the config can contain more than one node
+		if strings.HasPrefix(conn, "/") {
+			for _, ma := range strings.Split(conn, ",") {
+				expected = append(expected, expectedPeer{name: ma, id: peerID(ma)})
+			}
+			continue
+		}
+
+		value, err := redis.CheckIfNodeExistsInDB(red, ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		expected = append(expected, expectedPeer{name: conn, id: peerID(value)})
+	}
+
+	return expected, nil
+}
+
+// peerID returns the id of the multi address, or the value received if it's not a multi address.
+func peerID(value string) string {
+	if i := strings.LastIndex(value, "/p2p/"); i >= 0 {
+		return value[i+len("/p2p/"):]
+	}
+	return value
+}
+
+// fetchPeers returns the ids of the peers connected to the node, using p2p.Peers for the DA nodes, and net_info for
+// the consensus nodes.
+func fetchPeers(ctx context.Context, peer config.Peer) ([]string, error) {
+	nodeType, err := ResolveNodeType(peer)
+	if err != nil {
+		return nil, err
+	}
+	if nodeType.Family() == FamilyDA {
+		return FetchP2PPeers(ctx, peer)
+	}
+
+	host := peer.ServiceName
+	if host == "" {
+		host = peer.NodeName
+	}
+	return ConsensusNodePeers(host)
+}
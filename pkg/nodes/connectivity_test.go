@@ -0,0 +1,93 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+)
+
+func TestVerifyNode(t *testing.T) {
+	s := miniredis.RunT(t)
+	s.Set("da-bridge-1-0", "12D3KooWBridge1")
+	s.Set("da-bridge-2-0", "/dns/da-bridge-2/tcp/2121/p2p/12D3KooWBridge2")
+	red := redis.NewRedisClient(s.Addr(), "", 0)
+
+	peer := config.Peer{
+		NodeName:   "da-full-1-0",
+		NodeType:   TypeDAFull,
+		ConnectsTo: []string{"da-bridge-1-0", "da-bridge-2-0", "da-bridge-3-0", "/ip4/10.0.0.1/tcp/2121/p2p/12D3KooWExt"},
+	}
+
+	tests := []struct {
+		name          string
+		peers         []string
+		fetchErr      error
+		elapsed       time.Duration
+		wantConnected []string
+		wantMissing   []string
+		wantRatio     float64
+		wantFlagged   bool
+		wantErr       bool
+	}{
+		{
+			name:          "Case 1: Some peers are missing",
+			peers:         []string{"12D3KooWBridge1", "12D3KooWExt"},
+			wantConnected: []string{"da-bridge-1-0", "/ip4/10.0.0.1/tcp/2121/p2p/12D3KooWExt"},
+			wantMissing:   []string{"da-bridge-2-0", "da-bridge-3-0"},
+			wantRatio:     0.5,
+		},
+		{
+			name:          "Case 2: Peers still missing after the grace period",
+			peers:         []string{"12D3KooWBridge1", "12D3KooWBridge2", "12D3KooWExt"},
+			elapsed:       ConnectivityGracePeriod,
+			wantConnected: []string{"da-bridge-1-0", "da-bridge-2-0", "/ip4/10.0.0.1/tcp/2121/p2p/12D3KooWExt"},
+			wantMissing:   []string{"da-bridge-3-0"},
+			wantRatio:     0.75,
+			wantFlagged:   true,
+		},
+		{
+			name:     "Case 3: Peers can't be fetched",
+			fetchErr: errors.New("connection refused"),
+			elapsed:  ConnectivityGracePeriod,
+			wantErr:  true,
+		},
+	}
+
+	start := time.Now()
+	v := NewConnectivityVerifier(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v.now = func() time.Time { return start.Add(tt.elapsed) }
+			v.fetchPeers = func(ctx context.Context, peer config.Peer) ([]string, error) {
+				return tt.peers, tt.fetchErr
+			}
+
+			got := v.VerifyNode(context.Background(), peer, config.MutualPeersConfig{}, red)
+			if (got.Error != "") != tt.wantErr {
+				t.Fatalf("VerifyNode() error = %s, wantErr %v", got.Error, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Connected, tt.wantConnected) {
+				t.Errorf("VerifyNode() connected = %v, want %v", got.Connected, tt.wantConnected)
+			}
+			if !reflect.DeepEqual(got.Missing, tt.wantMissing) {
+				t.Errorf("VerifyNode() missing = %v, want %v", got.Missing, tt.wantMissing)
+			}
+			if got.Ratio != tt.wantRatio {
+				t.Errorf("VerifyNode() ratio = %v, want %v", got.Ratio, tt.wantRatio)
+			}
+			if got.Flagged != tt.wantFlagged {
+				t.Errorf("VerifyNode() flagged = %v, want %v", got.Flagged, tt.wantFlagged)
+			}
+		})
+	}
+}
@@ -1,36 +1,158 @@
 package nodes
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
 	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/logging"
+	"github.com/jrmanes/torch/pkg/metrics"
 )
 
 var (
-	consContainerSetupName = "consensus-setup"         // consContainerSetupName initContainer that we use to configure the nodes.
-	consContainerName      = "consensus"               // consContainerName container name which the pod runs.
-	namespace              = k8s.GetCurrentNamespace() // namespace of the node.
+	consContainerSetupName = "consensus-setup"                        // consContainerSetupName initContainer that we use to configure the nodes.
+	consContainerName      = "consensus"                              // consContainerName container name which the pod runs.
+	namespace              = k8s.GetCurrentNamespace()                // namespace of the node.
+	persistentPeersFile    = "/home/celestia/config/PERSISTENT_PEERS" // persistentPeersFile file with the persistent_peers of the consensus nodes.
+	seedsFile              = "/home/celestia/config/SEEDS"            // seedsFile file with the seeds of the consensus nodes.
 )
 
-// SetConsNodeDefault sets all the default values in case they are empty
+// SetConsNodeDefault sets all the default values of the consensus nodes in case they are empty
 func SetConsNodeDefault(peer config.Peer) config.Peer {
-	if peer.ContainerSetupName == "" {
-		peer.ContainerSetupName = consContainerSetupName
+	return setDefaults(peer, consContainerSetupName, consContainerName)
+}
+
+// SetupConsensusNodeWithPeers writes the persistent_peers and the seeds of the consensus node, built from its
+// connectsTo and seeds as nodeID@host:26656.
+func SetupConsensusNodeWithPeers(ctx context.Context, peer config.Peer, red *redis.RedisClient) error {
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	// lock the node while it's configured
+	ctx, unlock, err := lockNode(ctx, peer.NodeName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	persistentPeers, seeds, err := BuildConsensusPeers(ctx, peer, red)
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]string{
+		persistentPeersFile: persistentPeers,
+		seedsFile:           seeds,
 	}
-	if peer.ContainerName == "" {
-		peer.ContainerName = consContainerName
+	for _, file := range []string{persistentPeersFile, seedsFile} {
+		_, err := k8s.RunRemoteCommand(
+			ctx,
+			peer.NodeName,
+			peer.ContainerSetupName,
+			k8s.GetCurrentNamespace(),
+			k8s.WriteToFile(strings.Join(files[file], ","), file),
+		)
+		if err != nil {
+			logging.ForNode(ctx, peer).WithError(err).Error(errRemoteCommand)
+			return err
+		}
 	}
-	if peer.Namespace == "" {
-		peer.Namespace = namespace
+
+	logging.ForNode(ctx, peer).WithFields(log.Fields{
+		"persistentPeers": persistentPeers,
+		"seeds":           seeds,
+	}).Info("Consensus node peers written")
+	k8s.RecordNodeEvent(ctx, peer.NodeName, peer.Namespace, corev1.EventTypeNormal, k8s.ReasonPeersWritten,
+		"Torch wrote the persistent_peers [%s] and the seeds [%s]",
+		strings.Join(persistentPeers, ","), strings.Join(seeds, ","))
+
+	return nil
+}
+
+// BuildConsensusPeers returns the persistent_peers and the seeds of the consensus node, using the ids stored in the
+// DB, or fetching them from the nodes.
+func BuildConsensusPeers(ctx context.Context, peer config.Peer, red *redis.RedisClient) ([]string, []string, error) {
+	persistentPeers := make([]string, 0, len(peer.ConnectsTo))
+	for index, nodeName := range peer.ConnectsTo {
+		host := consensusHost(peer, index)
+		id, err := ConsensusNodeID(ctx, red, nodeName, host, peer.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		persistentPeers = append(persistentPeers, consensusAddress(id, host))
+	}
+
+	seeds := make([]string, 0, len(peer.Seeds))
+	for _, nodeName := range peer.Seeds {
+		id, err := ConsensusNodeID(ctx, red, nodeName, nodeName, peer.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		seeds = append(seeds, consensusAddress(id, nodeName))
+	}
+
+	return persistentPeers, seeds, nil
+}
+
+// ConsensusNodeID returns the id of the consensus node stored in the DB, if it's not there, it gets the id from the
+// status of the node using the host, and stores it. The event is recorded in the namespace received.
+func ConsensusNodeID(ctx context.Context, red *redis.RedisClient, nodeName, host, namespace string) (string, error) {
+	logger := logging.ForNodeName(ctx, nodeName).WithField(logging.FieldNodeType, FamilyConsensus)
+	id, err := redis.CheckIfNodeExistsInDB(red, ctx, nodeName)
+	if err != nil {
+		logger.WithError(err).Error("Error checking if the consensus node exists in the DB")
+		return "", err
+	}
+	if id != "" {
+		return id, nil
+	}
+
+	id, err = ConsensusNodesIDs(host)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", fmt.Errorf("error: the id of the consensus node [%s] is empty", nodeName)
+	}
+
+	logger = logger.WithField("id", id)
+	logger.Info("Adding consensus node id to Redis")
+	if err := redis.SetNodeId(nodeName, red, ctx, id); err != nil {
+		logger.WithError(err).Error("Error saving the consensus node id")
+		return "", err
+	}
+	k8s.RecordNodeEvent(ctx, nodeName, namespace, corev1.EventTypeNormal, k8s.ReasonNodeIDGenerated,
+		"Torch stored the node id %s", id)
+
+	return id, nil
+}
+
+// consensusHost returns the host of the connection in the index, using the DNS record if it's specified.
+func consensusHost(peer config.Peer, index int) string {
+	if index < len(peer.DnsConnections) {
+		return peer.DnsConnections[index]
 	}
-	return peer
+	return peer.ConnectsTo[index]
+}
+
+// consensusAddress returns the address of the consensus node as nodeID@host:26656.
+func consensusAddress(id, host string) string {
+	return id + "@" + host + ":" + consensusP2PPort
 }
 
 // GenesisHash connects to the specified consensus node, makes a request to the API,
@@ -42,15 +164,16 @@ func GenesisHash(consensusNode string) (string, string, error) {
 		return "", "", err
 	}
 
+	logger := consensusLogger(consensusNode)
 	blockIDHash, ok := jsonResponse["result"].(map[string]interface{})["block_id"].(map[string]interface{})["hash"].(string)
 	if !ok {
-		log.Error("Unable to access .block_id.hash")
+		logger.Error("Unable to access .block_id.hash")
 		return "", "", errors.New("error accessing block ID hash")
 	}
 
 	blockTime, ok := jsonResponse["result"].(map[string]interface{})["block"].(map[string]interface{})["header"].(map[string]interface{})["time"].(string)
 	if !ok {
-		log.Error("Unable to access .block.header.time")
+		logger.Error("Unable to access .block.header.time")
 		return "", "", errors.New("error accessing block time")
 	}
 
@@ -63,45 +186,126 @@ func ConsensusNodesIDs(consensusNode string) (string, error) {
 	url := fmt.Sprintf("http://%s:26657/status?", consensusNode)
 	jsonResponse, err := makeAPIRequest(url)
 	if err != nil {
+		metrics.ObserveIDGeneration(FamilyConsensus, "", err)
 		return "", err
 	}
 
-	nodeID, ok := jsonResponse["result"].(map[string]interface{})["node_info"].(map[string]interface{})["id"].(string)
-	if !ok {
-		log.Error("Unable to access .result.node_info.id")
-		return "", errors.New("error accessing node ID")
+	logger := consensusLogger(consensusNode)
+	result, _ := jsonResponse["result"].(map[string]interface{})
+	nodeInfo, _ := result["node_info"].(map[string]interface{})
+	nodeID, ok := nodeInfo["id"].(string)
+	if !ok || nodeID == "" {
+		logger.Error("Unable to access .result.node_info.id")
+		err := errors.New("error accessing node ID")
+		metrics.ObserveIDGeneration(FamilyConsensus, "", err)
+		return "", err
 	}
+	metrics.ObserveIDGeneration(FamilyConsensus, nodeID, nil)
 
-	log.Info("Consensus Node [", consensusNode, "] ID: [", nodeID, "]")
+	logger.WithField("id", nodeID).Info("Consensus node ID")
 
 	return nodeID, nil
 }
 
+// ConsensusNodeHeight connects to the specified consensus node, and retrieves the height and the time of its latest
+// block from the status response.
+func ConsensusNodeHeight(consensusNode string) (int64, time.Time, error) {
+	url := fmt.Sprintf("http://%s:26657/status?", consensusNode)
+	jsonResponse, err := makeAPIRequest(url)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	result, _ := jsonResponse["result"].(map[string]interface{})
+	syncInfo, _ := result["sync_info"].(map[string]interface{})
+	latestHeight, ok := syncInfo["latest_block_height"].(string)
+	if !ok {
+		consensusLogger(consensusNode).Error("Unable to access .result.sync_info.latest_block_height")
+		return 0, time.Time{}, errors.New("error accessing the latest block height")
+	}
+	height, err := strconv.ParseInt(latestHeight, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("error parsing the latest block height [%s]: %w", latestHeight, err)
+	}
+
+	// the time is optional, the height is still valid without it
+	var blockTime time.Time
+	if latestTime, ok := syncInfo["latest_block_time"].(string); ok {
+		blockTime, _ = time.Parse(time.RFC3339Nano, latestTime)
+	}
+
+	return height, blockTime, nil
+}
+
+// ConsensusNodePeers connects to the specified consensus node, and retrieves the ids of its peers from the net_info
+// response.
+func ConsensusNodePeers(consensusNode string) ([]string, error) {
+	url := fmt.Sprintf("http://%s:26657/net_info?", consensusNode)
+	jsonResponse, err := makeAPIRequest(url)
+	if err != nil {
+		return nil, err
+	}
+	if jsonResponse == nil {
+		return nil, fmt.Errorf("error: empty response from the node [%s]", consensusNode)
+	}
+
+	logger := consensusLogger(consensusNode)
+	result, ok := jsonResponse["result"].(map[string]interface{})
+	if !ok {
+		logger.Error("Unable to access .result")
+		return nil, errors.New("error accessing the peers")
+	}
+	peers, _ := result["peers"].([]interface{})
+
+	ids := make([]string, 0, len(peers))
+	for _, p := range peers {
+		peer, _ := p.(map[string]interface{})
+		nodeInfo, _ := peer["node_info"].(map[string]interface{})
+		id, ok := nodeInfo["id"].(string)
+		if !ok {
+			logger.Error("Unable to access .result.peers[].node_info.id")
+			return nil, errors.New("error accessing the peer ID")
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// consensusLogger returns the logger of the consensus node, used by the requests to its API which don't have a context.
+func consensusLogger(consensusNode string) *log.Entry {
+	return log.WithFields(log.Fields{
+		logging.FieldNode:     consensusNode,
+		logging.FieldNodeType: FamilyConsensus,
+	})
+}
+
 // makeAPIRequest handles the common task of making an HTTP request to a given URL
 // and parsing the JSON response. It returns a map representing the JSON response or an error.
 func makeAPIRequest(url string) (map[string]interface{}, error) {
+	logger := log.WithField("url", url)
 	response, err := http.Get(url)
 	if err != nil {
-		log.Error("Error making the request: ", err)
+		logger.WithError(err).Error("Error making the request")
 		return nil, err
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		log.Error("Non-OK response:", response.Status)
-		return nil, err
+		logger.WithField("status", response.Status).Error("Non-OK response")
+		return nil, fmt.Errorf("unexpected status requesting [%s]: %s", url, response.Status)
 	}
 
 	bodyBytes, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		log.Error("Error reading response body:", err)
+		logger.WithError(err).Error("Error reading response body")
 		return nil, err
 	}
 
 	var jsonResponse map[string]interface{}
 	err = json.Unmarshal(bodyBytes, &jsonResponse)
 	if err != nil {
-		log.Error("Error parsing JSON:", err)
+		logger.WithError(err).Error("Error parsing JSON")
 		return nil, err
 	}
 
@@ -1,10 +1,17 @@
 package nodes
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
+	"github.com/alicebob/miniredis/v2"
+
 	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
 )
 
 func TestSetConsNodeDefault(t *testing.T) {
@@ -63,3 +70,127 @@ func TestSetConsNodeDefault(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildConsensusPeers(t *testing.T) {
+	s := miniredis.RunT(t)
+	s.Set("consensus-validator-1", "a1b2c3")
+	s.Set("consensus-full-1", "d4e5f6")
+	s.Set("consensus-seed-1", "0a0b0c")
+	red := redis.NewRedisClient(s.Addr(), "", 0)
+
+	tests := []struct {
+		name                string
+		peer                config.Peer
+		wantPersistentPeers []string
+		wantSeeds           []string
+		wantErr             bool
+	}{
+		{
+			name: "Case 1: Persistent peers using the node names",
+			peer: config.Peer{
+				NodeName:   "consensus-full-2-0",
+				ConnectsTo: []string{"consensus-validator-1", "consensus-full-1"},
+			},
+			wantPersistentPeers: []string{"a1b2c3@consensus-validator-1:26656", "d4e5f6@consensus-full-1:26656"},
+			wantSeeds:           []string{},
+		},
+		{
+			name: "Case 2: Persistent peers using DNS and seeds",
+			peer: config.Peer{
+				NodeName:       "consensus-full-2-0",
+				ConnectsTo:     []string{"consensus-validator-1"},
+				DnsConnections: []string{"consensus-validator-1.celestia.svc.cluster.local"},
+				Seeds:          []string{"consensus-seed-1"},
+			},
+			wantPersistentPeers: []string{"a1b2c3@consensus-validator-1.celestia.svc.cluster.local:26656"},
+			wantSeeds:           []string{"0a0b0c@consensus-seed-1:26656"},
+		},
+		{
+			name: "Case 3: Node without connections",
+			peer: config.Peer{
+				NodeName: "consensus-full-2-0",
+			},
+			wantPersistentPeers: []string{},
+			wantSeeds:           []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			persistentPeers, seeds, err := BuildConsensusPeers(context.Background(), tt.peer, red)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildConsensusPeers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(persistentPeers, tt.wantPersistentPeers) {
+				t.Errorf("BuildConsensusPeers() persistentPeers = %v, want %v", persistentPeers, tt.wantPersistentPeers)
+			}
+			if !reflect.DeepEqual(seeds, tt.wantSeeds) {
+				t.Errorf("BuildConsensusPeers() seeds = %v, want %v", seeds, tt.wantSeeds)
+			}
+		})
+	}
+}
+
+func TestConsensusNodesIDs(t *testing.T) {
+	// the API of the consensus nodes listens on 26657
+	listener, err := net.Listen("tcp", "127.0.0.1:26657")
+	if err != nil {
+		t.Skipf("port of the consensus API not available: %v", err)
+	}
+
+	status, body := http.StatusOK, ""
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "Case 1: Node id in the status",
+			status: http.StatusOK,
+			body:   `{"result":{"node_info":{"id":"a1b2c3"}}}`,
+			want:   "a1b2c3",
+		},
+		{
+			name:    "Case 2: Non-OK response",
+			status:  http.StatusInternalServerError,
+			body:    `{}`,
+			wantErr: true,
+		},
+		{
+			name:    "Case 3: Status without the node info",
+			status:  http.StatusOK,
+			body:    `{"result":{}}`,
+			wantErr: true,
+		},
+		{
+			name:    "Case 4: Status without the result",
+			status:  http.StatusOK,
+			body:    `{"error":"not ready"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body = tt.status, tt.body
+			got, err := ConsensusNodesIDs("127.0.0.1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConsensusNodesIDs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ConsensusNodesIDs() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,58 +9,61 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	corev1 "k8s.io/api/core/v1"
+
 	"github.com/jrmanes/torch/config"
 	"github.com/jrmanes/torch/pkg/db/redis"
 	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/logging"
 	"github.com/jrmanes/torch/pkg/metrics"
 )
 
 const (
-	errRemoteCommand = "Error executing remote command: "
+	errRemoteCommand = "Error executing remote command"
 	timeoutDuration  = 60 * time.Second // timeoutDuration we specify the max time to run the func.
-	nodeIdMaxLength  = 52               // nodeIdMaxLength Specify the max length for the nodes ids.
 )
 
 var (
 	daContainerSetupName = "da-setup"                     // daContainerSetupName initContainer that we use to configure the nodes.
 	daContainerName      = "da"                           // daContainerName container name which the pod runs.
 	fPathDA              = "/tmp/celestia-config/TP-ADDR" // fPathDA path to the file where Torch will write.
-	ns                   = k8s.GetCurrentNamespace()      // ns namespace of the node.
 )
 
-// SetDaNodeDefault sets all the default values in case they are empty
+// SetDaNodeDefault sets all the default values of the DA nodes in case they are empty
 func SetDaNodeDefault(peer config.Peer) config.Peer {
-	if peer.ContainerSetupName == "" {
-		peer.ContainerSetupName = daContainerSetupName
-	}
-	if peer.ContainerName == "" {
-		peer.ContainerName = daContainerName
-	}
-	if peer.Namespace == "" {
-		peer.Namespace = ns
-	}
-	return peer
+	return setDefaults(peer, daContainerSetupName, daContainerName)
 }
 
-// SetupDANodeWithConnections configure a DA node with connections
-func SetupDANodeWithConnections(peer config.Peer) error {
-	red := redis.InitRedisConfig()
+// SetupDANodeWithConnections configure a DA node with connections, the connections in the config are reached with
+// their own settings.
+func SetupDANodeWithConnections(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) error {
 	// Create a new context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
 	connString := ""
 	addPrefix := true
 
 	// Make sure to call the cancel function to release resources when you're done
 	defer cancel()
 
+	// lock the node and the connections whose ids can be generated while it's configured, all of them are taken at
+	// once, so two nodes connected to each other don't wait for the lock that the other one holds
+	ctx, unlock, err := lockNodes(ctx, append(connectionNodes(peer), peer.NodeName)...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	logger := logging.ForNode(ctx, peer)
+
 	// read the connection list
 	for index, nodeName := range peer.ConnectsTo {
-		log.Info(peer.NodeName, " , connection: [", index, "] to node: [", nodeName, "]")
+		connLogger := logger.WithFields(log.Fields{"connection": nodeName, "index": index})
+		connLogger.Info("Configuring the connection of the node")
 
 		// checking the node in the DB first
 		ma, err := redis.CheckIfNodeExistsInDB(red, ctx, nodeName)
 		if err != nil {
-			log.Error("Error CheckIfNodeExistsInDB for full-node: [", peer.NodeName, "]", err)
+			connLogger.WithError(err).Error("Error checking if the connection exists in the DB")
 			return err
 		}
 
@@ -69,10 +72,10 @@ func SetupDANodeWithConnections(peer config.Peer) error {
 
 		// if the node is not in the db, then we generate it
 		if ma == "" {
-			log.Info("Node ", "["+nodeName+"]"+" NOT found in DB, let'nodeName generate it")
-			ma, err = GenerateNodeIdAndSaveIt(peer, peer.ConnectsTo[index], red, ctx)
+			connLogger.Info("Connection NOT found in DB, let's generate it")
+			ma, err = GenerateNodeIdAndSaveIt(peer, connectionPeer(cfg, peer, nodeName), red, ctx)
 			if err != nil {
-				log.Error("Error GenerateNodeIdAndSaveIt for full-node: [", peer.NodeName, "]", err)
+				connLogger.WithError(err).Error("Error generating the id of the connection")
 				return err
 			}
 		}
@@ -80,12 +83,12 @@ func SetupDANodeWithConnections(peer config.Peer) error {
 		// if we have the address already, lets continue the process, otherwise, means we couldn't get the node id
 		if ma != "" && addPrefix {
 			// adding the node prefix
-			ma, err = SetIdPrefix(peer, ma, index)
+			ma, err = SetIdPrefix(ctx, peer, cfg, ma, index)
 			if err != nil {
-				log.Error("Error SetIdPrefix for full-node: [", peer.NodeName, "]", err)
+				connLogger.WithError(err).Error("Error adding the prefix to the id of the connection")
 				return err
 			}
-			log.Info("Peer connection prefix: ", ma)
+			connLogger.WithField("multiAddr", ma).Info("Peer connection prefix")
 		}
 
 		// check the connection index and concatenate it in case we have more than one node
@@ -98,11 +101,11 @@ func SetupDANodeWithConnections(peer config.Peer) error {
 		// validate the MA, must start with /ip4/ || /dns/
 		if !strings.HasPrefix(ma, "/ip4/") && !strings.HasPrefix(ma, "/dns/") {
 			errorMessage := fmt.Sprintf("Error generating the MultiAddress, must begin with /ip4/ || /dns/: [%s]", ma)
-			log.Error(errorMessage)
+			connLogger.Error(errorMessage)
 			return errors.New(errorMessage)
 		}
 
-		log.Info("Registering metric for node: [", nodeName, "]")
+		connLogger.Info("Registering the metric of the connection")
 
 		// Register a multi-address metric
 		m := metrics.MultiAddrs{
@@ -117,24 +120,60 @@ func SetupDANodeWithConnections(peer config.Peer) error {
 		// get the command to write in a file and execute the command against the node
 		command := k8s.WriteToFile(connString, fPathDA)
 		output, err := k8s.RunRemoteCommand(
+			ctx,
 			peer.NodeName,
 			peer.ContainerSetupName,
 			k8s.GetCurrentNamespace(),
 			command)
 		if err != nil {
-			log.Error(errRemoteCommand, err)
+			logger.WithError(err).Error(errRemoteCommand)
 			return err
 		}
 
-		log.Info("MultiAddr for node ", peer.NodeName, " is: [", output, "]")
+		logger.WithField("multiAddr", output).Info("MultiAddr of the node written")
 
-		log.Info("Adding node to the queue: [", peer.NodeName, "]")
-		go AddToQueue(peer)
+		logger.Info("Adding node to the queue")
+		AddToQueue(ctx, peer)
+	}
+
+	if connString != "" {
+		k8s.RecordNodeEvent(ctx, peer.NodeName, peer.Namespace, corev1.EventTypeNormal, k8s.ReasonPeersWritten,
+			"Torch wrote the trusted peers to %s: %s", fPathDA, connString)
 	}
 
 	return nil
 }
 
+// connectionNodes returns the names of the nodes in the connections of the peer, skipping the multi addresses.
+func connectionNodes(peer config.Peer) []string {
+	var names []string
+	for index := range peer.ConnectsTo {
+		if _, addPrefix := VerifyAndUpdateMultiAddress(peer, index, "", true); addPrefix {
+			names = append(names, peer.ConnectsTo[index])
+		}
+	}
+	return names
+}
+
+// connectionPeer returns the peer of the connection from the config, with the defaults of its type, so its token,
+// store, type and containers are used. The connections which are not in the config are DA nodes in the namespace of
+// the peer, using its container, and their kind is taken from their names.
+func connectionPeer(cfg config.MutualPeersConfig, peer config.Peer, nodeName string) config.Peer {
+	if conn, ok := findPeer(cfg, nodeName); ok {
+		if t, err := ResolveNodeType(conn); err == nil {
+			return t.SetDefaults(conn)
+		}
+		return conn
+	}
+
+	return config.Peer{
+		NodeName:      nodeName,
+		NodeType:      FamilyDA,
+		Namespace:     peer.Namespace,
+		ContainerName: peer.ContainerName,
+	}
+}
+
 // VerifyAndUpdateMultiAddress checks if the configuration contains a Multi Address at the specified index
 // and updates it if found. It returns the verified Multi Address and a boolean indicating if an update was performed.
 func VerifyAndUpdateMultiAddress(peer config.Peer, index int, currentAddr string, addPrefix bool) (string, bool) {
@@ -148,83 +187,138 @@ func VerifyAndUpdateMultiAddress(peer config.Peer, index int, currentAddr string
 }
 
 // SetIdPrefix generates the prefix depending on dns or ip
-func SetIdPrefix(peer config.Peer, c string, i int) (string, error) {
+func SetIdPrefix(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, c string, i int) (string, error) {
 	// check if we are using DNS or IP
 	if len(peer.DnsConnections) > 0 {
 		c = "/dns/" + peer.DnsConnections[i] + "/tcp/2121/p2p/" + c
 	} else {
+		// use the address where the node listens, the ip is taken from the node itself if it's not available
+		target := connectionPeer(cfg, peer, peer.ConnectsTo[i])
+		// Create a new context with a timeout
+		ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
+		defer cancel()
+
+		logger := logging.ForNode(ctx, target)
+		info, err := FetchP2PInfo(ctx, target)
+		if addr := info.RoutableAddr(); err == nil && addr != "" {
+			logger.WithField("address", addr).Info("Listen address of the node")
+			return addr + "/p2p/" + c, nil
+		}
+
 		comm := k8s.GetNodeIP()
 		output, err := k8s.RunRemoteCommand(
-			peer.ConnectsTo[i],
-			peer.ContainerName,
-			k8s.GetCurrentNamespace(),
+			ctx,
+			target.NodeName,
+			target.ContainerName,
+			peerNamespace(target),
 			comm)
 		if err != nil {
-			log.Error(errRemoteCommand, err)
+			logger.WithError(err).Error(errRemoteCommand)
 			return "", err
 		}
-		log.Info("command - ip is: ", output)
+		logger.WithField("ip", output).Info("IP of the node")
 		c = output + c
 	}
 	return c, nil
 }
 
-// GenerateNodeIdAndSaveIt generates the node id and store it
+// GenerateNodeIdAndSaveIt generates the id of the connection of the pod and store it, the pod itself can be the
+// connection.
 func GenerateNodeIdAndSaveIt(
 	pod config.Peer,
-	connNode string,
+	conn config.Peer,
 	red *redis.RedisClient,
 	ctx context.Context,
 ) (string, error) {
-	// Generate the command and run it against the connection node + it's running container
-	command := k8s.CreateTrustedPeerCommand()
-	output, err := k8s.RunRemoteCommand(
-		connNode,
-		pod.ContainerName,
-		k8s.GetCurrentNamespace(),
-		command)
+	connNode := conn.NodeName
+	// lock the node while we generate its id
+	ctx, unlock, err := lockNode(ctx, connNode)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	output, err := GenerateNodeId(ctx, conn)
 	if err != nil {
-		log.Error(errRemoteCommand, err)
 		return "", err
 	}
 
 	// if the output of the generation is not empty, that means that we could generate the node id successfully, so let's
 	// store it into the DB.
 	if output != "" {
-		log.Info("Adding pod id to Redis: ", connNode, " [", output, "] ")
-
-		// check that the node id generate has the right length
-		output, err = TruncateString(output, nodeIdMaxLength)
-		if err != nil {
-			log.Error("Error TruncateString: ", err)
-			return "", err
-		}
+		logger := logging.ForNodeName(ctx, connNode).WithField("id", output)
+		logger.Info("Adding pod id to Redis")
 
 		// save node in redis
 		err = redis.SetNodeId(connNode, red, ctx, output)
 		if err != nil {
-			log.Error("Error SetNodeId: ", err)
+			logger.WithError(err).Error("Error saving the node id")
 			return "", err
 		}
-	} else {
-		log.Error("Output is empty for pod: ", " [", pod.NodeName, "] ")
-		return "", err
+		k8s.RecordNodeEvent(ctx, connNode, conn.Namespace, corev1.EventTypeNormal, k8s.ReasonNodeIDGenerated,
+			"Torch generated the node id %s", output)
 	}
 
 	return output, nil
 }
 
-// TruncateString receives and input and a maxLength and returns a string with the size specified.
-func TruncateString(input string, maxLength int) (string, error) {
-	if len(input) == maxLength {
-		return input, nil
+// RegenerateNodeId generates the node id again and overwrites the one stored, also, it updates the metric of the node.
+func RegenerateNodeId(
+	peer config.Peer,
+	red *redis.RedisClient,
+	ctx context.Context,
+) (string, error) {
+	// lock the node while we generate its id
+	ctx, unlock, err := lockNode(ctx, peer.NodeName)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	logger := logging.ForNode(ctx, peer)
+	output, err := GenerateNodeId(ctx, peer)
+	if err != nil {
+		return "", err
 	}
-	if len(input) < maxLength {
-		log.Error("Error: The node id received is not valid, too short: , ", " - [", len(input), "]", " - [", input, "]")
-		return input, errors.New("error: The node id received is not valid")
+	if output == "" {
+		logger.Error("The node id generated is empty")
+		return "", errors.New("error: the node id generated is empty")
 	}
 
-	log.Info("Node ID with bigger size found: ", input)
+	logger = logger.WithField("id", output)
+	logger.Info("Updating pod id in Redis")
+	err = redis.UpdateNodeId(peer.NodeName, red, ctx, output)
+	if err != nil {
+		logger.WithError(err).Error("Error updating the node id")
+		return "", err
+	}
+	k8s.RecordNodeEvent(ctx, peer.NodeName, peer.Namespace, corev1.EventTypeNormal, k8s.ReasonNodeIDGenerated,
+		"Torch regenerated the node id %s", output)
+
+	// Replace the multi-address metric of the node
+	metrics.UpdateMetric(metrics.MultiAddrs{
+		ServiceName: "torch",
+		NodeName:    peer.NodeName,
+		MultiAddr:   output,
+		Namespace:   peer.Namespace,
+		Value:       1,
+	})
+
+	return output, nil
+}
+
+// GenerateNodeId calls p2p.Info in the RPC of the node to get its node id, using its token or its container to mint
+// it if it's needed.
+func GenerateNodeId(ctx context.Context, target config.Peer) (string, error) {
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
+	defer cancel()
+
+	info, err := FetchP2PInfo(ctx, target)
+	metrics.ObserveIDGeneration(FamilyDA, info.ID, err)
+	if err != nil {
+		return "", err
+	}
 
-	return input[:maxLength], nil
+	return info.ID, nil
 }
@@ -1,8 +1,11 @@
 package nodes
 
 import (
+	"context"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jrmanes/torch/config"
 )
@@ -153,57 +156,114 @@ func TestSetDaNodeDefault(t *testing.T) {
 	}
 }
 
-func TestTruncateString(t *testing.T) {
-	type args struct {
-		input     string
-		maxLength int
+// TestLockMutualPeers checks that two nodes connected to each other can be configured at the same time, the setup
+// locks the node and its connections, and then the id of the connection is generated with the lock held.
+func TestLockMutualPeers(t *testing.T) {
+	peers := []config.Peer{
+		{NodeName: "da-bridge-1-0", NodeType: "da", ConnectsTo: []string{"da-full-1-0"}},
+		{NodeName: "da-full-1-0", NodeType: "da", ConnectsTo: []string{"da-bridge-1-0", "/dns/da-bridge-2/tcp/2121/p2p/a"}},
+	}
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 20; i++ {
+		var wg sync.WaitGroup
+		errs := make(chan error, len(peers))
+		for _, peer := range peers {
+			wg.Add(1)
+			go func(peer config.Peer) {
+				defer wg.Done()
+				setupCtx, unlock, err := lockNodes(ctx, append(connectionNodes(peer), peer.NodeName)...)
+				if err != nil {
+					errs <- err
+					return
+				}
+				defer unlock()
+
+				// give time to the other node to take its lock, like the checks done before generating the id
+				time.Sleep(time.Millisecond)
+
+				// the same lock that GenerateNodeIdAndSaveIt takes for the connection
+				for _, conn := range connectionNodes(peer) {
+					_, unlockConn, err := lockNode(setupCtx, conn)
+					if err != nil {
+						errs <- err
+						return
+					}
+					unlockConn()
+				}
+			}(peer)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Fatalf("lock error = %v", err)
+		}
+	}
+}
+
+func TestConnectionNodes(t *testing.T) {
+	t.Parallel()
+	peer := config.Peer{
+		NodeName:   "da-full-1-0",
+		ConnectsTo: []string{"da-bridge-1-0", "/ip4/192.168.1.100/tcp/2121/p2p/a", "da-bridge-2-0"},
+	}
+	want := []string{"da-bridge-1-0", "da-bridge-2-0"}
+	if got := connectionNodes(peer); !reflect.DeepEqual(got, want) {
+		t.Errorf("connectionNodes() = %v, want %v", got, want)
+	}
+}
+
+func TestConnectionPeer(t *testing.T) {
+	t.Parallel()
+	peer := config.Peer{NodeName: "da-full-1-0", NodeType: TypeDAFull, Namespace: "celestia", ContainerName: "da"}
+	cfg := config.MutualPeersConfig{
+		MutualPeers: []*config.MutualPeer{{Peers: []config.Peer{{
+			NodeName:        "celestia-node-0",
+			NodeType:        TypeDALight,
+			Namespace:       "light",
+			AuthTokenSecret: "light-token",
+			NodeStore:       "/data",
+		}}}},
 	}
+
 	tests := []struct {
-		name string
-		args args
-		want string
+		name     string
+		nodeName string
+		want     config.Peer
 	}{
 		{
-			name: "Case 1: Tests valid node ID: 12D3KooWPB3thXCYyr6Jid49d5DDaRL63inzVagaQswCcgUARg5W - 52",
-			args: args{
-				input:     "12D3KooWPB3thXCYyr6Jid49d5DDaRL63inzVagaQswCcgUARg5W",
-				maxLength: 52,
-			},
-			want: "12D3KooWPB3thXCYyr6Jid49d5DDaRL63inzVagaQswCcgUARg5W",
-		},
-		{
-			name: "Case 2: Tests not valid node ID.",
-			args: args{
-				input:     "12D3KooWPB3thXCYyr6Jid49d5DDaRL63inzVagaQswCcgUARg5W12D3KooWPB3thXCYyr6Jid49d5DDaRL63inzVagaQswCcgUARg5W",
-				maxLength: 52,
-			},
-			want: "12D3KooWPB3thXCYyr6Jid49d5DDaRL63inzVagaQswCcgUARg5W",
-		},
-		{
-			name: "Case 3: Tests valid node ID: 12D3KooWMGSh8pLvQYn5zYcdRhVfNAcMZrDt71iyq6eSVtrgjKb8 - 52",
-			args: args{
-				input:     "12D3KooWMGSh8pLvQYn5zYcdRhVfNAcMZrDt71iyq6eSVtrgjKb8",
-				maxLength: 52,
+			name:     "Case 1: Connection in the config",
+			nodeName: "celestia-node-0",
+			want: config.Peer{
+				NodeName:           "celestia-node-0",
+				NodeType:           TypeDALight,
+				Namespace:          "light",
+				ContainerName:      daContainerName,
+				ContainerSetupName: daContainerSetupName,
+				AuthTokenSecret:    "light-token",
+				NodeStore:          "/data",
 			},
-			want: "12D3KooWMGSh8pLvQYn5zYcdRhVfNAcMZrDt71iyq6eSVtrgjKb8",
 		},
 		{
-			name: "Case 4: Tests not valid node ID.",
-			args: args{
-				input:     "12D3KooWMGSh8pLvQYn5zYcdRhVfNAcMZrDt71iyq6eSVtrgjKb812D3KooWMGSh8pLvQYn5zYcdRhVfNAcMZrDt71iyq6eSVtrgjKb8",
-				maxLength: 52,
+			name:     "Case 2: Connection not in the config",
+			nodeName: "da-bridge-1-0",
+			want: config.Peer{
+				NodeName:      "da-bridge-1-0",
+				NodeType:      FamilyDA,
+				Namespace:     "celestia",
+				ContainerName: "da",
 			},
-			want: "12D3KooWMGSh8pLvQYn5zYcdRhVfNAcMZrDt71iyq6eSVtrgjKb8",
 		},
 	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got, err := TruncateString(tt.args.input, tt.args.maxLength); got != tt.want {
-				if err != nil {
-					t.Errorf("ERROR: TruncateString() = %v, want %v", got, tt.want)
-				}
-				t.Errorf("TruncateString() = %v, want %v", got, tt.want)
-
+			if got := connectionPeer(cfg, peer, tt.nodeName); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("connectionPeer() = %+v, want %+v", got, tt.want)
 			}
 		})
 	}
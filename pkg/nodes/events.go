@@ -0,0 +1,82 @@
+package nodes
+
+import (
+	"slices"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/logging"
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+// HandleNodeEvent keeps the multiaddr metrics in sync with the changes made by other replicas of Torch, the family
+// and the namespace of the node are taken from the config.
+func HandleNodeEvent(cfg config.MutualPeersConfig, event redis.NodeEvent) {
+	// the changes made by this replica are already applied
+	if event.IsLocal() {
+		return
+	}
+
+	family, ns := eventNode(cfg, event.NodeName)
+	logger := log.WithFields(log.Fields{
+		logging.FieldNode:      event.NodeName,
+		logging.FieldNamespace: ns,
+		"family":               family,
+		"source":               event.Source,
+		"action":               event.Action,
+	})
+	logger.Info("Node event received")
+
+	switch event.Action {
+	case redis.NodeCreated, redis.NodeUpdated:
+		// the consensus nodes don't have a multiaddr metric
+		if family == FamilyConsensus {
+			return
+		}
+		metrics.UpdateMetric(metrics.MultiAddrs{
+			ServiceName: "torch",
+			NodeName:    event.NodeName,
+			MultiAddr:   event.Value,
+			Namespace:   ns,
+			Value:       1,
+		})
+	case redis.NodeDeleted:
+		metrics.UnregisterMetric(event.NodeName)
+		metrics.UnregisterConsensusNodeMetric(event.NodeName)
+	default:
+		logger.Warn("Unknown node event action")
+	}
+}
+
+// eventNode returns the family and the namespace of the node. The nodes which are not peers of the config are
+// connections of the peers, or StatefulSets added to the queue, which are DA nodes.
+func eventNode(cfg config.MutualPeersConfig, nodeName string) (string, string) {
+	if peer, ok := findPeer(cfg, nodeName); ok {
+		family := FamilyDA
+		if t, err := ResolveNodeType(peer); err == nil {
+			family = t.Family()
+		}
+		return family, peerNamespace(peer)
+	}
+
+	for _, mutualPeer := range cfg.MutualPeers {
+		if mutualPeer.ConsensusNode == nodeName {
+			return FamilyConsensus, k8s.GetCurrentNamespace()
+		}
+		for _, peer := range mutualPeer.Peers {
+			if !slices.Contains(peer.ConnectsTo, nodeName) && !slices.Contains(peer.Seeds, nodeName) {
+				continue
+			}
+			// the connections of the consensus nodes are consensus nodes too
+			if t, err := ResolveNodeType(peer); err == nil && t.Family() == FamilyConsensus {
+				return FamilyConsensus, peerNamespace(peer)
+			}
+			return FamilyDA, peerNamespace(peer)
+		}
+	}
+
+	return FamilyDA, k8s.GetCurrentNamespace()
+}
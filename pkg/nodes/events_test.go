@@ -0,0 +1,93 @@
+package nodes
+
+import (
+	"testing"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+func TestEventNode(t *testing.T) {
+	cfg := config.MutualPeersConfig{
+		MutualPeers: []*config.MutualPeer{
+			{
+				ConsensusNode: "consensus-validator-1",
+				Peers: []config.Peer{
+					{NodeName: "consensus-full-1", NodeType: "consensus", Namespace: "celestia", ConnectsTo: []string{"consensus-validator-2"}},
+					{NodeName: "da-bridge-1-0", NodeType: "da", Namespace: "celestia", ConnectsTo: []string{"da-full-2-0"}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		nodeName      string
+		wantFamily    string
+		wantNamespace string
+	}{
+		{
+			name:          "Case 1: DA peer of the config",
+			nodeName:      "da-bridge-1-0",
+			wantFamily:    FamilyDA,
+			wantNamespace: "celestia",
+		},
+		{
+			name:          "Case 2: Consensus peer of the config",
+			nodeName:      "consensus-full-1",
+			wantFamily:    FamilyConsensus,
+			wantNamespace: "celestia",
+		},
+		{
+			name:          "Case 3: Connection of a consensus peer",
+			nodeName:      "consensus-validator-2",
+			wantFamily:    FamilyConsensus,
+			wantNamespace: "celestia",
+		},
+		{
+			name:          "Case 4: Connection of a DA peer",
+			nodeName:      "da-full-2-0",
+			wantFamily:    FamilyDA,
+			wantNamespace: "celestia",
+		},
+		{
+			name:          "Case 5: Consensus node of the mutual peers",
+			nodeName:      "consensus-validator-1",
+			wantFamily:    FamilyConsensus,
+			wantNamespace: k8s.GetCurrentNamespace(),
+		},
+		{
+			name:          "Case 6: Node which is not in the config",
+			nodeName:      "da-light-9-0",
+			wantFamily:    FamilyDA,
+			wantNamespace: k8s.GetCurrentNamespace(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			family, ns := eventNode(cfg, tt.nodeName)
+			if family != tt.wantFamily || ns != tt.wantNamespace {
+				t.Errorf("eventNode() = %s, %s, want %s, %s", family, ns, tt.wantFamily, tt.wantNamespace)
+			}
+		})
+	}
+}
+
+// TestHandleNodeEvent checks that the ids of the DA nodes stored by other replicas are added to the metrics.
+func TestHandleNodeEvent(t *testing.T) {
+	id := "12D3KooWH1pTTJR5NXPYs2huVcJ9srmmiyGU4txHm2qgdaUVPYAw"
+	defer metrics.UnregisterMetric("da-full-1-0")
+
+	HandleNodeEvent(config.MutualPeersConfig{}, redis.NodeEvent{
+		Action:   redis.NodeCreated,
+		NodeName: "da-full-1-0",
+		Value:    id,
+		Source:   "another-replica",
+	})
+	if !metrics.MultiAddrExists(id) {
+		t.Errorf("MultiAddrExists(%s) = false, want true", id)
+	}
+}
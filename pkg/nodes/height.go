@@ -0,0 +1,105 @@
+package nodes
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/logging"
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+// HeightInterval time between the checks of the heights of the nodes, HEIGHT_INTERVAL env var in seconds.
+var HeightInterval = time.Duration(getEnvInt("HEIGHT_INTERVAL", 15)) * time.Second
+
+// HeightPoller records the latest height of the nodes of the config.
+type HeightPoller struct {
+	fetchHeight func(ctx context.Context, peer config.Peer, nodeType NodeType) (int64, time.Time, error) // fetchHeight returns the height of the node.
+}
+
+// NewHeightPoller returns a poller which gets the heights of the nodes with the function received.
+func NewHeightPoller(fetchHeight func(ctx context.Context, peer config.Peer, nodeType NodeType) (int64, time.Time, error)) *HeightPoller {
+	return &HeightPoller{fetchHeight: fetchHeight}
+}
+
+// Run polls all the nodes of the config on every interval, until the context is done.
+func (p *HeightPoller) Run(ctx context.Context, cfg config.MutualPeersConfig, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Height poller stopped")
+			return
+		case <-ticker.C:
+			for _, mutualPeer := range cfg.MutualPeers {
+				for _, peer := range mutualPeer.Peers {
+					if ctx.Err() != nil {
+						return
+					}
+					if err := p.PollNode(ctx, peer); err != nil {
+						logging.ForNode(ctx, peer).WithError(err).Warn("Error getting the height of the node")
+					}
+				}
+			}
+		}
+	}
+}
+
+// PollNode gets the latest height of the node and records it, the last height is kept if it can't be fetched.
+func (p *HeightPoller) PollNode(ctx context.Context, peer config.Peer) error {
+	nodeType, err := ResolveNodeType(peer)
+	if err != nil {
+		return err
+	}
+	peer = nodeType.SetDefaults(peer)
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	height, blockTime, err := p.fetchHeight(ctx, peer, nodeType)
+	if err != nil {
+		return err
+	}
+
+	metrics.SetNodeHeight(metrics.NodeHeight{
+		NodeName:  peer.NodeName,
+		Namespace: peer.Namespace,
+		NodeType:  nodeType.Name(),
+		Consensus: nodeType.Family() == FamilyConsensus,
+		Height:    height,
+		Time:      blockTime,
+	})
+
+	return nil
+}
+
+// RunHeightPoller records the heights of the nodes of the config periodically, until the context is done.
+func RunHeightPoller(ctx context.Context, cfg config.MutualPeersConfig) {
+	log.Info("Polling the heights of the nodes every [", HeightInterval, "]")
+	NewHeightPoller(fetchHeight).Run(ctx, cfg, HeightInterval)
+}
+
+// fetchHeight returns the latest block of the consensus nodes using /status, and the header synced by the DA nodes
+// using header.SyncState.
+func fetchHeight(ctx context.Context, peer config.Peer, nodeType NodeType) (int64, time.Time, error) {
+	if nodeType.Family() == FamilyDA {
+		state, err := FetchSyncState(ctx, peer)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		return int64(state.Height), time.Time{}, nil
+	}
+
+	host := peer.ServiceName
+	if host == "" {
+		host = peer.NodeName
+	}
+	return ConsensusNodeHeight(host)
+}
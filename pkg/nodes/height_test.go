@@ -0,0 +1,71 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+func TestPollNode(t *testing.T) {
+	heights := map[string]int64{
+		"consensus-full-h1-0": 100,
+		"consensus-full-h2-0": 98,
+		"da-bridge-h1-0":      90,
+	}
+	p := NewHeightPoller(func(ctx context.Context, peer config.Peer, nodeType NodeType) (int64, time.Time, error) {
+		height, ok := heights[peer.NodeName]
+		if !ok {
+			return 0, time.Time{}, errors.New("node not found")
+		}
+		return height, time.Now(), nil
+	})
+
+	tests := []struct {
+		name    string
+		peer    config.Peer
+		wantLag int64
+		wantErr bool
+	}{
+		{
+			name:    "Case 1: Consensus node at the head",
+			peer:    config.Peer{NodeName: "consensus-full-h1-0", NodeType: TypeConsensusFull},
+			wantLag: 0,
+		},
+		{
+			name:    "Case 2: Consensus node behind the head",
+			peer:    config.Peer{NodeName: "consensus-full-h2-0", NodeType: TypeConsensusFull},
+			wantLag: 2,
+		},
+		{
+			name:    "Case 3: DA node behind the consensus nodes",
+			peer:    config.Peer{NodeName: "da-bridge-h1-0", NodeType: TypeDABridge},
+			wantLag: 10,
+		},
+		{
+			name:    "Case 4: Node not available",
+			peer:    config.Peer{NodeName: "da-bridge-h2-0", NodeType: TypeDABridge},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.PollNode(context.Background(), tt.peer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PollNode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			lag, ok := metrics.HeightLag(tt.peer.NodeName)
+			if ok == tt.wantErr {
+				t.Fatalf("HeightLag() found = %v, wantErr %v", ok, tt.wantErr)
+			}
+			if lag != tt.wantLag {
+				t.Errorf("HeightLag() = %d, want %d", lag, tt.wantLag)
+			}
+		})
+	}
+}
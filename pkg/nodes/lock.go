@@ -0,0 +1,92 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/logging"
+	"github.com/jrmanes/torch/pkg/metrics"
+)
+
+// LockLease time that a node lock is kept in Redis if it's not renewed.
+const LockLease = 60 * time.Second
+
+var (
+	locker         redis.Locker = redis.NewMemoryLocker() // locker used to lock the nodes while they are configured.
+	fallbackLocker              = redis.NewMemoryLocker() // fallbackLocker used when the locker returns an error.
+)
+
+// heldLocksKey key used to keep the locks held in the context.
+type heldLocksKey struct{}
+
+// SetLocker replaces the locker used to lock the nodes, the default one only works within the same process.
+func SetLocker(l redis.Locker) {
+	locker = l
+}
+
+// lockNode acquires the lock of the node before configuring it or generating its id, so the HTTP handlers,
+// the queues and the other replicas don't run against the same pod at the same time.
+// The context returned keeps the lock, so the functions called with it don't try to acquire it again.
+func lockNode(ctx context.Context, nodeName string) (context.Context, func(), error) {
+	held, _ := ctx.Value(heldLocksKey{}).(map[string]bool)
+	if held[nodeName] {
+		return ctx, func() {}, nil
+	}
+
+	logger := logging.ForNodeName(ctx, nodeName)
+	start := time.Now()
+	unlock, contended, err := locker.Lock(ctx, nodeName)
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		logger.WithError(err).Warn("Error acquiring the lock of the node, using the in-memory lock")
+		unlock, contended, err = fallbackLocker.Lock(ctx, nodeName)
+	}
+	metrics.ObserveNodeLock(time.Since(start), contended, err)
+	if err != nil {
+		logger.WithError(err).Error("Error acquiring the lock of the node")
+		return ctx, nil, err
+	}
+	if contended {
+		logger.WithField("waited", time.Since(start)).Info("The node was locked")
+	}
+
+	// copy the locks held, the parent context can be used by other goroutines
+	locks := make(map[string]bool, len(held)+1)
+	for k := range held {
+		locks[k] = true
+	}
+	locks[nodeName] = true
+
+	return context.WithValue(ctx, heldLocksKey{}, locks), unlock, nil
+}
+
+// lockNodes acquires the locks of the nodes sorted by name, so two functions which need the same nodes can't wait
+// for each other. The function returned releases all of them.
+func lockNodes(ctx context.Context, nodeNames ...string) (context.Context, func(), error) {
+	names := append([]string(nil), nodeNames...)
+	sort.Strings(names)
+
+	var unlocks []func()
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+
+	for i, name := range names {
+		if i > 0 && name == names[i-1] {
+			continue
+		}
+		lockCtx, unlock, err := lockNode(ctx, name)
+		if err != nil {
+			unlockAll()
+			return ctx, nil, err
+		}
+		ctx = lockCtx
+		unlocks = append(unlocks, unlock)
+	}
+
+	return ctx, unlockAll, nil
+}
@@ -1,10 +1,14 @@
 package nodes
 
 import (
+	"context"
+
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/jrmanes/torch/config"
 	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/logging"
 )
 
 type NodeAddress struct {
@@ -18,7 +22,7 @@ func ValidateNode(n string, cfg config.MutualPeersConfig) (bool, config.Peer) {
 	for _, mutualPeer := range cfg.MutualPeers {
 		for _, peer := range mutualPeer.Peers {
 			if peer.NodeName == n {
-				log.Info("Pod found in the config, executing remote command...")
+				log.WithField(logging.FieldNode, n).Info("Pod found in the config, executing remote command...")
 				return true, peer
 			}
 		}
@@ -27,25 +31,65 @@ func ValidateNode(n string, cfg config.MutualPeersConfig) (bool, config.Peer) {
 	return false, config.Peer{}
 }
 
-// SetupNodesEnvVarAndConnections configure the ENV vars for those nodes that needs to connect via ENV var
-func SetupNodesEnvVarAndConnections(peer config.Peer, cfg config.MutualPeersConfig) error {
+// findPeer returns the peer of the config with the node name received.
+func findPeer(cfg config.MutualPeersConfig, nodeName string) (config.Peer, bool) {
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			if peer.NodeName == nodeName {
+				return peer, true
+			}
+		}
+	}
+	return config.Peer{}, false
+}
+
+// DependentPeers returns the peers in the config which connect to the node received.
+func DependentPeers(n string, cfg config.MutualPeersConfig) []config.Peer {
+	var peers []config.Peer
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			for _, conn := range peer.ConnectsTo {
+				if conn == n {
+					peers = append(peers, peer)
+					break
+				}
+			}
+		}
+	}
+
+	return peers
+}
+
+// SetupNodesEnvVarAndConnections configure the ENV vars for those nodes that needs to connect via ENV var,
+// writing the connection in the file of its node type.
+func SetupNodesEnvVarAndConnections(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, file string) error {
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	// lock the node while it's configured
+	ctx, unlock, err := lockNode(ctx, peer.NodeName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Configure Consensus & DA - connecting using env var
-	_, err := k8s.RunRemoteCommand(
+	_, err = k8s.RunRemoteCommand(
+		ctx,
 		peer.NodeName,
 		peer.ContainerSetupName,
 		k8s.GetCurrentNamespace(),
-		k8s.CreateFileWithEnvVar(peer.ConnectsTo[0], peer.NodeType),
+		k8s.CreateFileWithEnvVar(peer.ConnectsTo[0], file),
 	)
 	if err != nil {
-		log.Error("Error executing remote command: ", err)
+		logging.ForNode(ctx, peer).WithError(err).Error(errRemoteCommand)
 		return err
 	}
-
-	// check if the node is type DA, if so, add the node to the queue to generate the Multi Address later.
-	if peer.NodeType == "da" {
-		// we use the goroutine for that, otherwise, Torch tries to keep the connection opened.
-		go AddToQueue(peer)
-	}
+	k8s.RecordNodeEvent(ctx, peer.NodeName, peer.Namespace, corev1.EventTypeNormal, k8s.ReasonPeersWritten,
+		"Torch wrote the connection %s to %s", peer.ConnectsTo[0], file)
 
 	return nil
 }
@@ -2,107 +2,231 @@ package nodes
 
 import (
 	"context"
-	"os"
-	"os/signal"
-	"syscall"
+	"fmt"
 	"time"
 
 	"github.com/adjust/rmq/v5"
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/jrmanes/torch/config"
 	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/logging"
+	"github.com/jrmanes/torch/pkg/metrics"
 )
 
 const (
-	consumerName            = "torch-consumer" // consumerName name used in the tag to identify the consumer.
-	prefetchLimit           = 10               // prefetchLimit
-	pollDuration            = 10 * time.Second // pollDuration how often is Torch going to pull data from the queue.
-	timeoutDurationConsumer = 60 * time.Second // timeoutDurationConsumer timeout for the consumer.
+	consumerName  = "torch-consumer" // consumerName name used in the tag to identify the consumer.
+	prefetchLimit = 10               // prefetchLimit
+	pollDuration  = 10 * time.Second // pollDuration how often is Torch going to pull data from the queue.
+	cleanInterval = time.Minute      // cleanInterval how often is Torch going to return the lost deliveries.
 )
 
-// ConsumerInit initialize the process to check the queues in Redis.
-func ConsumerInit(queueName string) {
-	errChan := make(chan error, 10)
-	go logErrors(errChan)
-
-	red := redis.InitRedisConfig()
-	// Create a new context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutDurationConsumer)
-
-	// Make sure to call the cancel function to release resources when you're done
-	defer cancel()
-
-	connection, err := rmq.OpenConnection(
-		"consumer",
-		"tcp",
-		redis.GetRedisFullURL(),
-		2,
-		errChan,
-	)
-	if err != nil {
-		log.Error("Error: ", err)
-	}
+var (
+	timeoutDurationConsumer = 60 * time.Second           // timeoutDurationConsumer timeout for the consumer.
+	processNode             = CheckNodesInDBOrCreateThem // processNode generates the id of the node of the delivery.
+)
 
+// ConsumerInit initialize the process to check the queues in Redis using the connection received.
+// The nodes which fail are published again after a delay, and after MaxRetryCount attempts they are moved to the
+// dead letters. It blocks until the context is done, then it stops the consumers of the connection and waits
+// DrainTimeout for the deliveries in-flight.
+func ConsumerInit(ctx context.Context, queueName string, red *redis.RedisClient, connection rmq.Connection) error {
+	logger := log.WithField("queue", queueName)
 	queue, err := connection.OpenQueue(queueName)
 	if err != nil {
-		log.Error("Error: ", err)
+		logger.WithError(err).Error("Error opening the queue")
+		return err
 	}
 
 	if err := queue.StartConsuming(prefetchLimit, pollDuration); err != nil {
-		log.Error("Error: ", err)
+		logger.WithError(err).Error("Error starting to consume the queue")
+		return err
 	}
 
 	_, err = queue.AddConsumerFunc(consumerName, func(delivery rmq.Delivery) {
-		log.Info("Performing task: ", delivery.Payload())
-		peer := config.Peer{
-			NodeName:      delivery.Payload(),
-			NodeType:      "da",
-			ContainerName: "da",
-		}
+		consume(queueName, red, delivery)
+	})
+	if err != nil {
+		logger.WithError(err).Error("Error adding the consumer to the queue")
+		return err
+	}
 
-		// here we wil send the node to generate the id
-		err := CheckNodesInDBOrCreateThem(peer, red, ctx)
+	metrics.RegisterQueueDepth(queueName, func(ctx context.Context) map[string]int64 {
+		state, err := redis.GetQueueState(red, ctx, queueName)
 		if err != nil {
-			log.Error("Error checking the nodes: CheckNodesInDBOrCreateThem - ", err)
+			logger.WithError(err).Error("Error getting the state of the queue")
+			return nil
 		}
+		return state.Counts()
+	})
+
+	// publish the retries and clean the deliveries of the dead connections until the context is done
+	RunQueueJanitor(ctx, queueName, red, connection, queue)
+
+	logger.Info("Stopping the consumers of the queue")
+	select {
+	case <-connection.StopAllConsuming(): // wait for all Consume() calls to finish
+		logger.Info("Consumers of the queue stopped")
+	case <-time.After(DrainTimeout):
+		// the unacked deliveries are returned to the queue by the cleaner of another replica
+		return fmt.Errorf("timeout waiting for the consumers of the queue [%s] to finish", queueName)
+	}
+
+	return nil
+}
+
+// consume processes the node of the delivery, if it fails, the node is scheduled to be retried or moved to the dead
+// letters, and if that fails too, the delivery is rejected, so it's not lost. The task gets a job id on its first
+// attempt, which is kept in its retries.
+func consume(queueName string, red *redis.RedisClient, delivery rmq.Delivery) {
+	task := redis.ParseQueueTask(delivery.Payload())
+	if task.JobID == "" {
+		task.JobID = logging.NewID()
+	}
+	// the node failed before, so it's a retry
+	if task.Attempts > 0 && !task.UpdatedAt.IsZero() {
+		metrics.ObserveQueueRetryLatency(queueName, time.Since(task.UpdatedAt))
+	}
+	// the StatefulSets in the queue are DA nodes, the kind is taken from their names
+	nodeType := daNodeType(config.Peer{NodeName: task.NodeName, NodeType: FamilyDA})
+	peer := nodeType.SetDefaults(config.Peer{
+		NodeName: task.NodeName,
+		NodeType: nodeType.Name(),
+	})
+
+	// the node is processed with the ids of the task
+	jobCtx := logging.WithJobID(logging.WithRequestID(context.Background(), task.RequestID), task.JobID)
+	logger := logging.ForNode(jobCtx, peer).WithFields(log.Fields{"queue": queueName, "attempts": task.Attempts})
+	logger.Info("Performing task")
 
+	// Create a new context with a timeout for each delivery
+	ctx, cancel := context.WithTimeout(jobCtx, timeoutDurationConsumer)
+	defer cancel()
+
+	// here we wil send the node to generate the id
+	err := processNode(peer, red, ctx)
+	metrics.ObserveQueueProcessed(queueName, err)
+	if err == nil {
 		if err := delivery.Ack(); err != nil {
-			log.Error("Error: ", err)
+			logger.WithError(err).Error("Error acking the delivery")
 		}
-	})
+		return
+	}
+	logger.WithError(err).Error("Error checking the node")
+
+	task.Attempts++
+	task.LastError = err.Error()
+	// the context of the delivery can be expired, the retry is stored with the one of the job
+	ctx = context.WithoutCancel(jobCtx)
+	task.UpdatedAt = time.Now().UTC()
+
+	reason, message := k8s.ReasonGaveUp, fmt.Sprintf(
+		"Torch gave up on the node after %d attempts, it's in the dead letters: %s", task.Attempts, task.LastError)
+	if task.Attempts < MaxRetryCount {
+		delay := retryDelay(task.Attempts)
+		err = redis.ScheduleRetry(red, ctx, queueName, task, delay)
+		reason, message = k8s.ReasonRetryScheduled, fmt.Sprintf(
+			"Torch couldn't process the node (attempt %d/%d), retrying it in %s: %s",
+			task.Attempts, MaxRetryCount, delay, task.LastError)
+	} else {
+		metrics.IncQueueFailed(queueName)
+		err = redis.AddDeadLetter(red, ctx, queueName, task)
+	}
 	if err != nil {
-		log.Error("Error: ", err)
+		logger.WithError(err).Error("Error scheduling the retry of the node, rejecting it")
+		if err := delivery.Reject(); err != nil {
+			logger.WithError(err).Error("Error rejecting the delivery")
+		}
+		return
 	}
 
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT)
-	defer signal.Stop(signals)
+	k8s.RecordNodeEvent(jobCtx, peer.NodeName, peer.Namespace, corev1.EventTypeWarning, reason, "%s", message)
 
-	<-signals // wait for signal
-	go func() {
-		<-signals // hard exit on second signal (in case shutdown gets stuck)
-		os.Exit(1)
-	}()
+	if err := delivery.Ack(); err != nil {
+		logger.WithError(err).Error("Error acking the delivery")
+	}
+}
 
-	<-connection.StopAllConsuming() // wait for all Consume() calls to finish
+// retryDelay returns the time to wait before retrying a node, it's doubled on every attempt.
+func retryDelay(attempts int) time.Duration {
+	delay := pollDuration
+	for i := 1; i < attempts && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > MaxRetryDelay {
+		delay = MaxRetryDelay
+	}
+	return delay
+}
+
+// RunQueueJanitor publishes the retries whose delay has expired, and returns to the queue the deliveries of the
+// dead connections and the rejected ones, until the context is done.
+func RunQueueJanitor(
+	ctx context.Context,
+	queueName string,
+	red *redis.RedisClient,
+	connection rmq.Connection,
+	queue rmq.Queue,
+) {
+	retryTicker := time.NewTicker(pollDuration)
+	defer retryTicker.Stop()
+	cleanTicker := time.NewTicker(cleanInterval)
+	defer cleanTicker.Stop()
+
+	cleaner := rmq.NewCleaner(connection)
+	logger := log.WithField("queue", queueName)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-retryTicker.C:
+			published, err := redis.PublishDueRetries(red, ctx, queue, queueName)
+			if err != nil {
+				logger.WithError(err).Error("Error publishing the retries")
+			}
+			if published > 0 {
+				logger.WithField("published", published).Info("Retries published in the queue")
+			}
+		case <-cleanTicker.C:
+			returned, err := cleaner.Clean()
+			if err != nil {
+				logger.WithError(err).Error("Error cleaning the queues")
+			}
+			if returned > 0 {
+				logger.WithField("returned", returned).Info("Unacked deliveries returned from dead connections")
+			}
+
+			returned, err = queue.ReturnRejected(prefetchLimit)
+			if err != nil {
+				logger.WithError(err).Error("Error returning the rejected deliveries")
+			}
+			if returned > 0 {
+				logger.WithField("returned", returned).Info("Rejected deliveries returned to the queue")
+			}
+		}
+	}
 }
 
-func logErrors(errChan <-chan error) {
+// LogQueueErrors logs the errors received from the queue connection.
+func LogQueueErrors(errChan <-chan error) {
 	for err := range errChan {
 		switch err := err.(type) {
 		case *rmq.HeartbeatError:
 			if err.Count == rmq.HeartbeatErrorLimit {
-				log.Print("heartbeat error (limit): ", err)
+				log.WithError(err).Error("Heartbeat error of the queue connection (limit)")
 			} else {
-				log.Print("heartbeat error: ", err)
+				log.WithError(err).Warn("Heartbeat error of the queue connection")
 			}
 		case *rmq.ConsumeError:
-			log.Print("consume error: ", err)
+			log.WithError(err).Error("Consume error of the queue connection")
 		case *rmq.DeliveryError:
-			log.Print("delivery error: ", err.Delivery, err)
+			task := redis.ParseQueueTask(err.Delivery.Payload())
+			task.Logger(context.Background()).WithError(err).Error("Delivery error of the queue connection")
 		default:
-			log.Print("other error: ", err)
+			log.WithError(err).Error("Other error of the queue connection")
 		}
 	}
 }
@@ -0,0 +1,83 @@
+package nodes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+)
+
+// fakeDelivery is a delivery which keeps if it was acked or rejected.
+type fakeDelivery struct {
+	payload  string
+	acked    bool
+	rejected bool
+}
+
+// Payload returns the payload of the delivery.
+func (d *fakeDelivery) Payload() string { return d.payload }
+
+// Ack marks the delivery as acked.
+func (d *fakeDelivery) Ack() error { d.acked = true; return nil }
+
+// Reject marks the delivery as rejected.
+func (d *fakeDelivery) Reject() error { d.rejected = true; return nil }
+
+// Push marks the delivery as rejected.
+func (d *fakeDelivery) Push() error { d.rejected = true; return nil }
+
+// TestConsumeTimeout checks that the nodes whose processing times out are retried with more attempts, until they
+// reach the dead letters.
+func TestConsumeTimeout(t *testing.T) {
+	s := miniredis.RunT(t)
+	red := redis.NewRedisClient(s.Addr(), "", 0)
+	k8s.SetEventRecorder(nil)
+
+	defer func(process func(config.Peer, *redis.RedisClient, context.Context) error, timeout time.Duration) {
+		processNode = process
+		timeoutDurationConsumer = timeout
+	}(processNode, timeoutDurationConsumer)
+	timeoutDurationConsumer = time.Millisecond
+	processNode = func(peer config.Peer, red *redis.RedisClient, ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ctx := context.Background()
+	payload := "da-full-1-0"
+	for attempt := 1; attempt <= MaxRetryCount; attempt++ {
+		delivery := &fakeDelivery{payload: payload}
+		consume("k8s", red, delivery)
+		if !delivery.acked || delivery.rejected {
+			t.Fatalf("attempt %d: acked = %v, rejected = %v, want acked", attempt, delivery.acked, delivery.rejected)
+		}
+		if attempt == MaxRetryCount {
+			break
+		}
+
+		delayed, err := redis.GetDelayedTasks(red, ctx, "k8s")
+		if err != nil || len(delayed) == 0 {
+			t.Fatalf("attempt %d: GetDelayedTasks() = %v, error = %v", attempt, delayed, err)
+		}
+		task := delayed[len(delayed)-1]
+		for _, d := range delayed {
+			if d.Attempts > task.Attempts {
+				task = d
+			}
+		}
+		if task.Attempts != attempt {
+			t.Fatalf("attempt %d: Attempts = %d, want %d", attempt, task.Attempts, attempt)
+		}
+		payload = task.Payload()
+	}
+
+	dead, err := redis.GetDeadLetters(red, ctx, "k8s")
+	if err != nil || len(dead) != 1 || dead[0].NodeName != "da-full-1-0" || dead[0].Attempts != MaxRetryCount {
+		t.Fatalf("GetDeadLetters() = %+v, error = %v, want da-full-1-0 with %d attempts", dead, err, MaxRetryCount)
+	}
+}
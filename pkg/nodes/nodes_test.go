@@ -76,3 +76,51 @@ func TestValidateNode(t *testing.T) {
 		})
 	}
 }
+
+func TestDependentPeers(t *testing.T) {
+	cfg := config.MutualPeersConfig{
+		MutualPeers: []*config.MutualPeer{
+			{
+				Peers: []config.Peer{
+					{NodeName: "da-bridge-1-0"},
+					{NodeName: "da-full-1-0", ConnectsTo: []string{"da-bridge-1-0"}},
+				},
+			},
+			{
+				Peers: []config.Peer{
+					{NodeName: "da-full-2-0", ConnectsTo: []string{"da-bridge-2-0", "da-bridge-1-0"}},
+					{NodeName: "da-full-3-0", ConnectsTo: []string{"da-bridge-2-0"}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		n    string
+		want []string
+	}{
+		{
+			name: "Case 1: Node with dependent peers",
+			n:    "da-bridge-1-0",
+			want: []string{"da-full-1-0", "da-full-2-0"},
+		},
+		{
+			name: "Case 2: Node without dependent peers",
+			n:    "da-full-1-0",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, peer := range DependentPeers(tt.n, cfg) {
+				got = append(got, peer.NodeName)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DependentPeers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+)
+
+const (
+	ActionUseStoredID    = "use-stored-id"    // ActionUseStoredID the id of the connection is already stored in the DB.
+	ActionUseConfigAddr  = "use-config-addr"  // ActionUseConfigAddr the connection is a multi address in the config.
+	ActionUseTrustedPool = "use-trusted-pool" // ActionUseTrustedPool the light node connects to the full and bridge nodes.
+	ActionGenerateID     = "generate-id"      // ActionGenerateID the id of the connection has to be generated.
+	ActionResolveAddress = "resolve-address"  // ActionResolveAddress the IP of the connection is taken from the node.
+	ActionExec           = "exec"             // ActionExec a command is run in a container of the pod.
+	ActionStoreID        = "store-id"         // ActionStoreID the id generated is stored in the DB.
+	ActionEnqueue        = "enqueue"          // ActionEnqueue the node is added to the queue to generate its id.
+
+	placeholderIDTemplate   = "<id of %s>"      // placeholderIDTemplate value of the ids that are not generated yet.
+	placeholderAddrTemplate = "<address of %s>" // placeholderAddrTemplate value of the addresses taken from the nodes.
+)
+
+// Action represents a step that Torch would run to configure a node.
+type Action struct {
+	Step        int      `json:"step"`                // Step order of the action.
+	Kind        string   `json:"kind"`                // Kind of action.
+	Node        string   `json:"node"`                // Node where the action is applied.
+	Container   string   `json:"container,omitempty"` // Container where the command is run.
+	Namespace   string   `json:"namespace,omitempty"` // Namespace of the pod where the command is run.
+	Command     []string `json:"command,omitempty"`   // Command run in the container.
+	File        string   `json:"file,omitempty"`      // File written by the command.
+	Value       string   `json:"value,omitempty"`     // Value used: the id stored, or the content of the file.
+	Description string   `json:"description"`         // Description of the action.
+}
+
+// Plan represents the actions that Torch would run to configure a node, without running them.
+type Plan struct {
+	Node     string            `json:"node"`     // Node to configure.
+	NodeType string            `json:"nodeType"` // NodeType resolved type of the node.
+	Actions  []Action          `json:"actions"`  // Actions in the order they would be run.
+	Files    map[string]string `json:"files"`    // Files final content of the files written in the node.
+}
+
+// add appends the action to the plan, setting its step.
+func (p *Plan) add(action Action) {
+	action.Step = len(p.Actions) + 1
+	p.Actions = append(p.Actions, action)
+}
+
+// addWrite appends the command which writes the content in the file of the node, and keeps the final content.
+func (p *Plan) addWrite(peer config.Peer, file, content string, command []string) {
+	p.add(Action{
+		Kind:        ActionExec,
+		Node:        peer.NodeName,
+		Container:   peer.ContainerSetupName,
+		Namespace:   k8s.GetCurrentNamespace(),
+		Command:     command,
+		File:        file,
+		Value:       content,
+		Description: "write the connections in the file",
+	})
+	p.Files[file] = content
+}
+
+// PlanNode returns the actions that Torch would run to configure the node, it only reads the DB.
+func PlanNode(peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) (Plan, error) {
+	nodeType, err := ResolveNodeType(peer)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	return nodeType.Plan(nodeType.SetDefaults(peer), cfg, red)
+}
+
+// newPlan returns an empty plan for the node.
+func newPlan(peer config.Peer, nodeType string) Plan {
+	return Plan{
+		Node:     peer.NodeName,
+		NodeType: nodeType,
+		Actions:  []Action{},
+		Files:    make(map[string]string),
+	}
+}
+
+// planEnvVar adds the command which writes the first connection in the file, when the node connects using env vars.
+func planEnvVar(plan *Plan, peer config.Peer, file string) {
+	if len(peer.ConnectsTo) == 0 {
+		return
+	}
+	plan.addWrite(peer, file, peer.ConnectsTo[0], k8s.CreateFileWithEnvVar(peer.ConnectsTo[0], file))
+}
+
+// planStoredID adds the action to use the id stored in the DB, or the ones to generate it, it returns the id or a
+// placeholder.
+func planStoredID(ctx context.Context, plan *Plan, red *redis.RedisClient, nodeName, generate string) (string, error) {
+	id, err := redis.CheckIfNodeExistsInDB(red, ctx, nodeName)
+	if err != nil {
+		return "", err
+	}
+	if id != "" {
+		plan.add(Action{
+			Kind:        ActionUseStoredID,
+			Node:        nodeName,
+			Value:       id,
+			Description: "the id is stored in the DB",
+		})
+		return id, nil
+	}
+
+	plan.add(Action{
+		Kind:        ActionGenerateID,
+		Node:        nodeName,
+		Description: generate,
+	})
+	plan.add(Action{
+		Kind:        ActionStoreID,
+		Node:        nodeName,
+		Description: "store the id generated in the DB",
+	})
+	return fmt.Sprintf(placeholderIDTemplate, nodeName), nil
+}
+
+// Plan returns the actions to write the env var, or the persistent peers and seeds of the node.
+func (t consensusNode) Plan(peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) (Plan, error) {
+	plan := newPlan(peer, t.name)
+	if peer.ConnectsAsEnvVar {
+		planEnvVar(&plan, peer, trustedPeerFileConsensus)
+		return plan, nil
+	}
+	if len(peer.ConnectsTo) == 0 && len(peer.Seeds) == 0 {
+		return plan, nil
+	}
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	lists := map[string][]string{}
+	for index, nodeName := range peer.ConnectsTo {
+		host := consensusHost(peer, index)
+		id, err := planStoredID(ctx, &plan, red, nodeName, "get the id from the status of "+host)
+		if err != nil {
+			return plan, err
+		}
+		lists[persistentPeersFile] = append(lists[persistentPeersFile], consensusAddress(id, host))
+	}
+	for _, nodeName := range peer.Seeds {
+		id, err := planStoredID(ctx, &plan, red, nodeName, "get the id from the status of "+nodeName)
+		if err != nil {
+			return plan, err
+		}
+		lists[seedsFile] = append(lists[seedsFile], consensusAddress(id, nodeName))
+	}
+
+	for _, file := range []string{persistentPeersFile, seedsFile} {
+		content := strings.Join(lists[file], ",")
+		plan.addWrite(peer, file, content, k8s.WriteToFile(content, file))
+	}
+
+	return plan, nil
+}
+
+// Plan returns the actions to write the env var, or the multi addresses of the connections of the node.
+func (t daNode) Plan(peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) (Plan, error) {
+	plan := newPlan(peer, t.name)
+	if peer.ConnectsAsEnvVar {
+		planEnvVar(&plan, peer, trustedPeerFileDA)
+		plan.add(Action{Kind: ActionEnqueue, Node: peer.NodeName, Description: "add the node to the queue to generate its id"})
+		return plan, nil
+	}
+
+	if t.name == TypeDALight && len(peer.ConnectsTo) == 0 {
+		peer.ConnectsTo = TrustedPeerPool(peer.NodeName, cfg)
+		if len(peer.ConnectsTo) == 0 {
+			return plan, fmt.Errorf("there are no full or bridge nodes in the config to connect the light node [%s]", peer.NodeName)
+		}
+		plan.add(Action{
+			Kind:        ActionUseTrustedPool,
+			Node:        peer.NodeName,
+			Value:       strings.Join(peer.ConnectsTo, ","),
+			Description: "connect to the full and bridge nodes of the config",
+		})
+	}
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+
+	// Make sure to call the cancel function to release resources when you're done
+	defer cancel()
+
+	addresses := make([]string, 0, len(peer.ConnectsTo))
+	for index, nodeName := range peer.ConnectsTo {
+		if ma, addPrefix := VerifyAndUpdateMultiAddress(peer, index, "", true); !addPrefix {
+			plan.add(Action{Kind: ActionUseConfigAddr, Node: nodeName, Value: ma, Description: "the multi address is in the config"})
+			addresses = append(addresses, ma)
+			continue
+		}
+
+		id, err := redis.CheckIfNodeExistsInDB(red, ctx, nodeName)
+		if err != nil {
+			return plan, err
+		}
+		if id != "" {
+			plan.add(Action{Kind: ActionUseStoredID, Node: nodeName, Value: id, Description: "the id is stored in the DB"})
+		} else {
+			planGenerateDAID(&plan, connectionPeer(cfg, peer, nodeName))
+			id = fmt.Sprintf(placeholderIDTemplate, nodeName)
+		}
+
+		addresses = append(addresses, planDAPrefix(&plan, peer, cfg, index, id))
+	}
+
+	if len(addresses) > 0 {
+		content := strings.Join(addresses, ",")
+		plan.addWrite(peer, fPathDA, content, k8s.WriteToFile(content, fPathDA))
+		plan.add(Action{Kind: ActionEnqueue, Node: peer.NodeName, Description: "add the node to the queue to generate its id"})
+	}
+
+	return plan, nil
+}
+
+// planGenerateDAID adds the actions to generate the id of the DA node calling p2p.Info, minting the token if the node
+// doesn't have a Secret, and to store it.
+func planGenerateDAID(plan *Plan, target config.Peer) {
+	nodeName := target.NodeName
+	plan.add(Action{
+		Kind:        ActionGenerateID,
+		Node:        nodeName,
+		Description: "call p2p.Info in the RPC of the node using the mode " + RPCMode,
+	})
+	if target.AuthTokenSecret == "" {
+		plan.add(Action{
+			Kind:        ActionExec,
+			Node:        nodeName,
+			Container:   target.ContainerName,
+			Namespace:   peerNamespace(target),
+			Command:     daNodeType(target).AuthTokenCommand(target),
+			Description: "mint the auth token of the node if it's not cached",
+		})
+	}
+	plan.add(Action{Kind: ActionStoreID, Node: nodeName, Description: "store the id generated in the DB"})
+}
+
+// planDAPrefix adds the actions to resolve the address of the connection in the index, and returns the multi address.
+func planDAPrefix(plan *Plan, peer config.Peer, cfg config.MutualPeersConfig, index int, id string) string {
+	if len(peer.DnsConnections) > 0 {
+		return "/dns/" + peer.DnsConnections[index] + "/tcp/2121/p2p/" + id
+	}
+
+	target := connectionPeer(cfg, peer, peer.ConnectsTo[index])
+	nodeName := target.NodeName
+	plan.add(Action{
+		Kind:        ActionResolveAddress,
+		Node:        nodeName,
+		Description: "use the listen address from p2p.Info, or get the IP running the command in the node",
+	})
+	plan.add(Action{
+		Kind:        ActionExec,
+		Node:        nodeName,
+		Container:   target.ContainerName,
+		Namespace:   peerNamespace(target),
+		Command:     k8s.GetNodeIP(),
+		Description: "get the IP of the node, only if p2p.Info doesn't return it",
+	})
+	return fmt.Sprintf(placeholderAddrTemplate, nodeName) + "/p2p/" + id
+}
@@ -0,0 +1,168 @@
+package nodes
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+)
+
+// actionKinds returns the kind and node of the actions, to compare them without the commands.
+func actionKinds(actions []Action) [][2]string {
+	kinds := [][2]string{}
+	for _, action := range actions {
+		kinds = append(kinds, [2]string{action.Kind, action.Node})
+	}
+	return kinds
+}
+
+func TestPlanNode(t *testing.T) {
+	s := miniredis.RunT(t)
+	s.Set("da-bridge-1-0", "12D3KooWBridge1")
+	s.Set("consensus-validator-1", "a1b2c3")
+	red := redis.NewRedisClient(s.Addr(), "", 0)
+
+	tests := []struct {
+		name        string
+		peer        config.Peer
+		cfg         config.MutualPeersConfig
+		wantActions [][2]string
+		wantFiles   map[string]string
+	}{
+		{
+			name: "Case 1: DA node using the stored id and generating the missing one",
+			peer: config.Peer{
+				NodeName:       "da-full-1-0",
+				NodeType:       TypeDAFull,
+				ConnectsTo:     []string{"da-bridge-1-0", "da-bridge-2-0"},
+				DnsConnections: []string{"da-bridge-1", "da-bridge-2"},
+			},
+			wantActions: [][2]string{
+				{ActionUseStoredID, "da-bridge-1-0"},
+				{ActionGenerateID, "da-bridge-2-0"},
+				{ActionExec, "da-bridge-2-0"},
+				{ActionStoreID, "da-bridge-2-0"},
+				{ActionExec, "da-full-1-0"},
+				{ActionEnqueue, "da-full-1-0"},
+			},
+			wantFiles: map[string]string{
+				fPathDA: "/dns/da-bridge-1/tcp/2121/p2p/12D3KooWBridge1,/dns/da-bridge-2/tcp/2121/p2p/<id of da-bridge-2-0>",
+			},
+		},
+		{
+			name: "Case 2: DA node using env var",
+			peer: config.Peer{
+				NodeName:         "da-bridge-1-0",
+				NodeType:         TypeDABridge,
+				ConnectsAsEnvVar: true,
+				ConnectsTo:       []string{"consensus-full-1"},
+			},
+			wantActions: [][2]string{
+				{ActionExec, "da-bridge-1-0"},
+				{ActionEnqueue, "da-bridge-1-0"},
+			},
+			wantFiles: map[string]string{
+				trustedPeerFileDA: "consensus-full-1",
+			},
+		},
+		{
+			name: "Case 3: Consensus node with persistent peers",
+			peer: config.Peer{
+				NodeName:   "consensus-full-1-0",
+				NodeType:   TypeConsensusFull,
+				ConnectsTo: []string{"consensus-validator-1"},
+				Seeds:      []string{"consensus-seed-1"},
+			},
+			wantActions: [][2]string{
+				{ActionUseStoredID, "consensus-validator-1"},
+				{ActionGenerateID, "consensus-seed-1"},
+				{ActionStoreID, "consensus-seed-1"},
+				{ActionExec, "consensus-full-1-0"},
+				{ActionExec, "consensus-full-1-0"},
+			},
+			wantFiles: map[string]string{
+				persistentPeersFile: "a1b2c3@consensus-validator-1:26656",
+				seedsFile:           "<id of consensus-seed-1>@consensus-seed-1:26656",
+			},
+		},
+		{
+			name: "Case 4: Consensus node which uses env var only writes its env var",
+			peer: config.Peer{
+				NodeName:         "consensus-full-1-0",
+				NodeType:         TypeConsensusFull,
+				ConnectsAsEnvVar: true,
+				ConnectsTo:       []string{"consensus-validator-1"},
+				Seeds:            []string{"consensus-seed-1"},
+			},
+			wantActions: [][2]string{
+				{ActionExec, "consensus-full-1-0"},
+			},
+			wantFiles: map[string]string{
+				trustedPeerFileConsensus: "consensus-validator-1",
+			},
+		},
+		{
+			name: "Case 5: DA node connected to a node of the config with its own Secret",
+			peer: config.Peer{
+				NodeName:   "da-full-1-0",
+				NodeType:   TypeDAFull,
+				ConnectsTo: []string{"celestia-archive-0"},
+			},
+			cfg: config.MutualPeersConfig{
+				MutualPeers: []*config.MutualPeer{{Peers: []config.Peer{{
+					NodeName:        "celestia-archive-0",
+					NodeType:        TypeDAFull,
+					Namespace:       "archive",
+					ContainerName:   "celestia",
+					AuthTokenSecret: "archive-token",
+				}}}},
+			},
+			wantActions: [][2]string{
+				{ActionGenerateID, "celestia-archive-0"},
+				{ActionStoreID, "celestia-archive-0"},
+				{ActionResolveAddress, "celestia-archive-0"},
+				{ActionExec, "celestia-archive-0"},
+				{ActionExec, "da-full-1-0"},
+				{ActionEnqueue, "da-full-1-0"},
+			},
+			wantFiles: map[string]string{
+				fPathDA: "<address of celestia-archive-0>/p2p/<id of celestia-archive-0>",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PlanNode(tt.peer, tt.cfg, red)
+			if err != nil {
+				t.Fatalf("PlanNode() error = %v", err)
+			}
+			if kinds := actionKinds(got.Actions); !reflect.DeepEqual(kinds, tt.wantActions) {
+				t.Errorf("PlanNode() actions = %v, want %v", kinds, tt.wantActions)
+			}
+			if !reflect.DeepEqual(got.Files, tt.wantFiles) {
+				t.Errorf("PlanNode() files = %v, want %v", got.Files, tt.wantFiles)
+			}
+			for i, action := range got.Actions {
+				if action.Step != i+1 {
+					t.Errorf("PlanNode() step of action [%d] = %d", i, action.Step)
+				}
+				// the commands run in the connections of the config use their container and namespace
+				if conn, ok := findPeer(tt.cfg, action.Node); ok && action.Kind == ActionExec {
+					if action.Container != conn.ContainerName || action.Namespace != conn.Namespace {
+						t.Errorf("PlanNode() exec in %s/%s, want %s/%s",
+							action.Namespace, action.Container, conn.Namespace, conn.ContainerName)
+					}
+				}
+			}
+		})
+	}
+
+	// the plan doesn't modify the DB
+	if keys := s.Keys(); !reflect.DeepEqual(keys, []string{"consensus-validator-1", "da-bridge-1-0"}) {
+		t.Errorf("PlanNode() modified the DB: %v", keys)
+	}
+}
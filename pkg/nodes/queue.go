@@ -2,111 +2,440 @@ package nodes
 
 import (
 	"context"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
 
 	"github.com/jrmanes/torch/config"
 	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/logging"
 	"github.com/jrmanes/torch/pkg/metrics"
 )
 
+const (
+	StatusPending  = "pending"   // StatusPending the node is waiting to be processed.
+	StatusInFlight = "in-flight" // StatusInFlight the node is being processed.
+	StatusFailed   = "failed"    // StatusFailed the node reached the max number of retries.
+
+	TaskQueueName = "task" // TaskQueueName name of the in-memory queue used in the metrics and the API.
+)
+
 var (
-	taskQueue                   = make(chan config.Peer) // taskQueue channel for pending tasks (peers to process later).
-	MaxRetryCount               = 5                      // MaxRetryCount number of retries per node.
-	TickerTime                  = 11 * time.Second       // TickerTime time specified to make a signal.
-	timeoutDurationProcessQueue = 60 * time.Second       // timeoutDurationProcessQueue time specified to make a signal.
+	MaxRetryCount               = 5                                      // MaxRetryCount number of retries per node.
+	TickerTime                  = 11 * time.Second                       // TickerTime time to wait before processing a node, it's doubled on every retry.
+	MaxRetryDelay               = 5 * time.Minute                        // MaxRetryDelay max time to wait between the retries of a node.
+	QueueWorkers                = getEnvInt("QUEUE_WORKERS", 2)          // QueueWorkers number of nodes processed at the same time.
+	MaxQueueSize                = getEnvInt("QUEUE_MAX_SIZE", 1000)      // MaxQueueSize max number of nodes in the queue.
+	timeoutDurationProcessQueue = 60 * time.Second                       // timeoutDurationProcessQueue max time to process a node.
+	DrainTimeout                = 30 * time.Second                       // DrainTimeout max time to wait for the nodes in-flight on shutdown.
+	taskQueue                   = NewTaskQueue(MaxQueueSize, TickerTime) // taskQueue queue for pending tasks (peers to process later).
+
+	// ErrQueueFull returned when the queue reached the max number of nodes.
+	ErrQueueFull = errors.New("error: the queue is full")
+	// errNodeIdNotReady returned when the node id couldn't be generated yet.
+	errNodeIdNotReady = errors.New("error: the node id is not ready yet")
 )
 
-// ProcessTaskQueue processes the pending tasks in the queue the time specified in the const TickerTime.
-func ProcessTaskQueue() {
-	ticker := time.NewTicker(TickerTime)
+// QueueItem represents the state of a node in the queue.
+type QueueItem struct {
+	NodeName  string    `json:"nodeName"`            // NodeName name of the node.
+	Status    string    `json:"status"`              // Status of the node: pending, in-flight or failed.
+	Attempts  int       `json:"attempts"`            // Attempts number of times the node has been processed.
+	LastError string    `json:"lastError,omitempty"` // LastError error of the last attempt.
+	UpdatedAt time.Time `json:"updatedAt"`           // UpdatedAt last time the status changed.
+	JobID     string    `json:"jobID"`               // JobID id of the node in the queue, it's the same in all its attempts.
+	RequestID string    `json:"requestID,omitempty"` // RequestID id of the HTTP request which added the node.
+}
+
+// TaskQueueState represents the nodes of the in-memory queue grouped by their status.
+type TaskQueueState struct {
+	Name     string      `json:"name"`     // Name of the queue.
+	Pending  []QueueItem `json:"pending"`  // Pending nodes waiting to be processed.
+	InFlight []QueueItem `json:"inFlight"` // InFlight nodes being processed.
+	Failed   []QueueItem `json:"failed"`   // Failed nodes which reached the max number of retries.
+}
+
+// TaskQueue is a bounded work queue of nodes, deduplicated by node name, which retries the nodes with
+// exponential backoff until they succeed or reach MaxRetryCount.
+type TaskQueue struct {
+	queue   workqueue.RateLimitingInterface
+	mu      sync.Mutex
+	peers   map[string]config.Peer // peers latest peer received by node name.
+	items   map[string]*QueueItem  // items state of the nodes in the queue, including the failed ones.
+	dirty   map[string]bool        // dirty nodes updated while they were in-flight, they are processed again.
+	maxSize int
+	delay   time.Duration
+}
+
+// NewTaskQueue returns a TaskQueue which accepts maxSize nodes, the nodes are processed after the delay specified,
+// which is doubled on every retry.
+func NewTaskQueue(maxSize int, delay time.Duration) *TaskQueue {
+	return &TaskQueue{
+		queue:   workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(delay, MaxRetryDelay)),
+		peers:   make(map[string]config.Peer),
+		items:   make(map[string]*QueueItem),
+		dirty:   make(map[string]bool),
+		maxSize: maxSize,
+		delay:   delay,
+	}
+}
+
+// Add adds the peer to the queue without blocking, if the node is already in the queue, the peer is updated
+// and the node is not added again, if it's in-flight, it's processed again with the new peer once it finishes.
+// The node gets a new job id, and keeps the request id of the context, so the logs of its attempts can be correlated
+// with the request which added it.
+func (q *TaskQueue) Add(ctx context.Context, peer config.Peer) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 
-	for {
-		select {
-		case <-ticker.C:
-			processQueue()
+	item, ok := q.items[peer.NodeName]
+	if ok && item.Status != StatusFailed {
+		logging.ForNode(logging.WithJobID(ctx, item.JobID), peer).Info("Node already in the queue")
+		q.peers[peer.NodeName] = peer
+		if item.Status == StatusInFlight {
+			q.dirty[peer.NodeName] = true
 		}
+		return nil
+	}
+	if !ok && q.pendingLen() >= q.maxSize {
+		logging.ForNode(ctx, peer).Error("The queue is full, discarding the node")
+		return ErrQueueFull
+	}
+
+	q.peers[peer.NodeName] = peer
+	q.items[peer.NodeName] = &QueueItem{
+		NodeName:  peer.NodeName,
+		Status:    StatusPending,
+		UpdatedAt: time.Now(),
+		JobID:     logging.NewID(),
+		RequestID: logging.RequestID(ctx),
 	}
+	q.queue.Forget(peer.NodeName)
+	// wait before processing the node, so it has time to start
+	q.queue.AddAfter(peer.NodeName, q.delay)
+
+	metrics.IncQueueEnqueued(TaskQueueName)
+	logging.ForNode(logging.WithJobID(ctx, q.items[peer.NodeName].JobID), peer).Info("Node added to the queue")
+
+	return nil
 }
 
-// processQueue process the nodes in the queue and tries to generate the Multi Address
-func processQueue() {
-	red := redis.InitRedisConfig()
-	// Create a new context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutDurationProcessQueue)
+// Run starts the workers which process the nodes, it blocks until the context is done.
+// Once the context is done, the workers don't take new nodes, and the nodes in-flight have DrainTimeout to finish,
+// after that, their context is cancelled.
+func (q *TaskQueue) Run(ctx context.Context, workers int, process func(context.Context, config.Peer) error) {
+	// the nodes in-flight keep running after the context is done, until they finish or the drain timeout expires
+	jobsCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q.processNext(ctx, jobsCtx, process) {
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	q.queue.ShutDown()
 
-	// Make sure to call the cancel function to release resources when you're done
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		log.Info("Queue drained")
+	case <-time.After(DrainTimeout):
+		log.Error("Timeout draining the queue, cancelling the nodes in-flight")
+		cancelJobs()
+		<-drained
+	}
+}
+
+// processNext processes the next node of the queue, it returns false when the queue is shut down or the context is
+// done. The node is processed using the jobsCtx, so it's not cancelled when the context is done.
+func (q *TaskQueue) processNext(
+	ctx context.Context,
+	jobsCtx context.Context,
+	process func(context.Context, config.Peer) error,
+) bool {
+	key, shutdown := q.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer q.queue.Done(key)
+
+	// stop taking nodes once the context is done, the node stays pending
+	if ctx.Err() != nil {
+		return false
+	}
+
+	nodeName := key.(string)
+	peer, item := q.start(nodeName)
+	attempt := item.Attempts
+
+	// the node is processed with the ids of the job and the request which added it
+	jobsCtx = logging.WithJobID(logging.WithRequestID(jobsCtx, item.RequestID), item.JobID)
+	logger := logging.ForNode(jobsCtx, peer).WithField("attempt", attempt)
+
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(jobsCtx, timeoutDurationProcessQueue)
 	defer cancel()
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Error("processQueue - The context has been canceled, exit the loop.")
-			return
-		case peer := <-taskQueue:
-			// TODO:
-			// errors should be returned back and go routines needs to be in errGroup instead of pure go
-			err := CheckNodesInDBOrCreateThem(peer, red, ctx)
-			if err != nil {
-				log.Error("Error checking the nodes: CheckNodesInDBOrCreateThem - ", err)
-			}
+	err := process(ctx, peer)
+	metrics.ObserveQueueProcessed(TaskQueueName, err)
+	if err == nil {
+		q.queue.Forget(nodeName)
+		if q.finish(nodeName) {
+			logger.Info("Node updated while it was processed, adding it to the queue again")
+			q.queue.AddAfter(nodeName, q.delay)
+		}
+		return true
+	}
+
+	if q.requeueDirty(nodeName) {
+		logger.WithError(err).Info("Node updated while it was processed, adding it to the queue again")
+		q.queue.Forget(nodeName)
+		q.queue.AddAfter(nodeName, q.delay)
+	} else if attempt < MaxRetryCount {
+		logger.WithError(err).Info("Node couldn't be processed, adding it to the queue")
+		q.fail(nodeName, StatusPending, err)
+		q.queue.AddRateLimited(nodeName)
+		k8s.RecordNodeEvent(jobsCtx, nodeName, peer.Namespace, corev1.EventTypeWarning, k8s.ReasonRetryScheduled,
+			"Torch couldn't process the node (attempt %d/%d), retrying it: %v", attempt, MaxRetryCount, err)
+	} else {
+		logger.WithError(err).Error("Max retry count reached for the node, it might have some issues")
+		q.queue.Forget(nodeName)
+		q.fail(nodeName, StatusFailed, err)
+		metrics.IncQueueFailed(TaskQueueName)
+		k8s.RecordNodeEvent(jobsCtx, nodeName, peer.Namespace, corev1.EventTypeWarning, k8s.ReasonGaveUp,
+			"Torch gave up on the node after %d attempts: %v", attempt, err)
+	}
+
+	return true
+}
+
+// start marks the node as in-flight, it returns the peer and a copy of its state, with the number of the attempt.
+func (q *TaskQueue) start(nodeName string) (config.Peer, QueueItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.items[nodeName]
+	if !ok {
+		item = &QueueItem{NodeName: nodeName, JobID: logging.NewID()}
+		q.items[nodeName] = item
+	}
+	// the node failed before, so it's a retry
+	if item.Attempts > 0 {
+		metrics.ObserveQueueRetryLatency(TaskQueueName, time.Since(item.UpdatedAt))
+	}
+	item.Status = StatusInFlight
+	item.Attempts++
+	item.UpdatedAt = time.Now()
+
+	peer := q.peers[nodeName]
+	peer.RetryCount = item.Attempts
 
+	return peer, *item
+}
+
+// finish removes the node from the queue once it has been processed successfully, unless it was updated while it was
+// in-flight, then it returns true and the node stays pending to be processed again.
+func (q *TaskQueue) finish(nodeName string) bool {
+	if q.requeueDirty(nodeName) {
+		return true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.items, nodeName)
+	delete(q.peers, nodeName)
+	return false
+}
+
+// requeueDirty marks the node as pending with its attempts reset if it was updated while it was in-flight, it
+// returns true if the node has to be added to the queue again.
+func (q *TaskQueue) requeueDirty(nodeName string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.items[nodeName]
+	if !ok || !q.dirty[nodeName] {
+		return false
+	}
+	delete(q.dirty, nodeName)
+
+	item.Status = StatusPending
+	item.Attempts = 0
+	item.LastError = ""
+	item.UpdatedAt = time.Now()
+	return true
+}
+
+// fail keeps the error of the last attempt and updates the status of the node.
+func (q *TaskQueue) fail(nodeName, status string, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if item, ok := q.items[nodeName]; ok {
+		item.Status = status
+		item.LastError = err.Error()
+		item.UpdatedAt = time.Now()
+	}
+}
+
+// pendingLen returns the number of nodes which haven't failed, it must be called holding the mutex.
+func (q *TaskQueue) pendingLen() int {
+	n := 0
+	for _, item := range q.items {
+		if item.Status != StatusFailed {
+			n++
+		}
+	}
+	return n
+}
+
+// Snapshot returns the state of the nodes in the queue, sorted by node name.
+func (q *TaskQueue) Snapshot() []QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := make([]QueueItem, 0, len(q.items))
+	for _, item := range q.items {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].NodeName < items[j].NodeName
+	})
+
+	return items
+}
+
+// Counts returns the number of nodes in the queue by status.
+func (q *TaskQueue) Counts() map[string]int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	counts := map[string]int64{
+		StatusPending:  0,
+		StatusInFlight: 0,
+		StatusFailed:   0,
+	}
+	for _, item := range q.items {
+		counts[item.Status]++
+	}
+
+	return counts
+}
+
+// ProcessTaskQueue processes the nodes added to the queue with QueueWorkers workers, until the context is done.
+func ProcessTaskQueue(ctx context.Context, red *redis.RedisClient) {
+	log.Info("Processing the queue with [", QueueWorkers, "] workers")
+	metrics.RegisterQueueDepth(TaskQueueName, func(context.Context) map[string]int64 {
+		return taskQueue.Counts()
+	})
+	taskQueue.Run(ctx, QueueWorkers, func(ctx context.Context, peer config.Peer) error {
+		return CheckNodesInDBOrCreateThem(peer, red, ctx)
+	})
+}
+
+// TaskQueueSnapshot returns the nodes in the queue grouped by their status.
+func TaskQueueSnapshot() TaskQueueState {
+	state := TaskQueueState{
+		Name:     TaskQueueName,
+		Pending:  []QueueItem{},
+		InFlight: []QueueItem{},
+		Failed:   []QueueItem{},
+	}
+	for _, item := range taskQueue.Snapshot() {
+		switch item.Status {
+		case StatusInFlight:
+			state.InFlight = append(state.InFlight, item)
+		case StatusFailed:
+			state.Failed = append(state.Failed, item)
 		default:
-			return
+			state.Pending = append(state.Pending, item)
 		}
 	}
+
+	return state
 }
 
 // CheckNodesInDBOrCreateThem try to find the node in the DB, if the node is not in the DB, it tries to create it.
+// It returns an error if the node id couldn't be generated, so the node can be retried later.
 func CheckNodesInDBOrCreateThem(peer config.Peer, red *redis.RedisClient, ctx context.Context) error {
-	log.Info("Processing Node in the queue: ", "[", peer.NodeName, "]")
+	logger := logging.ForNode(ctx, peer)
+	logger.Info("Processing node in the queue")
 	// check if the node is in the DB
 	ma, err := redis.CheckIfNodeExistsInDB(red, ctx, peer.NodeName)
 	if err != nil {
-		log.Error("Error CheckIfNodeExistsInDB for node: [", peer.NodeName, "]: ", err)
+		logger.WithError(err).Error("Error checking if the node exists in the DB")
 		return err
 	}
 
 	// if the node doesn't exist in the DB, let's try to create it
 	if ma == "" {
-		log.Info("Node ", "["+peer.NodeName+"]"+" NOT found in DB, let's try to generate it")
-		ma, err = GenerateNodeIdAndSaveIt(peer, peer.NodeName, red, ctx)
+		logger.Info("Node NOT found in DB, let's try to generate it")
+		ma, err = GenerateNodeIdAndSaveIt(peer, peer, red, ctx)
 		if err != nil {
-			log.Error("Error GenerateNodeIdAndSaveIt for full-node: [", peer.NodeName, "]", err)
+			logger.WithError(err).Error("Error generating the node id")
+			return err
 		}
-		return err
 	}
 
 	// check if the multi address is empty after trying to generate it
 	if ma == "" {
-		// check if the node is still under the maximum number of retries
-		if peer.RetryCount < MaxRetryCount {
-			log.Info("Node ", "["+peer.NodeName+"]"+" NOT found in DB, adding it to the queue, attempt: ", "[", peer.RetryCount, "]")
-			peer.RetryCount++ // increment the counter
-			AddToQueue(peer)
-		} else {
-			log.Info("Max retry count reached for node: ", "[", peer.NodeName, "]", "it might have some issues...")
-		}
-	} else {
-		log.Info("Node ", "[", peer.NodeName, "]", " found in DB, ID: ", "[", ma, "]")
-		// Register a multi-address metric
-		m := metrics.MultiAddrs{
-			ServiceName: "torch",
-			NodeName:    peer.NodeName,
-			MultiAddr:   ma,
-			Namespace:   peer.Namespace,
-			Value:       1,
-		}
-		metrics.RegisterMetric(m)
+		return errNodeIdNotReady
 	}
 
+	logger.WithField("id", ma).Info("Node found in DB")
+	// Register a multi-address metric
+	m := metrics.MultiAddrs{
+		ServiceName: "torch",
+		NodeName:    peer.NodeName,
+		MultiAddr:   ma,
+		Namespace:   peer.Namespace,
+		Value:       1,
+	}
+	metrics.RegisterMetric(m)
+
 	return nil
 }
 
-// AddToQueue adds a function to add peers to the queue if necessary.
-func AddToQueue(peer config.Peer) {
+// AddToQueue adds the peer to the queue to generate its id later, it doesn't block.
+func AddToQueue(ctx context.Context, peer config.Peer) {
 	peer.RetryCount = 0 // set the first attempt
-	log.Info("Node added to the queue: ", peer)
-	taskQueue <- peer
+	if err := taskQueue.Add(ctx, peer); err != nil {
+		logging.ForNode(ctx, peer).WithError(err).Error("Error adding the node to the queue")
+	}
+}
+
+// getEnvInt returns the value of the env var as int, or the default value if it's not defined or not valid.
+func getEnvInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Error("Invalid ", name, " [", value, "], using the default value: ", defaultValue)
+		return defaultValue
+	}
+
+	return n
 }
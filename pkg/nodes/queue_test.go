@@ -0,0 +1,275 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/logging"
+)
+
+func TestTaskQueueAdd(t *testing.T) {
+	q := NewTaskQueue(2, time.Hour)
+
+	if err := q.Add(context.Background(), config.Peer{NodeName: "da-full-1-0"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	// the same node is not added twice
+	if err := q.Add(context.Background(), config.Peer{NodeName: "da-full-1-0"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := q.Add(context.Background(), config.Peer{NodeName: "da-full-2-0"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	// the queue is full
+	if err := q.Add(context.Background(), config.Peer{NodeName: "da-full-3-0"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Add() error = %v, want %v", err, ErrQueueFull)
+	}
+
+	items := q.Snapshot()
+	if len(items) != 2 {
+		t.Fatalf("Snapshot() = %v, want 2 items", items)
+	}
+	for _, item := range items {
+		if item.Status != StatusPending || item.Attempts != 0 {
+			t.Errorf("Snapshot() item = %+v, want pending without attempts", item)
+		}
+	}
+}
+
+func TestTaskQueueRun(t *testing.T) {
+	q := NewTaskQueue(10, time.Millisecond)
+
+	var attempts atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx, 2, func(ctx context.Context, peer config.Peer) error {
+			if peer.NodeName == "da-full-ok-0" {
+				return nil
+			}
+			attempts.Add(1)
+			return errNodeIdNotReady
+		})
+	}()
+
+	_ = q.Add(context.Background(), config.Peer{NodeName: "da-full-ok-0"})
+	_ = q.Add(context.Background(), config.Peer{NodeName: "da-full-ko-0"})
+
+	// wait until the node fails all the retries
+	deadline := time.After(10 * time.Second)
+	for {
+		items := q.Snapshot()
+		if len(items) == 1 && items[0].Status == StatusFailed {
+			if items[0].NodeName != "da-full-ko-0" || items[0].Attempts != MaxRetryCount {
+				t.Errorf("Snapshot() item = %+v, want da-full-ko-0 failed after %d attempts", items[0], MaxRetryCount)
+			}
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for the node to fail: %+v", items)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if got := int(attempts.Load()); got != MaxRetryCount {
+		t.Errorf("attempts = %d, want %d", got, MaxRetryCount)
+	}
+
+	cancel()
+	<-done
+}
+
+// TestTaskQueueContextIDs checks that the node is processed with the request id of the context which added it, and
+// with the same job id in all its attempts.
+func TestTaskQueueContextIDs(t *testing.T) {
+	q := NewTaskQueue(10, time.Millisecond)
+
+	type ids struct{ requestID, jobID string }
+	seen := make(chan ids, MaxRetryCount)
+	var attempts atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx, 1, func(ctx context.Context, peer config.Peer) error {
+			seen <- ids{requestID: logging.RequestID(ctx), jobID: logging.JobID(ctx)}
+			// the first attempt fails, so the node is retried
+			if attempts.Add(1) == 1 {
+				return errNodeIdNotReady
+			}
+			return nil
+		})
+	}()
+
+	_ = q.Add(logging.WithRequestID(context.Background(), "req-1"), config.Peer{NodeName: "da-full-1-0"})
+
+	var got []ids
+	for len(got) < 2 {
+		select {
+		case id := <-seen:
+			got = append(got, id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for the attempts, got %+v", got)
+		}
+	}
+	cancel()
+	<-done
+
+	for _, id := range got {
+		if id.requestID != "req-1" {
+			t.Errorf("request id = %q, want req-1", id.requestID)
+		}
+		if id.jobID == "" || id.jobID != got[0].jobID {
+			t.Errorf("job ids = %+v, want the same non empty id in all the attempts", got)
+		}
+	}
+}
+
+// TestTaskQueueAddInFlight checks that a node added while it's processed is processed again with the new peer.
+func TestTaskQueueAddInFlight(t *testing.T) {
+	q := NewTaskQueue(10, time.Millisecond)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	seen := make(chan config.Peer, 2)
+	var calls atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx, 1, func(ctx context.Context, peer config.Peer) error {
+			seen <- peer
+			// the first attempt waits until the node is updated
+			if calls.Add(1) == 1 {
+				close(started)
+				<-release
+			}
+			return nil
+		})
+	}()
+
+	_ = q.Add(context.Background(), config.Peer{NodeName: "da-full-1-0", ContainerName: "da"})
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the node to be processed")
+	}
+
+	// the node is updated while it's in-flight
+	_ = q.Add(context.Background(), config.Peer{NodeName: "da-full-1-0", ContainerName: "celestia"})
+	close(release)
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case peer := <-seen:
+			got = append(got, peer.ContainerName)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for the node to be processed again, got %v", got)
+		}
+	}
+	if want := []string{"da", "celestia"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("containers processed = %v, want %v", got, want)
+	}
+
+	// the node is removed once it's processed with the new peer
+	deadline := time.After(5 * time.Second)
+	for len(q.Snapshot()) > 0 {
+		select {
+		case <-deadline:
+			t.Fatalf("Snapshot() = %+v, want the node removed", q.Snapshot())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	<-done
+}
+
+func TestTaskQueueCounts(t *testing.T) {
+	q := NewTaskQueue(10, time.Hour)
+	_ = q.Add(context.Background(), config.Peer{NodeName: "da-full-1-0"})
+	_ = q.Add(context.Background(), config.Peer{NodeName: "da-full-2-0"})
+	q.start("da-full-2-0")
+	_ = q.Add(context.Background(), config.Peer{NodeName: "da-full-3-0"})
+	q.fail("da-full-3-0", StatusFailed, errNodeIdNotReady)
+
+	want := map[string]int64{StatusPending: 1, StatusInFlight: 1, StatusFailed: 1}
+	if got := q.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts() = %v, want %v", got, want)
+	}
+}
+
+// TestTaskQueueDrain checks that the nodes in-flight keep running when the context is done, until the drain timeout.
+func TestTaskQueueDrain(t *testing.T) {
+	tests := []struct {
+		name      string
+		duration  time.Duration // duration of the job, it ignores the context done
+		wantErr   error
+		wantItems int
+	}{
+		{
+			name:      "Case 1: The node in-flight finishes before the drain timeout",
+			duration:  50 * time.Millisecond,
+			wantErr:   nil,
+			wantItems: 0,
+		},
+		{
+			name:      "Case 2: The node in-flight is cancelled after the drain timeout",
+			duration:  time.Hour,
+			wantErr:   context.Canceled,
+			wantItems: 1,
+		},
+	}
+
+	drainTimeout := DrainTimeout
+	DrainTimeout = 200 * time.Millisecond
+	defer func() { DrainTimeout = drainTimeout }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewTaskQueue(10, time.Millisecond)
+
+			started := make(chan struct{})
+			var gotErr error
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				q.Run(ctx, 1, func(jobCtx context.Context, peer config.Peer) error {
+					close(started)
+					select {
+					case <-time.After(tt.duration):
+					case <-jobCtx.Done():
+						gotErr = jobCtx.Err()
+					}
+					return gotErr
+				})
+			}()
+
+			_ = q.Add(context.Background(), config.Peer{NodeName: "da-full-1-0"})
+			<-started
+			cancel()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for the queue to drain")
+			}
+
+			if !errors.Is(gotErr, tt.wantErr) {
+				t.Errorf("job error = %v, want %v", gotErr, tt.wantErr)
+			}
+			if items := q.Snapshot(); len(items) != tt.wantItems {
+				t.Errorf("Snapshot() = %+v, want %d items", items, tt.wantItems)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package nodes
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/logging"
+)
+
+const (
+	ReconcileInSync  = "in-sync" // ReconcileInSync the files of the node have the desired content.
+	ReconcileDrifted = "drifted" // ReconcileDrifted the files of the node were different, and they were applied again.
+	ReconcilePending = "pending" // ReconcilePending the ids of the connections are not generated yet.
+	ReconcileError   = "error"   // ReconcileError the state of the node couldn't be checked or applied.
+)
+
+var (
+	// ReconcileInterval time between the checks of the nodes, RECONCILE_INTERVAL env var in seconds.
+	ReconcileInterval = time.Duration(getEnvInt("RECONCILE_INTERVAL", 300)) * time.Second
+
+	// placeholderAddr matches the addresses that are taken from the nodes, so they can be compared with any address.
+	placeholderAddr = regexp.MustCompile(regexp.QuoteMeta("<address of ") + `[^>]*>`)
+
+	reconciler = NewReconciler(readNodeFile, connectNode) // reconciler checks the nodes of the config.
+)
+
+// NodeStatus represents the result of reconciling a node.
+type NodeStatus struct {
+	Node      string    `json:"node"`              // Node name.
+	Status    string    `json:"status"`            // Status in-sync, drifted, pending or error.
+	Drifted   []string  `json:"drifted,omitempty"` // Drifted files that had a different content.
+	Error     string    `json:"error,omitempty"`   // Error found checking or applying the node.
+	CheckedAt time.Time `json:"checkedAt"`         // CheckedAt last time the node was checked.
+}
+
+// Reconciler compares the files of the nodes with the desired ones, and configures again the nodes that drifted.
+type Reconciler struct {
+	mu       sync.Mutex
+	statuses map[string]NodeStatus                                                                                   // statuses last result by node name.
+	readFile func(ctx context.Context, peer config.Peer, file string) (string, error)                                // readFile reads a file of the node.
+	apply    func(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) error // apply configures the node.
+}
+
+// NewReconciler returns a reconciler which reads the files and configures the nodes with the functions received.
+func NewReconciler(
+	readFile func(ctx context.Context, peer config.Peer, file string) (string, error),
+	apply func(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) error,
+) *Reconciler {
+	return &Reconciler{
+		statuses: make(map[string]NodeStatus),
+		readFile: readFile,
+		apply:    apply,
+	}
+}
+
+// Run reconciles all the nodes of the config on every interval, until the context is done.
+func (r *Reconciler) Run(ctx context.Context, cfg config.MutualPeersConfig, red *redis.RedisClient, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Reconciler stopped")
+			return
+		case <-ticker.C:
+			r.ReconcileAll(ctx, cfg, red)
+		}
+	}
+}
+
+// ReconcileAll reconciles the nodes of the config one by one.
+func (r *Reconciler) ReconcileAll(ctx context.Context, cfg config.MutualPeersConfig, red *redis.RedisClient) {
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			if ctx.Err() != nil {
+				return
+			}
+			status := r.ReconcileNode(ctx, peer, cfg, red)
+			if status.Status != ReconcileInSync {
+				logging.ForNode(ctx, peer).WithFields(log.Fields{
+					"status":  status.Status,
+					"drifted": status.Drifted,
+					"error":   status.Error,
+				}).Info("Node reconciled")
+			}
+		}
+	}
+}
+
+// ReconcileNode compares the files of the node with the ones in its plan, and applies the node again if they are
+// different. The nodes whose connections don't have an id yet are pending.
+func (r *Reconciler) ReconcileNode(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) NodeStatus {
+	status := NodeStatus{Node: peer.NodeName, Status: ReconcileInSync, CheckedAt: time.Now()}
+	defer r.setStatus(&status)
+
+	nodeType, err := ResolveNodeType(peer)
+	if err != nil {
+		status.Status = ReconcileError
+		status.Error = err.Error()
+		return status
+	}
+	peer = nodeType.SetDefaults(peer)
+
+	plan, err := nodeType.Plan(peer, cfg, red)
+	if err != nil {
+		status.Status = ReconcileError
+		status.Error = err.Error()
+		return status
+	}
+
+	files := make([]string, 0, len(plan.Files))
+	for file := range plan.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		desired := plan.Files[file]
+		if strings.Contains(desired, "<id of ") {
+			status.Status = ReconcilePending
+			return status
+		}
+
+		actual, err := r.readFile(ctx, peer, file)
+		if err != nil {
+			status.Status = ReconcileError
+			status.Error = err.Error()
+			return status
+		}
+		if !matchesDesired(desired, actual) {
+			status.Drifted = append(status.Drifted, file)
+		}
+	}
+
+	if len(status.Drifted) == 0 {
+		return status
+	}
+
+	status.Status = ReconcileDrifted
+	if err := r.apply(ctx, peer, cfg, red); err != nil {
+		status.Status = ReconcileError
+		status.Error = err.Error()
+	}
+
+	return status
+}
+
+// Statuses returns the last result of each node, sorted by node name.
+func (r *Reconciler) Statuses() []NodeStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]NodeStatus, 0, len(r.statuses))
+	for _, status := range r.statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Node < statuses[j].Node })
+
+	return statuses
+}
+
+// setStatus stores the result of the node.
+func (r *Reconciler) setStatus(status *NodeStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses[status.Node] = *status
+}
+
+// RunReconciler reconciles the nodes of the config periodically, until the context is done.
+func RunReconciler(ctx context.Context, cfg config.MutualPeersConfig, red *redis.RedisClient) {
+	log.Info("Reconciling the nodes every [", ReconcileInterval, "]")
+	reconciler.Run(ctx, cfg, red, ReconcileInterval)
+}
+
+// ReconcileSnapshot returns the last result of the reconciliation of each node.
+func ReconcileSnapshot() []NodeStatus {
+	return reconciler.Statuses()
+}
+
+// matchesDesired returns true if the actual content of the file is the desired one, the addresses taken from the
+// nodes match any IPv4 address and port.
+func matchesDesired(desired, actual string) bool {
+	actual = strings.TrimSpace(actual)
+	if !placeholderAddr.MatchString(desired) {
+		return desired == actual
+	}
+
+	parts := placeholderAddr.Split(desired, -1)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	pattern := "^" + strings.Join(parts, `/ip4/[0-9.]+/tcp/[0-9]+`) + "$"
+
+	return regexp.MustCompile(pattern).MatchString(actual)
+}
+
+// readNodeFile reads the file in the container of the node, the setup container has already exited when the pod runs.
+func readNodeFile(ctx context.Context, peer config.Peer, file string) (string, error) {
+	// Create a new context with a timeout
+	ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
+	defer cancel()
+
+	return k8s.RunRemoteCommand(ctx, peer.NodeName, peer.ContainerName, peerNamespace(peer), k8s.ReadFile(file))
+}
+
+// connectNode delivers the connections to the node, using its type.
+func connectNode(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) error {
+	nodeType, err := ResolveNodeType(peer)
+	if err != nil {
+		return err
+	}
+	return nodeType.Connect(ctx, nodeType.SetDefaults(peer), cfg, red)
+}
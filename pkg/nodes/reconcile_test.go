@@ -0,0 +1,115 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+)
+
+// reconcileKey key used to check that the context of the reconciler reaches the functions of the nodes.
+type reconcileKey struct{}
+
+func TestReconcileNode(t *testing.T) {
+	s := miniredis.RunT(t)
+	s.Set("da-bridge-1-0", "12D3KooWBridge1")
+	red := redis.NewRedisClient(s.Addr(), "", 0)
+
+	desired := "/dns/da-bridge-1/tcp/2121/p2p/12D3KooWBridge1"
+
+	tests := []struct {
+		name        string
+		peer        config.Peer
+		files       map[string]string
+		readErr     error
+		applyErr    error
+		wantStatus  string
+		wantDrifted []string
+		wantApplied bool
+	}{
+		{
+			name:       "Case 1: Node in sync",
+			peer:       config.Peer{NodeName: "da-full-1-0", NodeType: TypeDAFull, ConnectsTo: []string{"da-bridge-1-0"}, DnsConnections: []string{"da-bridge-1"}},
+			files:      map[string]string{fPathDA: desired + "\n"},
+			wantStatus: ReconcileInSync,
+		},
+		{
+			name:        "Case 2: Node which lost its file",
+			peer:        config.Peer{NodeName: "da-full-1-0", NodeType: TypeDAFull, ConnectsTo: []string{"da-bridge-1-0"}, DnsConnections: []string{"da-bridge-1"}},
+			files:       map[string]string{},
+			wantStatus:  ReconcileDrifted,
+			wantDrifted: []string{fPathDA},
+			wantApplied: true,
+		},
+		{
+			name:       "Case 3: Node using the IP of the connection",
+			peer:       config.Peer{NodeName: "da-full-1-0", NodeType: TypeDAFull, ConnectsTo: []string{"da-bridge-1-0"}},
+			files:      map[string]string{fPathDA: "/ip4/10.0.0.12/tcp/2121/p2p/12D3KooWBridge1"},
+			wantStatus: ReconcileInSync,
+		},
+		{
+			name:       "Case 4: Connection without id",
+			peer:       config.Peer{NodeName: "da-full-1-0", NodeType: TypeDAFull, ConnectsTo: []string{"da-bridge-2-0"}, DnsConnections: []string{"da-bridge-2"}},
+			wantStatus: ReconcilePending,
+		},
+		{
+			name:       "Case 5: File can't be read",
+			peer:       config.Peer{NodeName: "da-full-1-0", NodeType: TypeDAFull, ConnectsTo: []string{"da-bridge-1-0"}, DnsConnections: []string{"da-bridge-1"}},
+			readErr:    errors.New("pod not found"),
+			wantStatus: ReconcileError,
+		},
+		{
+			name:        "Case 6: Node can't be applied",
+			peer:        config.Peer{NodeName: "da-full-1-0", NodeType: TypeDAFull, ConnectsTo: []string{"da-bridge-1-0"}, DnsConnections: []string{"da-bridge-1"}},
+			files:       map[string]string{fPathDA: "/dns/da-bridge-1/tcp/2121/p2p/old"},
+			applyErr:    errors.New("exec failed"),
+			wantStatus:  ReconcileError,
+			wantDrifted: []string{fPathDA},
+			wantApplied: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := false
+			r := NewReconciler(
+				func(ctx context.Context, peer config.Peer, file string) (string, error) {
+					if peer.ContainerName != daContainerName {
+						t.Errorf("readFile() container = %s, want %s", peer.ContainerName, daContainerName)
+					}
+					if ctx.Value(reconcileKey{}) == nil {
+						t.Errorf("readFile() didn't receive the context of the reconciler")
+					}
+					return tt.files[file], tt.readErr
+				},
+				func(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) error {
+					if ctx.Value(reconcileKey{}) == nil {
+						t.Errorf("apply() didn't receive the context of the reconciler")
+					}
+					applied = true
+					return tt.applyErr
+				},
+			)
+
+			ctx := context.WithValue(context.Background(), reconcileKey{}, true)
+			got := r.ReconcileNode(ctx, tt.peer, config.MutualPeersConfig{}, red)
+			if got.Status != tt.wantStatus {
+				t.Errorf("ReconcileNode() status = %s, want %s: %s", got.Status, tt.wantStatus, got.Error)
+			}
+			if !reflect.DeepEqual(got.Drifted, tt.wantDrifted) {
+				t.Errorf("ReconcileNode() drifted = %v, want %v", got.Drifted, tt.wantDrifted)
+			}
+			if applied != tt.wantApplied {
+				t.Errorf("ReconcileNode() applied = %v, want %v", applied, tt.wantApplied)
+			}
+			if statuses := r.Statuses(); len(statuses) != 1 || statuses[0].Status != tt.wantStatus {
+				t.Errorf("Statuses() = %v", statuses)
+			}
+		})
+	}
+}
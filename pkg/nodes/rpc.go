@@ -0,0 +1,322 @@
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/logging"
+)
+
+const (
+	RPCModeDirect      = "direct"       // RPCModeDirect Torch connects to the IP of the pod, the RPC must listen on it.
+	RPCModePortForward = "port-forward" // RPCModePortForward Torch connects through a port forward of the API server.
+
+	daRPCPort          = 26658   // daRPCPort port of the RPC of the DA nodes.
+	authTokenSecretKey = "token" // authTokenSecretKey key of the Secret which contains the token.
+)
+
+var (
+	RPCMode = getRPCMode() // RPCMode how Torch connects to the RPC of the DA nodes, NODE_RPC_MODE env var.
+
+	// errUnauthorized returned when the node rejects the token.
+	errUnauthorized = errors.New("error: the node rejected the auth token")
+
+	tokensMu sync.Mutex
+	tokens   = make(map[string]string) // tokens cache of the auth tokens by namespace and node name.
+)
+
+// P2PInfo represents the response of p2p.Info.
+type P2PInfo struct {
+	ID    string   `json:"ID"`    // ID of the peer.
+	Addrs []string `json:"Addrs"` // Addrs multi addresses where the node listens.
+}
+
+// RoutableAddr returns the first listen address with an IPv4 which is not the loopback, or an empty string.
+func (i P2PInfo) RoutableAddr() string {
+	for _, addr := range i.Addrs {
+		parts := strings.Split(addr, "/")
+		// /ip4/<ip>/tcp/<port>
+		if len(parts) < 5 || parts[1] != "ip4" || parts[3] != "tcp" {
+			continue
+		}
+		if ip := net.ParseIP(parts[2]); ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+			continue
+		}
+		return addr
+	}
+	return ""
+}
+
+// RPCClient calls the JSON-RPC API of a node.
+type RPCClient struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+// rpcRequest represents a JSON-RPC request.
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// rpcResponse represents a JSON-RPC response.
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewRPCClient returns a client for the RPC in the url, using the token to authenticate.
+func NewRPCClient(url, token string) *RPCClient {
+	return &RPCClient{
+		url:        url,
+		token:      token,
+		httpClient: &http.Client{Timeout: timeoutDuration},
+	}
+}
+
+// Call calls the method with the params and decodes the result.
+func (c *RPCClient) Call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return errUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status calling [%s]: %s", method, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var rpcResp rpcResponse
+	if err := json.Unmarshal(data, &rpcResp); err != nil {
+		return fmt.Errorf("error decoding the response of [%s]: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("error calling [%s]: %d %s", method, rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+
+	return json.Unmarshal(rpcResp.Result, result)
+}
+
+// P2PInfo returns the ID and the listen addresses of the node.
+func (c *RPCClient) P2PInfo(ctx context.Context) (P2PInfo, error) {
+	var info P2PInfo
+	if err := c.Call(ctx, "p2p.Info", &info); err != nil {
+		return P2PInfo{}, err
+	}
+	if info.ID == "" {
+		return P2PInfo{}, errors.New("error: p2p.Info returned an empty ID")
+	}
+	return info, nil
+}
+
+// FetchP2PInfo calls p2p.Info in the RPC of the DA node, using the token of the node. If the token is rejected,
+// it's minted again once.
+func FetchP2PInfo(ctx context.Context, peer config.Peer) (P2PInfo, error) {
+	var info P2PInfo
+	err := callDANode(ctx, peer, "p2p.Info", func(client *RPCClient) error {
+		var err error
+		info, err = client.P2PInfo(ctx)
+		return err
+	})
+	return info, err
+}
+
+// FetchP2PPeers calls p2p.Peers in the RPC of the DA node, and returns the ids of the peers connected.
+func FetchP2PPeers(ctx context.Context, peer config.Peer) ([]string, error) {
+	var peers []string
+	err := callDANode(ctx, peer, "p2p.Peers", func(client *RPCClient) error {
+		return client.Call(ctx, "p2p.Peers", &peers)
+	})
+	return peers, err
+}
+
+// SyncState represents the response of header.SyncState.
+type SyncState struct {
+	Height   uint64 `json:"height"`    // Height of the latest header synced.
+	ToHeight uint64 `json:"to_height"` // ToHeight height of the network head known by the node.
+}
+
+// FetchSyncState calls header.SyncState in the RPC of the DA node.
+func FetchSyncState(ctx context.Context, peer config.Peer) (SyncState, error) {
+	var state SyncState
+	err := callDANode(ctx, peer, "header.SyncState", func(client *RPCClient) error {
+		return client.Call(ctx, "header.SyncState", &state)
+	})
+	return state, err
+}
+
+// callDANode calls the RPC of the DA node with the client received, using the token of the node. If the token is
+// rejected, it's minted again once.
+func callDANode(ctx context.Context, peer config.Peer, method string, call func(client *RPCClient) error) error {
+	url, stop, err := rpcEndpoint(ctx, peer)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	logger := logging.ForNode(ctx, peer).WithField("method", method)
+	for attempt := 0; attempt < 2; attempt++ {
+		token, err := authToken(ctx, peer)
+		if err != nil {
+			return err
+		}
+
+		err = call(NewRPCClient(url, token))
+		if errors.Is(err, errUnauthorized) {
+			logger.Warn("The token of the node was rejected, getting a new one")
+			forgetAuthToken(peer)
+			continue
+		}
+		if err != nil {
+			logger.WithError(err).Error("Error calling the RPC of the node")
+		}
+		return err
+	}
+
+	return errUnauthorized
+}
+
+// rpcEndpoint returns the URL of the RPC of the node and the function to release it, depending on the RPCMode.
+func rpcEndpoint(ctx context.Context, peer config.Peer) (string, func(), error) {
+	ns := peerNamespace(peer)
+
+	if RPCMode == RPCModePortForward {
+		port, stop, err := k8s.PortForward(ctx, peer.NodeName, ns, daRPCPort)
+		if err != nil {
+			return "", nil, err
+		}
+		return "http://127.0.0.1:" + strconv.Itoa(int(port)), stop, nil
+	}
+
+	ip, err := k8s.GetPodIP(ctx, peer.NodeName, ns)
+	if err != nil {
+		return "", nil, err
+	}
+	return "http://" + net.JoinHostPort(ip, strconv.Itoa(daRPCPort)), func() {}, nil
+}
+
+// authToken returns the token of the node, it's read from the Secret of the peer if it's specified, otherwise, it's
+// minted in the node. The token is cached until the node rejects it.
+func authToken(ctx context.Context, peer config.Peer) (string, error) {
+	tokensMu.Lock()
+	token, ok := tokens[tokenKey(peer)]
+	tokensMu.Unlock()
+	if ok {
+		return token, nil
+	}
+
+	var err error
+	if peer.AuthTokenSecret != "" {
+		token, err = k8s.GetSecretValue(ctx, peerNamespace(peer), peer.AuthTokenSecret, authTokenSecretKey)
+	} else {
+		token, err = mintAuthToken(ctx, peer)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	tokensMu.Lock()
+	tokens[tokenKey(peer)] = token
+	tokensMu.Unlock()
+
+	return token, nil
+}
+
+// mintAuthToken generates an admin token running the auth command of the node kind in the node.
+func mintAuthToken(ctx context.Context, peer config.Peer) (string, error) {
+	command := daNodeType(peer).AuthTokenCommand(peer)
+	output, err := k8s.RunRemoteCommand(ctx, peer.NodeName, peer.ContainerName, peerNamespace(peer), command)
+	if err != nil {
+		logging.ForNode(ctx, peer).WithError(err).Error(errRemoteCommand)
+		return "", err
+	}
+
+	// the command can print some warnings before the token, which is the last field
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("error: the token of the node [%s] is empty", peer.NodeName)
+	}
+
+	return fields[len(fields)-1], nil
+}
+
+// forgetAuthToken removes the token of the node from the cache.
+func forgetAuthToken(peer config.Peer) {
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	delete(tokens, tokenKey(peer))
+}
+
+// tokenKey returns the key of the token of the node in the cache, the nodes in different namespaces can have the same
+// name.
+func tokenKey(peer config.Peer) string {
+	return peerNamespace(peer) + "/" + peer.NodeName
+}
+
+// peerNamespace returns the namespace of the peer, or the namespace of Torch if it's not specified.
+func peerNamespace(peer config.Peer) string {
+	if peer.Namespace != "" {
+		return peer.Namespace
+	}
+	return k8s.GetCurrentNamespace()
+}
+
+// getRPCMode returns the mode specified in NODE_RPC_MODE, or port-forward if it's not valid. The port forward is the
+// default because celestia-node binds its RPC to localhost unless it runs with --rpc.addr 0.0.0.0.
+func getRPCMode() string {
+	mode := os.Getenv("NODE_RPC_MODE")
+	switch mode {
+	case RPCModeDirect, RPCModePortForward:
+		return mode
+	case "":
+		return RPCModePortForward
+	default:
+		log.Error("Invalid NODE_RPC_MODE [", mode, "], using the default value: ", RPCModePortForward)
+		return RPCModePortForward
+	}
+}
@@ -0,0 +1,111 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/jrmanes/torch/config"
+)
+
+func TestRPCClientP2PInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "p2p.Info" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch r.Header.Get("Authorization") {
+		case "Bearer valid":
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"ID":"12D3KooWNFpkX9fuo3GQ38FaVKdAZcTQsLr1BNE5DTHGjv2fjEHG","Addrs":["/ip4/127.0.0.1/tcp/2121","/ip4/100.64.5.103/tcp/2121"]}}`))
+		case "Bearer read":
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":1,"message":"missing permission"}}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		token   string
+		want    P2PInfo
+		wantErr error
+	}{
+		{
+			name:  "Case 1: Valid token",
+			token: "valid",
+			want: P2PInfo{
+				ID:    "12D3KooWNFpkX9fuo3GQ38FaVKdAZcTQsLr1BNE5DTHGjv2fjEHG",
+				Addrs: []string{"/ip4/127.0.0.1/tcp/2121", "/ip4/100.64.5.103/tcp/2121"},
+			},
+		},
+		{
+			name:    "Case 2: Token rejected",
+			token:   "invalid",
+			wantErr: errUnauthorized,
+		},
+		{
+			name:    "Case 3: Error in the response",
+			token:   "read",
+			wantErr: errors.New("error calling [p2p.Info]: 1 missing permission"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewRPCClient(server.URL, tt.token).P2PInfo(context.Background())
+			if (err == nil) != (tt.wantErr == nil) || (err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Fatalf("P2PInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("P2PInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestP2PInfoRoutableAddr(t *testing.T) {
+	tests := []struct {
+		name  string
+		addrs []string
+		want  string
+	}{
+		{
+			name:  "Case 1: The loopback and IPv6 are skipped",
+			addrs: []string{"/ip4/127.0.0.1/tcp/2121", "/ip6/::1/tcp/2121", "/ip4/100.64.5.103/tcp/2121"},
+			want:  "/ip4/100.64.5.103/tcp/2121",
+		},
+		{
+			name:  "Case 2: Only UDP addresses",
+			addrs: []string{"/ip4/100.64.5.103/udp/2121/quic-v1"},
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := P2PInfo{ID: "12D3KooW", Addrs: tt.addrs}
+			if got := info.RoutableAddr(); got != tt.want {
+				t.Errorf("RoutableAddr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenKey(t *testing.T) {
+	t.Parallel()
+	a := tokenKey(config.Peer{NodeName: "da-bridge-1-0", Namespace: "celestia"})
+	b := tokenKey(config.Peer{NodeName: "da-bridge-1-0", Namespace: "arabica"})
+	if a == b {
+		t.Errorf("tokenKey() = %s for the nodes of different namespaces", a)
+	}
+	if want := "celestia/da-bridge-1-0"; a != want {
+		t.Errorf("tokenKey() = %s, want %s", a, want)
+	}
+}
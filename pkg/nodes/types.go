@@ -0,0 +1,304 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/db/redis"
+	"github.com/jrmanes/torch/pkg/k8s"
+	"github.com/jrmanes/torch/pkg/logging"
+)
+
+const (
+	FamilyDA        = "da"        // FamilyDA data availability nodes, it's also the legacy value of nodeType.
+	FamilyConsensus = "consensus" // FamilyConsensus consensus nodes, it's also the legacy value of nodeType.
+
+	TypeConsensusValidator = "consensus-validator" // TypeConsensusValidator consensus node which produces blocks.
+	TypeConsensusFull      = "consensus-full"      // TypeConsensusFull consensus node which doesn't produce blocks.
+	TypeDABridge           = "da-bridge"           // TypeDABridge DA node connected to the consensus nodes.
+	TypeDAFull             = "da-full"             // TypeDAFull DA node which stores all the data.
+	TypeDALight            = "da-light"            // TypeDALight DA node which samples the data.
+
+	consensusP2PPort = "26656"          // consensusP2PPort port used by the consensus nodes to connect to their peers.
+	nodeStoreBase    = "/home/celestia" // nodeStoreBase home of the celestia user, where the DA nodes keep their store.
+)
+
+var (
+	trustedPeerFileConsensus = "/home/celestia/config/TP-ADDR" // trustedPeerFileConsensus file with the connection of the consensus nodes.
+	trustedPeerFileDA        = "/tmp/CONSENSUS_NODE_SERVICE"   // trustedPeerFileDA file with the consensus node of the DA nodes.
+	nodeTypesMu              sync.RWMutex                      // nodeTypesMu protects the registry.
+	nodeTypes                = make(map[string]NodeType)       // nodeTypes registry of the node types by name.
+	familyDefaults           = map[string]string{              // familyDefaults type used when the kind can't be taken from the node name.
+		FamilyDA:        TypeDABridge,
+		FamilyConsensus: TypeConsensusFull,
+	}
+)
+
+// NodeType defines how Torch configures a kind of node, adding a new kind only needs a new implementation registered
+// with RegisterNodeType.
+type NodeType interface {
+	// Name of the type, used as nodeType in the config.
+	Name() string
+	// Family of the type: da or consensus.
+	Family() string
+	// SetDefaults sets the default values of the peer in case they are empty.
+	SetDefaults(peer config.Peer) config.Peer
+	// FetchID returns the identity of the node, or an empty string if the node is not ready yet.
+	FetchID(ctx context.Context, peer config.Peer) (string, error)
+	// AdvertisedAddress returns the address used by the peer to connect to its connection in the index, using its id.
+	AdvertisedAddress(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, index int, id string) (string, error)
+	// Connect delivers the connections to the node, as part of the span in the context.
+	Connect(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) error
+	// Plan returns the actions that Connect would run, without running them.
+	Plan(peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) (Plan, error)
+}
+
+func init() {
+	RegisterNodeType(consensusNode{name: TypeConsensusValidator})
+	RegisterNodeType(consensusNode{name: TypeConsensusFull})
+	RegisterNodeType(daNode{name: TypeDABridge, kind: "bridge", nodeStore: defaultNodeStore("bridge")})
+	RegisterNodeType(daNode{name: TypeDAFull, kind: "full", nodeStore: defaultNodeStore("full")})
+	RegisterNodeType(daNode{name: TypeDALight, kind: "light", nodeStore: defaultNodeStore("light")})
+}
+
+// defaultNodeStore returns the store that celestia-node uses by default for the kind of node.
+func defaultNodeStore(kind string) string {
+	return nodeStoreBase + "/.celestia-" + kind
+}
+
+// RegisterNodeType adds the node type to the registry, replacing the one with the same name.
+func RegisterNodeType(t NodeType) {
+	nodeTypesMu.Lock()
+	defer nodeTypesMu.Unlock()
+	nodeTypes[t.Name()] = t
+}
+
+// GetNodeType returns the node type registered with the name received.
+func GetNodeType(name string) (NodeType, bool) {
+	nodeTypesMu.RLock()
+	defer nodeTypesMu.RUnlock()
+	t, ok := nodeTypes[name]
+	return t, ok
+}
+
+// NodeTypeNames returns the names of the node types registered, sorted.
+func NodeTypeNames() []string {
+	nodeTypesMu.RLock()
+	defer nodeTypesMu.RUnlock()
+
+	names := make([]string, 0, len(nodeTypes))
+	for name := range nodeTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ResolveNodeType returns the node type of the peer. The legacy values of nodeType (da and consensus) are resolved
+// using the prefix of the node name, e.g. da-full-1-0 is a da-full node, otherwise, the default of the family is used.
+// It returns an error if the name matches more than one type.
+func ResolveNodeType(peer config.Peer) (NodeType, error) {
+	if t, ok := GetNodeType(peer.NodeType); ok {
+		return t, nil
+	}
+
+	defaultType, ok := familyDefaults[peer.NodeType]
+	if !ok {
+		return nil, fmt.Errorf("unknown node type [%s] for node [%s], valid types: %s",
+			peer.NodeType, peer.NodeName, strings.Join(NodeTypeNames(), ", "))
+	}
+
+	var matches []string
+	for _, name := range NodeTypeNames() {
+		t, _ := GetNodeType(name)
+		if t.Family() != peer.NodeType {
+			continue
+		}
+		// the name is <family>-<kind>, e.g. da-full, and the node name must begin with it
+		if peer.NodeName == name || strings.HasPrefix(peer.NodeName, name+"-") {
+			matches = append(matches, name)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		t, _ := GetNodeType(defaultType)
+		return t, nil
+	case 1:
+		t, _ := GetNodeType(matches[0])
+		return t, nil
+	default:
+		return nil, fmt.Errorf("ambiguous node type for node [%s], it matches the types: %s, specify one of them in nodeType",
+			peer.NodeName, strings.Join(matches, ", "))
+	}
+}
+
+// TrustedPeerPool returns the full and bridge nodes of the config, except the node received, they are the trusted
+// peers of the light nodes which don't specify their connections.
+func TrustedPeerPool(nodeName string, cfg config.MutualPeersConfig) []string {
+	var pool []string
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			if peer.NodeName == nodeName {
+				continue
+			}
+			t, err := ResolveNodeType(peer)
+			if err != nil {
+				continue
+			}
+			if t.Name() == TypeDABridge || t.Name() == TypeDAFull {
+				pool = append(pool, peer.NodeName)
+			}
+		}
+	}
+
+	return pool
+}
+
+// daNodeType returns the DA type of the peer, if the type can't be resolved or it's not a DA node, it returns the
+// bridge type.
+func daNodeType(peer config.Peer) daNode {
+	t, err := ResolveNodeType(peer)
+	if err != nil {
+		log.WithField(logging.FieldNode, peer.NodeName).WithError(err).Warn("Error resolving the type of the node, using ", TypeDABridge)
+	}
+	d, ok := t.(daNode)
+	if !ok {
+		bridge, _ := GetNodeType(TypeDABridge)
+		d = bridge.(daNode)
+	}
+	return d
+}
+
+// setDefaults sets the containers and the namespace of the peer in case they are empty.
+func setDefaults(peer config.Peer, containerSetupName, containerName string) config.Peer {
+	if peer.ContainerSetupName == "" {
+		peer.ContainerSetupName = containerSetupName
+	}
+	if peer.ContainerName == "" {
+		peer.ContainerName = containerName
+	}
+	if peer.Namespace == "" {
+		peer.Namespace = namespace
+	}
+	return peer
+}
+
+// consensusNode configures the consensus nodes, they get their id from the API and connect using env vars.
+type consensusNode struct {
+	name string
+}
+
+// Name returns the name of the type.
+func (t consensusNode) Name() string { return t.name }
+
+// Family returns the family of the type.
+func (t consensusNode) Family() string { return FamilyConsensus }
+
+// SetDefaults sets the default values of the consensus nodes.
+func (t consensusNode) SetDefaults(peer config.Peer) config.Peer {
+	return setDefaults(peer, consContainerSetupName, consContainerName)
+}
+
+// FetchID returns the node id from the status of the node, using the service name if it's specified.
+func (t consensusNode) FetchID(_ context.Context, peer config.Peer) (string, error) {
+	host := peer.ServiceName
+	if host == "" {
+		host = peer.NodeName
+	}
+	return ConsensusNodesIDs(host)
+}
+
+// AdvertisedAddress returns the address as id@host:port, using the DNS record if it's specified.
+func (t consensusNode) AdvertisedAddress(_ context.Context, peer config.Peer, _ config.MutualPeersConfig, index int, id string) (string, error) {
+	if index >= len(peer.ConnectsTo) {
+		return "", fmt.Errorf("node [%s] doesn't have the connection [%d]", peer.NodeName, index)
+	}
+
+	return consensusAddress(id, consensusHost(peer, index)), nil
+}
+
+// Connect writes the connection of the node when it uses env vars, otherwise, the persistent_peers and seeds built from
+// its connections.
+func (t consensusNode) Connect(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) error {
+	if peer.ConnectsAsEnvVar {
+		logging.ForNode(ctx, peer).Info("The node uses env var to connect")
+		return SetupNodesEnvVarAndConnections(ctx, peer, cfg, trustedPeerFileConsensus)
+	}
+
+	if len(peer.ConnectsTo) == 0 && len(peer.Seeds) == 0 {
+		return nil
+	}
+	return SetupConsensusNodeWithPeers(ctx, peer, red)
+}
+
+// daNode configures the DA nodes, they get their id from the node itself and connect using multi addresses.
+type daNode struct {
+	name      string
+	kind      string // kind used in the celestia commands: bridge, full or light.
+	nodeStore string // nodeStore default path of the store, used to generate the auth token.
+}
+
+// Name returns the name of the type.
+func (t daNode) Name() string { return t.name }
+
+// Family returns the family of the type.
+func (t daNode) Family() string { return FamilyDA }
+
+// SetDefaults sets the default values of the DA nodes.
+func (t daNode) SetDefaults(peer config.Peer) config.Peer {
+	return setDefaults(peer, daContainerSetupName, daContainerName)
+}
+
+// FetchID returns the node id running the command in the node.
+func (t daNode) FetchID(ctx context.Context, peer config.Peer) (string, error) {
+	return GenerateNodeId(ctx, peer)
+}
+
+// AuthTokenCommand returns the command to mint the token of the node, using the auth command and the store of the
+// node kind, the store can be overwritten with the nodeStore of the peer.
+func (t daNode) AuthTokenCommand(peer config.Peer) []string {
+	nodeStore := peer.NodeStore
+	if nodeStore == "" {
+		nodeStore = t.nodeStore
+	}
+	return k8s.CreateAuthTokenCommand(t.kind, nodeStore)
+}
+
+// AdvertisedAddress returns the multi address using the DNS record or the IP of the connection.
+func (t daNode) AdvertisedAddress(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, index int, id string) (string, error) {
+	return SetIdPrefix(ctx, peer, cfg, id, index)
+}
+
+// Connect writes the consensus node when the node uses env vars, otherwise, it writes the multi addresses of its
+// connections. The light nodes without connections use the full and bridge nodes of the config as trusted peers.
+// The node is added to the queue to generate its id later.
+func (t daNode) Connect(ctx context.Context, peer config.Peer, cfg config.MutualPeersConfig, red *redis.RedisClient) error {
+	if !peer.ConnectsAsEnvVar {
+		if t.name == TypeDALight && len(peer.ConnectsTo) == 0 {
+			peer.ConnectsTo = TrustedPeerPool(peer.NodeName, cfg)
+			if len(peer.ConnectsTo) == 0 {
+				return fmt.Errorf("there are no full or bridge nodes in the config to connect the light node [%s]", peer.NodeName)
+			}
+			logging.ForNode(ctx, peer).WithField("pool", peer.ConnectsTo).Info("Light node connects to the pool")
+		}
+		return SetupDANodeWithConnections(ctx, peer, cfg, red)
+	}
+
+	logging.ForNode(ctx, peer).Info("The node uses env var to connect")
+	if err := SetupNodesEnvVarAndConnections(ctx, peer, cfg, trustedPeerFileDA); err != nil {
+		return err
+	}
+
+	// the queue doesn't block, the node is processed later by the workers.
+	AddToQueue(ctx, peer)
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package nodes
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/k8s"
+)
+
+func TestResolveNodeType(t *testing.T) {
+	tests := []struct {
+		name    string
+		peer    config.Peer
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Case 1: Node type specified",
+			peer: config.Peer{NodeName: "da-node-1-0", NodeType: TypeDALight},
+			want: TypeDALight,
+		},
+		{
+			name: "Case 2: Legacy DA node with the kind in the name",
+			peer: config.Peer{NodeName: "da-full-1-0", NodeType: "da"},
+			want: TypeDAFull,
+		},
+		{
+			name: "Case 3: Legacy DA node without the kind in the name",
+			peer: config.Peer{NodeName: "celestia-node-0", NodeType: "da"},
+			want: TypeDABridge,
+		},
+		{
+			name: "Case 4: Legacy consensus validator",
+			peer: config.Peer{NodeName: "consensus-validator-1", NodeType: "consensus"},
+			want: TypeConsensusValidator,
+		},
+		{
+			name: "Case 5: Legacy consensus node without the kind in the name",
+			peer: config.Peer{NodeName: "consensus-1", NodeType: "consensus"},
+			want: TypeConsensusFull,
+		},
+		{
+			name:    "Case 6: Unknown node type",
+			peer:    config.Peer{NodeName: "da-bridge-1-0", NodeType: "unknown"},
+			wantErr: true,
+		},
+		{
+			name: "Case 7: Legacy DA node with the kind in the middle of the name",
+			peer: config.Peer{NodeName: "celestia-full-1-0", NodeType: "da"},
+			want: TypeDABridge,
+		},
+		{
+			name: "Case 8: Legacy DA node with another kind after the prefix",
+			peer: config.Peer{NodeName: "da-light-full-0", NodeType: "da"},
+			want: TypeDALight,
+		},
+		{
+			name:    "Case 9: Legacy DA node which matches more than one type",
+			peer:    config.Peer{NodeName: "da-full-archive-0", NodeType: "da"},
+			wantErr: true,
+		},
+	}
+
+	// a custom type whose name begins with the name of another type
+	RegisterNodeType(daNode{name: "da-full-archive", kind: "full", nodeStore: defaultNodeStore("full")})
+	defer func() {
+		nodeTypesMu.Lock()
+		defer nodeTypesMu.Unlock()
+		delete(nodeTypes, "da-full-archive")
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveNodeType(tt.peer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveNodeType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Name() != tt.want {
+				t.Errorf("ResolveNodeType() = %v, want %v", got.Name(), tt.want)
+			}
+		})
+	}
+}
+
+func TestConsensusAdvertisedAddress(t *testing.T) {
+	nodeType, _ := GetNodeType(TypeConsensusFull)
+
+	tests := []struct {
+		name    string
+		peer    config.Peer
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Case 1: Using the node name",
+			peer: config.Peer{NodeName: "consensus-full-1", ConnectsTo: []string{"consensus-validator-1"}},
+			want: "f3e1a2@consensus-validator-1:26656",
+		},
+		{
+			name: "Case 2: Using the DNS record",
+			peer: config.Peer{
+				NodeName:       "consensus-full-1",
+				ConnectsTo:     []string{"consensus-validator-1"},
+				DnsConnections: []string{"consensus-validator-1.celestia.svc"},
+			},
+			want: "f3e1a2@consensus-validator-1.celestia.svc:26656",
+		},
+		{
+			name:    "Case 3: Connection not found",
+			peer:    config.Peer{NodeName: "consensus-full-1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nodeType.AdvertisedAddress(context.Background(), tt.peer, config.MutualPeersConfig{}, 0, "f3e1a2")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AdvertisedAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("AdvertisedAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedPeerPool(t *testing.T) {
+	cfg := config.MutualPeersConfig{
+		MutualPeers: []*config.MutualPeer{
+			{
+				Peers: []config.Peer{
+					{NodeName: "consensus-full-1", NodeType: "consensus"},
+					{NodeName: "da-bridge-1-0", NodeType: "da"},
+					{NodeName: "da-full-1-0", NodeType: TypeDAFull},
+					{NodeName: "da-light-1-0", NodeType: TypeDALight},
+					{NodeName: "da-light-2-0", NodeType: "da"},
+				},
+			},
+		},
+	}
+
+	want := []string{"da-bridge-1-0", "da-full-1-0"}
+	if got := TrustedPeerPool("da-light-1-0", cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("TrustedPeerPool() = %v, want %v", got, want)
+	}
+}
+
+func TestAuthTokenCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		peer config.Peer
+		want []string
+	}{
+		{
+			name: "Case 1: Light node using the default store",
+			peer: config.Peer{NodeName: "da-light-1-0", NodeType: "da"},
+			want: k8s.CreateAuthTokenCommand("light", "/home/celestia/.celestia-light"),
+		},
+		{
+			name: "Case 2: Full node with its own store",
+			peer: config.Peer{NodeName: "da-full-1-0", NodeType: TypeDAFull, NodeStore: "/data"},
+			want: k8s.CreateAuthTokenCommand("full", "/data"),
+		},
+		{
+			name: "Case 3: Unknown node type uses the bridge command",
+			peer: config.Peer{NodeName: "da-1-0", NodeType: "unknown"},
+			want: k8s.CreateAuthTokenCommand("bridge", "/home/celestia/.celestia-bridge"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := daNodeType(tt.peer).AuthTokenCommand(tt.peer); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AuthTokenCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jrmanes/torch/pkg/nodes"
+)
+
+const (
+	SeverityError   = "error"   // SeverityError the node can't be configured.
+	SeverityWarning = "warning" // SeverityWarning the node can be configured, but it might not join the network.
+
+	IssueSelfReference     = "self-reference"     // IssueSelfReference the node connects to itself.
+	IssueDanglingReference = "dangling-reference" // IssueDanglingReference the node connects to a node that doesn't exist.
+	IssueUnknownType       = "unknown-type"       // IssueUnknownType the type of the node can't be resolved.
+	IssueNoUpstream        = "no-upstream"        // IssueNoUpstream the node has no path to a bridge or consensus node.
+	IssueUnreachable       = "unreachable"        // IssueUnreachable the node is not linked to the consensus nodes.
+)
+
+// Issue represents a problem found in the topology.
+type Issue struct {
+	Kind     string `json:"kind"`             // Kind of issue.
+	Severity string `json:"severity"`         // Severity error or warning.
+	Node     string `json:"node"`             // Node where the issue was found.
+	Target   string `json:"target,omitempty"` // Target reference of the connection, if any.
+	Message  string `json:"message"`          // Message describing the issue.
+}
+
+// Report represents the result of checking the topology.
+type Report struct {
+	Valid  bool       `json:"valid"`  // Valid true if there are no errors.
+	Nodes  int        `json:"nodes"`  // Nodes number of nodes in the graph.
+	Edges  int        `json:"edges"`  // Edges number of connections between nodes of the config.
+	Issues []Issue    `json:"issues"` // Issues found, sorted by node.
+	Cycles [][]string `json:"cycles"` // Cycles groups of nodes that connect to each other.
+}
+
+// upstreams types that the nodes of each type must reach, the nodes without requirements are not checked.
+var upstreams = map[string][]string{
+	nodes.TypeDAFull:        {nodes.TypeDABridge},
+	nodes.TypeDALight:       {nodes.TypeDABridge},
+	nodes.TypeDABridge:      {nodes.TypeConsensusValidator, nodes.TypeConsensusFull},
+	nodes.TypeConsensusFull: {nodes.TypeConsensusValidator},
+}
+
+// Check returns the issues of the graph: self and dangling references, nodes with an unknown type, nodes without
+// a path to a bridge or consensus node, and nodes that are not linked to the consensus nodes.
+func (g *Graph) Check() Report {
+	report := Report{
+		Valid:  true,
+		Nodes:  len(g.Nodes),
+		Edges:  g.EdgeCount(),
+		Issues: []Issue{},
+		Cycles: g.Cycles(),
+	}
+
+	for _, ref := range g.Self {
+		report.add(Issue{
+			Kind:     IssueSelfReference,
+			Severity: SeverityError,
+			Node:     ref.From,
+			Target:   ref.Target,
+			Message:  fmt.Sprintf("the node references itself in %s", ref.Kind),
+		})
+	}
+	for _, ref := range g.Dangling {
+		report.add(Issue{
+			Kind:     IssueDanglingReference,
+			Severity: SeverityError,
+			Node:     ref.From,
+			Target:   ref.Target,
+			Message:  fmt.Sprintf("the node in %s doesn't exist in the config", ref.Kind),
+		})
+	}
+	for name, err := range g.Unknown {
+		report.add(Issue{
+			Kind:     IssueUnknownType,
+			Severity: SeverityError,
+			Node:     name,
+			Message:  err,
+		})
+	}
+
+	external := g.externalNodes()
+	for _, name := range g.Names() {
+		required, ok := upstreams[g.Nodes[name].Type]
+		if !ok || g.reaches(name, required, external) {
+			continue
+		}
+		report.add(Issue{
+			Kind:     IssueNoUpstream,
+			Severity: SeverityWarning,
+			Node:     name,
+			Message:  fmt.Sprintf("the node has no path to a node of type %v", required),
+		})
+	}
+
+	linked := g.linkedToConsensus()
+	for _, name := range g.Names() {
+		if linked == nil || linked[name] || external[name] {
+			continue
+		}
+		report.add(Issue{
+			Kind:     IssueUnreachable,
+			Severity: SeverityWarning,
+			Node:     name,
+			Message:  "the node is not linked to any consensus node of the config",
+		})
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Node < report.Issues[j].Node
+	})
+
+	return report
+}
+
+// Cycles returns the groups of nodes that reach each other, the nodes of each group and the groups are sorted.
+func (g *Graph) Cycles() [][]string {
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	cycles := [][]string{}
+
+	// Tarjan's algorithm to find the strongly connected components
+	var connect func(name string)
+	connect = func(name string) {
+		index[name] = len(index)
+		lowLink[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, edge := range g.Edges[name] {
+			if _, ok := index[edge.To]; !ok {
+				connect(edge.To)
+				lowLink[name] = min(lowLink[name], lowLink[edge.To])
+			} else if onStack[edge.To] {
+				lowLink[name] = min(lowLink[name], index[edge.To])
+			}
+		}
+
+		if lowLink[name] != index[name] {
+			return
+		}
+		var component []string
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == name {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, name := range g.Names() {
+		if _, ok := index[name]; !ok {
+			connect(name)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+
+	return cycles
+}
+
+// add adds the issue to the report, the report is not valid if the issue is an error.
+func (r *Report) add(issue Issue) {
+	if issue.Severity == SeverityError {
+		r.Valid = false
+	}
+	r.Issues = append(r.Issues, issue)
+}
+
+// reaches returns true if the node has a path to a node of the types received, or to an external multi address,
+// which can't be checked.
+func (g *Graph) reaches(name string, types []string, external map[string]bool) bool {
+	visited := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if external[current] {
+			return true
+		}
+		for _, edge := range g.Edges[current] {
+			if visited[edge.To] {
+				continue
+			}
+			for _, t := range types {
+				if g.Nodes[edge.To].Type == t {
+					return true
+				}
+			}
+			visited[edge.To] = true
+			queue = append(queue, edge.To)
+		}
+	}
+	return false
+}
+
+// linkedToConsensus returns the nodes linked in any direction to the consensus nodes, or nil if there are no
+// consensus nodes in the config.
+func (g *Graph) linkedToConsensus() map[string]bool {
+	// the links in both directions
+	links := make(map[string][]string)
+	for from, edges := range g.Edges {
+		for _, edge := range edges {
+			links[from] = append(links[from], edge.To)
+			links[edge.To] = append(links[edge.To], from)
+		}
+	}
+
+	linked := make(map[string]bool)
+	var queue []string
+	for _, name := range g.Names() {
+		if g.Nodes[name].Family == nodes.FamilyConsensus {
+			linked[name] = true
+			queue = append(queue, name)
+		}
+	}
+	if len(queue) == 0 {
+		return nil
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range links[current] {
+			if !linked[next] {
+				linked[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return linked
+}
+
+// externalNodes returns the nodes which connect to external multi addresses.
+func (g *Graph) externalNodes() map[string]bool {
+	external := make(map[string]bool)
+	for _, ref := range g.External {
+		external[ref.From] = true
+	}
+	return external
+}
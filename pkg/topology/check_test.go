@@ -0,0 +1,91 @@
+package topology
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jrmanes/torch/config"
+)
+
+// issueKinds returns the kind and node of the issues, to compare them without the messages.
+func issueKinds(issues []Issue) [][2]string {
+	kinds := [][2]string{}
+	for _, issue := range issues {
+		kinds = append(kinds, [2]string{issue.Kind, issue.Node})
+	}
+	return kinds
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        config.MutualPeersConfig
+		wantValid  bool
+		wantIssues [][2]string
+		wantCycles [][]string
+	}{
+		{
+			name: "Case 1: Valid topology",
+			cfg: config.MutualPeersConfig{MutualPeers: []*config.MutualPeer{
+				{ConsensusNode: "consensus-validator-1"},
+				{Peers: []config.Peer{{NodeName: "consensus-full-1-0", NodeType: "consensus", ConnectsAsEnvVar: true, ConnectsTo: []string{"consensus-validator-1"}}}},
+				{Peers: []config.Peer{{NodeName: "da-bridge-1-0", NodeType: "da", ConnectsAsEnvVar: true, ConnectsTo: []string{"consensus-full-1"}}}},
+				{Peers: []config.Peer{{NodeName: "da-full-1-0", NodeType: "da", ConnectsTo: []string{"da-bridge-1-0", "da-full-2-0"}}}},
+				{Peers: []config.Peer{{NodeName: "da-full-2-0", NodeType: "da", ConnectsTo: []string{"da-full-1-0"}}}},
+				{Peers: []config.Peer{{NodeName: "da-full-3-0", NodeType: "da", ConnectsTo: []string{"/dns/da-bridge-9/tcp/2121/p2p/12D3KooW"}}}},
+				{Peers: []config.Peer{{NodeName: "da-light-1-0", NodeType: "da-light"}}},
+			}},
+			wantValid:  true,
+			wantIssues: [][2]string{},
+			wantCycles: [][]string{{"da-full-1-0", "da-full-2-0"}},
+		},
+		{
+			name: "Case 2: Full nodes that only reach each other",
+			cfg: config.MutualPeersConfig{MutualPeers: []*config.MutualPeer{
+				{ConsensusNode: "consensus-validator-1"},
+				{Peers: []config.Peer{{NodeName: "da-bridge-1-0", NodeType: "da", ConnectsTo: []string{"consensus-validator-1"}}}},
+				{Peers: []config.Peer{{NodeName: "da-full-1-0", NodeType: "da", ConnectsTo: []string{"da-full-2-0"}}}},
+				{Peers: []config.Peer{{NodeName: "da-full-2-0", NodeType: "da", ConnectsTo: []string{"da-full-1-0"}}}},
+			}},
+			wantValid: true,
+			wantIssues: [][2]string{
+				{IssueNoUpstream, "da-full-1-0"},
+				{IssueUnreachable, "da-full-1-0"},
+				{IssueNoUpstream, "da-full-2-0"},
+				{IssueUnreachable, "da-full-2-0"},
+			},
+			wantCycles: [][]string{{"da-full-1-0", "da-full-2-0"}},
+		},
+		{
+			name: "Case 3: Dangling and self references",
+			cfg: config.MutualPeersConfig{MutualPeers: []*config.MutualPeer{
+				{Peers: []config.Peer{{NodeName: "da-bridge-1-0", NodeType: "da", ConnectsTo: []string{"consensus-full-9"}}}},
+				{Peers: []config.Peer{{NodeName: "da-full-1-0", NodeType: "da", ConnectsTo: []string{"da-full-1", "da-bridge-1-0"}}}},
+				{Peers: []config.Peer{{NodeName: "da-full-2-0", NodeType: "unknown"}}},
+			}},
+			wantValid: false,
+			wantIssues: [][2]string{
+				{IssueDanglingReference, "da-bridge-1-0"},
+				{IssueNoUpstream, "da-bridge-1-0"},
+				{IssueSelfReference, "da-full-1-0"},
+				{IssueUnknownType, "da-full-2-0"},
+			},
+			wantCycles: [][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Build(tt.cfg).Check()
+			if got.Valid != tt.wantValid {
+				t.Errorf("Check() valid = %v, want %v, issues: %v", got.Valid, tt.wantValid, got.Issues)
+			}
+			if kinds := issueKinds(got.Issues); !reflect.DeepEqual(kinds, tt.wantIssues) {
+				t.Errorf("Check() issues = %v, want %v", kinds, tt.wantIssues)
+			}
+			if !reflect.DeepEqual(got.Cycles, tt.wantCycles) {
+				t.Errorf("Check() cycles = %v, want %v", got.Cycles, tt.wantCycles)
+			}
+		})
+	}
+}
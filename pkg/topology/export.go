@@ -0,0 +1,115 @@
+package topology
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	FormatJSON = "json" // FormatJSON encodes the topology as JSON.
+	FormatDOT  = "dot"  // FormatDOT encodes the topology as Graphviz DOT.
+
+	StatusConfigured = "configured" // StatusConfigured the id of the node is stored in the DB.
+	StatusPending    = "pending"    // StatusPending the id of the node is not stored in the DB yet.
+	StatusUnknown    = "unknown"    // StatusUnknown the DB was not checked, e.g. rendering the config offline.
+)
+
+// GraphNode represents a node of the exported topology.
+type GraphNode struct {
+	Node
+	Address string `json:"address,omitempty"` // Address multi address of the DA nodes, or id of the consensus nodes.
+	Status  string `json:"status"`            // Status of the configuration: configured, pending or unknown.
+}
+
+// Export represents the topology with the nodes and their connections.
+type Export struct {
+	Nodes    []GraphNode `json:"nodes"`    // Nodes sorted by name.
+	Edges    []Edge      `json:"edges"`    // Edges connections between nodes of the config.
+	External []Reference `json:"external"` // External multi addresses.
+	Dangling []Reference `json:"dangling"` // Dangling references to nodes that don't exist.
+}
+
+// Export returns the nodes and the connections of the graph, the nodes are enriched with the values stored in the
+// DB, if stored is nil, the status of the nodes is unknown.
+func (g *Graph) Export(stored map[string]string) Export {
+	export := Export{
+		Nodes:    []GraphNode{},
+		Edges:    []Edge{},
+		External: append([]Reference{}, g.External...),
+		Dangling: append([]Reference{}, g.Dangling...),
+	}
+
+	for _, name := range g.Names() {
+		node := GraphNode{Node: *g.Nodes[name], Status: StatusUnknown}
+		if stored != nil {
+			node.Status = StatusPending
+			if address, ok := stored[name]; ok && address != "" {
+				node.Address = address
+				node.Status = StatusConfigured
+			}
+		}
+		export.Nodes = append(export.Nodes, node)
+		export.Edges = append(export.Edges, g.Edges[name]...)
+	}
+
+	return export
+}
+
+// EncodeDOT encodes the topology as a Graphviz digraph.
+func EncodeDOT(export Export) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("digraph torch {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=filled, fillcolor=white];\n")
+
+	for _, node := range export.Nodes {
+		label := node.Name + "\\n" + node.Type + "\\n" + node.Status
+		fmt.Fprintf(&b, "  %s [label=%s, fillcolor=%s];\n", dotID(node.Name), dotID(label), statusColor(node.Status))
+	}
+	for _, edge := range export.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s%s];\n", dotID(edge.From), dotID(edge.To), dotID(edge.Kind), edgeStyle(edge.Kind))
+	}
+	for _, ref := range export.External {
+		fmt.Fprintf(&b, "  %s [shape=note, label=\"external\", tooltip=%s];\n", dotID(ref.Target), dotID(ref.Target))
+		fmt.Fprintf(&b, "  %s -> %s [label=%s, style=dotted];\n", dotID(ref.From), dotID(ref.Target), dotID(ref.Kind))
+	}
+	for _, ref := range export.Dangling {
+		fmt.Fprintf(&b, "  %s [shape=ellipse, color=red, fontcolor=red];\n", dotID(ref.Target))
+		fmt.Fprintf(&b, "  %s -> %s [label=%s, color=red];\n", dotID(ref.From), dotID(ref.Target), dotID(ref.Kind))
+	}
+
+	b.WriteString("}\n")
+
+	return b.Bytes()
+}
+
+// dotID quotes the value to use it as an ID or a label in DOT.
+func dotID(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// statusColor returns the fill color of the nodes with the status received.
+func statusColor(status string) string {
+	switch status {
+	case StatusConfigured:
+		return "palegreen"
+	case StatusPending:
+		return "khaki"
+	default:
+		return "white"
+	}
+}
+
+// edgeStyle returns the attributes of the edges with the kind received.
+func edgeStyle(kind string) string {
+	switch kind {
+	case EdgeEnvVar:
+		return ", style=bold"
+	case EdgeSeed, EdgeTrustedPool:
+		return ", style=dashed"
+	default:
+		return ""
+	}
+}
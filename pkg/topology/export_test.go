@@ -0,0 +1,65 @@
+package topology
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jrmanes/torch/config"
+)
+
+func TestExport(t *testing.T) {
+	cfg := config.MutualPeersConfig{MutualPeers: []*config.MutualPeer{
+		{Peers: []config.Peer{{NodeName: "da-bridge-1-0", NodeType: "da", ConnectsAsEnvVar: true, ConnectsTo: []string{"consensus-full-9"}}}},
+		{Peers: []config.Peer{{NodeName: "da-full-1-0", NodeType: "da", ConnectsTo: []string{"da-bridge-1-0"}}}},
+	}}
+
+	tests := []struct {
+		name       string
+		stored     map[string]string
+		wantStatus map[string]string
+		wantDOT    []string
+	}{
+		{
+			name:       "Case 1: Without the DB",
+			stored:     nil,
+			wantStatus: map[string]string{"da-bridge-1-0": StatusUnknown, "da-full-1-0": StatusUnknown},
+			wantDOT: []string{
+				`"da-full-1-0" -> "da-bridge-1-0" [label="connectsTo"];`,
+				`"da-bridge-1-0" -> "consensus-full-9" [label="envVar", color=red];`,
+			},
+		},
+		{
+			name:       "Case 2: With the nodes stored in the DB",
+			stored:     map[string]string{"da-bridge-1-0": "/dns/da-bridge-1/tcp/2121/p2p/12D3KooW"},
+			wantStatus: map[string]string{"da-bridge-1-0": StatusConfigured, "da-full-1-0": StatusPending},
+			wantDOT: []string{
+				`"da-bridge-1-0" [label="da-bridge-1-0\nda-bridge\nconfigured", fillcolor=palegreen];`,
+				`"da-full-1-0" [label="da-full-1-0\nda-full\npending", fillcolor=khaki];`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			export := Build(cfg).Export(tt.stored)
+			if len(export.Nodes) != len(tt.wantStatus) {
+				t.Fatalf("Export() nodes = %v, want %d nodes", export.Nodes, len(tt.wantStatus))
+			}
+			for _, node := range export.Nodes {
+				if node.Status != tt.wantStatus[node.Name] {
+					t.Errorf("Export() status of [%s] = %s, want %s", node.Name, node.Status, tt.wantStatus[node.Name])
+				}
+				if node.Address != tt.stored[node.Name] {
+					t.Errorf("Export() address of [%s] = %s, want %s", node.Name, node.Address, tt.stored[node.Name])
+				}
+			}
+
+			dot := string(EncodeDOT(export))
+			for _, line := range tt.wantDOT {
+				if !strings.Contains(dot, line) {
+					t.Errorf("EncodeDOT() doesn't contain %s:\n%s", line, dot)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package topology
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/nodes"
+)
+
+const (
+	EdgeConnectsTo    = "connectsTo"    // EdgeConnectsTo connection specified in connectsTo.
+	EdgeEnvVar        = "envVar"        // EdgeEnvVar connection delivered as env var, the first one of connectsTo.
+	EdgeSeed          = "seed"          // EdgeSeed seed of a consensus node.
+	EdgeConsensusNode = "consensusNode" // EdgeConsensusNode consensus node of the group of peers.
+	EdgeTrustedPool   = "trustedPool"   // EdgeTrustedPool full or bridge node used by a light node without connections.
+)
+
+// ordinalSuffix matches the ordinal that the StatefulSets add to the name of the pods.
+var ordinalSuffix = regexp.MustCompile(`-[0-9]+$`)
+
+// Node represents a node of the topology.
+type Node struct {
+	Name     string `json:"name"`               // Name of the node.
+	Type     string `json:"type"`               // Type of the node, empty if it can't be resolved.
+	Family   string `json:"family"`             // Family of the node: da or consensus.
+	Declared bool   `json:"declared,omitempty"` // Declared true if the node is only declared as consensusNode.
+}
+
+// Edge represents a connection from a node to another one.
+type Edge struct {
+	From string `json:"from"` // From node which connects.
+	To   string `json:"to"`   // To node, or the reference when it's external or dangling.
+	Kind string `json:"kind"` // Kind of link: connectsTo, envVar, seed or consensusNode.
+}
+
+// Reference represents a connection which doesn't point to a node of the config.
+type Reference struct {
+	From   string `json:"from"`   // From node which connects.
+	Target string `json:"target"` // Target as written in the config.
+	Kind   string `json:"kind"`   // Kind of link.
+}
+
+// Graph is the directed graph of the connections between the nodes of the config.
+type Graph struct {
+	Nodes    map[string]*Node  // Nodes by name.
+	Edges    map[string][]Edge // Edges connections of each node, by the name of the node which connects.
+	External []Reference       // External multi addresses, they can't be checked.
+	Dangling []Reference       // Dangling references to nodes that don't exist.
+	Self     []Reference       // Self references.
+	Unknown  map[string]string // Unknown nodes whose type can't be resolved, with the error.
+	aliases  map[string]string // aliases other names of the nodes: service names and names without the ordinal.
+}
+
+// Build returns the graph of the config, the connections are resolved using the node name, the service name, or the
+// node name without the ordinal of the pod.
+func Build(cfg config.MutualPeersConfig) *Graph {
+	g := &Graph{
+		Nodes:   make(map[string]*Node),
+		Edges:   make(map[string][]Edge),
+		Unknown: make(map[string]string),
+		aliases: make(map[string]string),
+	}
+
+	// add the nodes first, so the connections can point to nodes declared later
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			if _, ok := g.Nodes[peer.NodeName]; ok {
+				continue
+			}
+			node := &Node{Name: peer.NodeName}
+			t, err := nodes.ResolveNodeType(peer)
+			if err != nil {
+				g.Unknown[peer.NodeName] = err.Error()
+			} else {
+				node.Type = t.Name()
+				node.Family = t.Family()
+			}
+			g.Nodes[peer.NodeName] = node
+			g.addAlias(peer.ServiceName, peer.NodeName)
+			g.addAlias(ordinalSuffix.ReplaceAllString(peer.NodeName, ""), peer.NodeName)
+		}
+	}
+	for _, mutualPeer := range cfg.MutualPeers {
+		if mutualPeer.ConsensusNode == "" || g.Resolve(mutualPeer.ConsensusNode) != "" {
+			continue
+		}
+		g.Nodes[mutualPeer.ConsensusNode] = &Node{
+			Name:     mutualPeer.ConsensusNode,
+			Type:     nodes.TypeConsensusValidator,
+			Family:   nodes.FamilyConsensus,
+			Declared: true,
+		}
+	}
+
+	for _, mutualPeer := range cfg.MutualPeers {
+		for _, peer := range mutualPeer.Peers {
+			for index, target := range peer.ConnectsTo {
+				kind := EdgeConnectsTo
+				if peer.ConnectsAsEnvVar && index == 0 {
+					kind = EdgeEnvVar
+				}
+				g.addEdge(peer.NodeName, target, kind)
+			}
+			for _, target := range peer.Seeds {
+				g.addEdge(peer.NodeName, target, EdgeSeed)
+			}
+			if mutualPeer.ConsensusNode != "" {
+				g.addEdge(peer.NodeName, mutualPeer.ConsensusNode, EdgeConsensusNode)
+			}
+			// the light nodes without connections use the full and bridge nodes
+			if g.Nodes[peer.NodeName].Type == nodes.TypeDALight && len(peer.ConnectsTo) == 0 && !peer.ConnectsAsEnvVar {
+				for _, target := range nodes.TrustedPeerPool(peer.NodeName, cfg) {
+					g.addEdge(peer.NodeName, target, EdgeTrustedPool)
+				}
+			}
+		}
+	}
+
+	return g
+}
+
+// Resolve returns the name of the node referenced, or an empty string if it doesn't exist.
+func (g *Graph) Resolve(reference string) string {
+	if _, ok := g.Nodes[reference]; ok {
+		return reference
+	}
+	return g.aliases[reference]
+}
+
+// Names returns the names of the nodes, sorted.
+func (g *Graph) Names() []string {
+	names := make([]string, 0, len(g.Nodes))
+	for name := range g.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EdgeCount returns the number of connections between nodes of the config.
+func (g *Graph) EdgeCount() int {
+	count := 0
+	for _, edges := range g.Edges {
+		count += len(edges)
+	}
+	return count
+}
+
+// addAlias adds another name to reference the node, the names of the nodes take precedence.
+func (g *Graph) addAlias(alias, nodeName string) {
+	if alias == "" || alias == nodeName {
+		return
+	}
+	if _, ok := g.aliases[alias]; !ok {
+		g.aliases[alias] = nodeName
+	}
+}
+
+// addEdge adds the connection from the node to the target, or records it as external, dangling or self reference.
+func (g *Graph) addEdge(from, target, kind string) {
+	ref := Reference{From: from, Target: target, Kind: kind}
+
+	// the multi addresses are external to the config
+	if strings.HasPrefix(target, "/") {
+		g.External = append(g.External, ref)
+		return
+	}
+
+	to := g.Resolve(target)
+	switch {
+	case to == "":
+		g.Dangling = append(g.Dangling, ref)
+	case to == from:
+		g.Self = append(g.Self, ref)
+	default:
+		for _, edge := range g.Edges[from] {
+			if edge.To == to {
+				return
+			}
+		}
+		g.Edges[from] = append(g.Edges[from], Edge{From: from, To: to, Kind: kind})
+	}
+}
@@ -0,0 +1,158 @@
+package topology
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jrmanes/torch/config"
+	"github.com/jrmanes/torch/pkg/nodes"
+)
+
+const (
+	StrategySpread    = "spread"    // StrategySpread the nodes connect to consecutive targets, spread evenly between them.
+	StrategySameIndex = "sameIndex" // StrategySameIndex each node connects to the target in the same position.
+)
+
+// target represents a node that the nodes generated by a rule can connect to.
+type target struct {
+	nodeType  string // nodeType resolved type of the node.
+	reference string // reference used in connectsTo.
+}
+
+// Expand returns the config with the peers generated by the rules, each rule generates a group of peers. The rules
+// are expanded in order, so they can connect to the nodes generated by the previous rules, and the peers generated
+// before are replaced, so the config can be expanded again.
+func Expand(cfg config.MutualPeersConfig) (config.MutualPeersConfig, error) {
+	expanded := cfg.Unexpanded()
+	if len(cfg.Rules) == 0 {
+		return expanded, nil
+	}
+
+	names := make(map[string]bool)
+	var targets []target
+	for _, mutualPeer := range expanded.MutualPeers {
+		if mutualPeer.ConsensusNode != "" {
+			targets = append(targets, target{nodeType: nodes.TypeConsensusValidator, reference: mutualPeer.ConsensusNode})
+		}
+		for _, peer := range mutualPeer.Peers {
+			names[peer.NodeName] = true
+			if t, err := nodes.ResolveNodeType(peer); err == nil {
+				targets = append(targets, target{nodeType: t.Name(), reference: targetReference(peer, t)})
+			}
+		}
+	}
+
+	for _, rule := range cfg.Rules {
+		peers, err := expandRule(rule, targets)
+		if err != nil {
+			return cfg, fmt.Errorf("error expanding the rule [%s]: %w", rule.Name, err)
+		}
+
+		t, _ := nodes.GetNodeType(rule.NodeType)
+		for _, peer := range peers {
+			if names[peer.NodeName] {
+				return cfg, fmt.Errorf("error expanding the rule [%s]: the node [%s] already exists", rule.Name, peer.NodeName)
+			}
+			names[peer.NodeName] = true
+			targets = append(targets, target{nodeType: t.Name(), reference: targetReference(peer, t)})
+		}
+
+		expanded.MutualPeers = append(expanded.MutualPeers, &config.MutualPeer{Peers: peers, Rule: rule.Name})
+	}
+
+	return expanded, nil
+}
+
+// expandRule returns the peers generated by the rule, connected to the targets of the type specified.
+func expandRule(rule config.Rule, targets []target) ([]config.Peer, error) {
+	if rule.Name == "" {
+		return nil, fmt.Errorf("the name is required")
+	}
+	if _, ok := nodes.GetNodeType(rule.NodeType); !ok {
+		return nil, fmt.Errorf("unknown node type [%s], valid types: %s", rule.NodeType, strings.Join(nodes.NodeTypeNames(), ", "))
+	}
+	if strings.Count(rule.NamePattern, "%d") != 1 {
+		return nil, fmt.Errorf("the namePattern [%s] must contain %%d once", rule.NamePattern)
+	}
+	if rule.Count <= 0 {
+		return nil, fmt.Errorf("the count must be greater than 0")
+	}
+
+	startIndex := rule.StartIndex
+	if startIndex == 0 {
+		startIndex = 1
+	}
+
+	var candidates []string
+	if rule.ConnectsTo != nil {
+		for _, t := range targets {
+			if t.nodeType == rule.ConnectsTo.NodeType {
+				candidates = append(candidates, t.reference)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("there are no nodes of type [%s] to connect to", rule.ConnectsTo.NodeType)
+		}
+	}
+
+	peers := make([]config.Peer, 0, rule.Count)
+	for i := 0; i < rule.Count; i++ {
+		peer := config.Peer{
+			NodeName:           fmt.Sprintf(rule.NamePattern, startIndex+i),
+			NodeType:           rule.NodeType,
+			Namespace:          rule.Namespace,
+			ContainerName:      rule.ContainerName,
+			ContainerSetupName: rule.ContainerSetupName,
+			ConnectsAsEnvVar:   rule.ConnectsAsEnvVar,
+		}
+		if rule.ConnectsTo != nil {
+			connectsTo, err := selectTargets(*rule.ConnectsTo, candidates, i)
+			if err != nil {
+				return nil, err
+			}
+			peer.ConnectsTo = connectsTo
+		}
+		peers = append(peers, peer)
+	}
+
+	return peers, nil
+}
+
+// selectTargets returns the targets of the node in the position received, using the strategy of the connection.
+func selectTargets(connection config.RuleConnection, candidates []string, position int) ([]string, error) {
+	switch connection.Strategy {
+	case StrategySameIndex:
+		if position >= len(candidates) {
+			return nil, fmt.Errorf("there is no node of type [%s] with the index [%d]", connection.NodeType, position)
+		}
+		return []string{candidates[position]}, nil
+	case StrategySpread, "":
+		count := connection.Count
+		if count <= 0 {
+			count = 1
+		}
+		if count > len(candidates) {
+			return nil, fmt.Errorf("there are [%d] nodes of type [%s], the rule needs [%d]", len(candidates), connection.NodeType, count)
+		}
+		// each node starts where the previous one finished, so the connections are spread evenly
+		selected := make([]string, 0, count)
+		for j := 0; j < count; j++ {
+			selected = append(selected, candidates[(position*count+j)%len(candidates)])
+		}
+		return selected, nil
+	default:
+		return nil, fmt.Errorf("unknown strategy [%s], valid strategies: %s, %s", connection.Strategy, StrategySpread, StrategySameIndex)
+	}
+}
+
+// targetReference returns how the other nodes reference the peer in connectsTo: the DA nodes use the node name, which
+// is the key in the DB, and the consensus nodes use the service, which is their host.
+func targetReference(peer config.Peer, t nodes.NodeType) string {
+	if t.Family() == nodes.FamilyDA {
+		return peer.NodeName
+	}
+	if peer.ServiceName != "" {
+		return peer.ServiceName
+	}
+	return ordinalSuffix.ReplaceAllString(peer.NodeName, "")
+}
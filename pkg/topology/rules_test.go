@@ -0,0 +1,133 @@
+package topology
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jrmanes/torch/config"
+)
+
+func TestExpand(t *testing.T) {
+	consensus := []*config.MutualPeer{
+		{ConsensusNode: "consensus-validator-1"},
+		{Peers: []config.Peer{{NodeName: "consensus-full-1-0", NodeType: "consensus-full"}}},
+		{Peers: []config.Peer{{NodeName: "consensus-full-2-0", NodeType: "consensus-full"}}},
+	}
+
+	tests := []struct {
+		name    string
+		rules   []config.Rule
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name: "Case 1: Bridges with the same index and full nodes spread between them",
+			rules: []config.Rule{
+				{
+					Name:        "bridges",
+					NodeType:    "da-bridge",
+					NamePattern: "da-bridge-%d-0",
+					Count:       2,
+					ConnectsTo:  &config.RuleConnection{NodeType: "consensus-full", Strategy: StrategySameIndex},
+				},
+				{
+					Name:        "full-nodes",
+					NodeType:    "da-full",
+					NamePattern: "da-full-%d-0",
+					Count:       3,
+					ConnectsTo:  &config.RuleConnection{NodeType: "da-bridge", Count: 1},
+				},
+			},
+			want: map[string][]string{
+				"da-bridge-1-0": {"consensus-full-1"},
+				"da-bridge-2-0": {"consensus-full-2"},
+				"da-full-1-0":   {"da-bridge-1-0"},
+				"da-full-2-0":   {"da-bridge-2-0"},
+				"da-full-3-0":   {"da-bridge-1-0"},
+			},
+		},
+		{
+			name: "Case 2: Nodes connected to more than one node",
+			rules: []config.Rule{
+				{
+					Name:        "consensus",
+					NodeType:    "consensus-full",
+					NamePattern: "consensus-full-%d-0",
+					StartIndex:  3,
+					Count:       1,
+					ConnectsTo:  &config.RuleConnection{NodeType: "consensus-validator"},
+				},
+				{
+					Name:        "light-nodes",
+					NodeType:    "da-light",
+					NamePattern: "da-light-%d-0",
+					Count:       1,
+					ConnectsTo:  &config.RuleConnection{NodeType: "consensus-full", Count: 2},
+				},
+			},
+			want: map[string][]string{
+				"consensus-full-3-0": {"consensus-validator-1"},
+				"da-light-1-0":       {"consensus-full-1", "consensus-full-2"},
+			},
+		},
+		{
+			name: "Case 3: Not enough nodes to connect to",
+			rules: []config.Rule{
+				{
+					Name:        "full-nodes",
+					NodeType:    "da-full",
+					NamePattern: "da-full-%d-0",
+					Count:       1,
+					ConnectsTo:  &config.RuleConnection{NodeType: "consensus-full", Count: 3},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Case 4: Node generated twice",
+			rules: []config.Rule{
+				{Name: "consensus", NodeType: "consensus-full", NamePattern: "consensus-full-%d-0", Count: 1},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.MutualPeersConfig{MutualPeers: consensus, Rules: tt.rules}
+
+			got, err := Expand(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			connections := make(map[string][]string)
+			for _, mutualPeer := range got.MutualPeers {
+				if mutualPeer.Rule == "" {
+					continue
+				}
+				for _, peer := range mutualPeer.Peers {
+					connections[peer.NodeName] = peer.ConnectsTo
+				}
+			}
+			if !reflect.DeepEqual(connections, tt.want) {
+				t.Errorf("Expand() = %v, want %v", connections, tt.want)
+			}
+
+			// expanding the config again generates the same peers
+			again, err := Expand(got)
+			if err != nil {
+				t.Fatalf("Expand() again error = %v", err)
+			}
+			if !reflect.DeepEqual(again, got) {
+				t.Errorf("Expand() again = %v, want %v", again, got)
+			}
+			if unexpanded := got.Unexpanded(); len(unexpanded.MutualPeers) != len(consensus) {
+				t.Errorf("Unexpanded() = %d groups, want %d", len(unexpanded.MutualPeers), len(consensus))
+			}
+		})
+	}
+}